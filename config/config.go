@@ -11,31 +11,95 @@ import (
 )
 
 type conf struct {
-	MongoURI       string   `yaml:"MongoURI"`
-	Address        string   `yaml:"Address"`
-	TrustedProxies []string `yaml:"TrustedProxies"`
-	SSLCert        string   `yaml:"SSLCert"`
-	SSLKey         string   `yaml:"SSLKey"`
-	LogErrorOnly   bool     `yaml:"LogErrorOnly"`
-	DNSServers     []string `yaml:"DNSServers"`
-	DNSPort        string   `yaml:"DNSPort"`
-	DNSProtocol    string   `yaml:"DNSProtocol"`
-	DomainWorker   int      `yaml:"DomainWorker"`
-	DomainBuffer   int      `yaml:"DomainBuffer"`
+	Store                 string            `yaml:"Store"`
+	StorePath             string            `yaml:"StorePath"`
+	MongoURI              string            `yaml:"MongoURI"`
+	Address               string            `yaml:"Address"`
+	TrustedProxies        []string          `yaml:"TrustedProxies"`
+	SSLCert               string            `yaml:"SSLCert"`
+	SSLKey                string            `yaml:"SSLKey"`
+	LogErrorOnly          bool              `yaml:"LogErrorOnly"`
+	DNSServers            []string          `yaml:"DNSServers"`
+	DNSPort               string            `yaml:"DNSPort"`
+	DNSProtocol           string            `yaml:"DNSProtocol"`
+	DomainWorker          int               `yaml:"DomainWorker"`
+	DomainBuffer          int               `yaml:"DomainBuffer"`
+	TopListOptOut         []string          `yaml:"TopListOptOut"`
+	AdminKeys             map[string]string `yaml:"AdminKeys"`
+	NotFoundDiscovery     bool              `yaml:"NotFoundDiscovery"`
+	APIKeys               map[string]string `yaml:"APIKeys"`
+	BruteForce            bool              `yaml:"BruteForce"`
+	BruteForceWordlist    string            `yaml:"BruteForceWordlist"`
+	BruteForceWorker      int               `yaml:"BruteForceWorker"`
+	BruteForceRate        int               `yaml:"BruteForceRate"`
+	BruteForceWordlistTop int               `yaml:"BruteForceWordlistTop"`
+	BatchLookupMax        int               `yaml:"BatchLookupMax"`
+	BatchLookupWorker     int               `yaml:"BatchLookupWorker"`
+	CacheControl          map[string]string `yaml:"CacheControl"`
+	CacheSize             int               `yaml:"CacheSize"`
+	CacheTTL              int               `yaml:"CacheTTL"`
+	Bloom                 bool              `yaml:"Bloom"`
+	BloomCapacity         int               `yaml:"BloomCapacity"`
+	BloomFPRate           float64           `yaml:"BloomFPRate"`
+	BloomSnapshot         string            `yaml:"BloomSnapshot"`
+	BloomRebuild          int               `yaml:"BloomRebuild"`
+	MigrateOnStartup      *bool             `yaml:"MigrateOnStartup"`
+	DumpDir               string            `yaml:"DumpDir"`
+	DumpInterval          int               `yaml:"DumpInterval"`
+	DumpDeltaDays         int               `yaml:"DumpDeltaDays"`
+	RetentionNames        int               `yaml:"RetentionNames"`
+	RetentionAction       string            `yaml:"RetentionAction"`
+	RetentionRecords      int               `yaml:"RetentionRecords"`
+	RetentionRecordCap    int               `yaml:"RetentionRecordCap"`
+	RetentionDryRun       *bool             `yaml:"RetentionDryRun"`
+	RetentionInterval     int               `yaml:"RetentionInterval"`
+	PrivateSuffixes       bool              `yaml:"PrivateSuffixes"`
 }
 
 var (
-	MongoURI       string   // MongoDB connection string
-	Address        string   // Address to listen on
-	TrustedProxies []string // A list of trusted proxies
-	SSLCert        string
-	SSLKey         string
-	LogErrorOnly   bool
-	DNSServers     []string
-	DNSPort        string
-	DNSProtocol    string
-	DomainWorker   int
-	DomainBuffer   int
+	Store                 string   // Storage backend, "mongodb" or "embedded"
+	StorePath             string   // Path to the database file of the embedded store
+	MongoURI              string   // MongoDB connection string
+	Address               string   // Address to listen on
+	TrustedProxies        []string // A list of trusted proxies
+	SSLCert               string
+	SSLKey                string
+	LogErrorOnly          bool
+	DNSServers            []string
+	DNSPort               string
+	DNSProtocol           string
+	DomainWorker          int
+	DomainBuffer          int
+	TopListOptOut         []string          // Domains that never exposed in the toplist and trending APIs
+	AdminKeys             map[string]string // Admin name -> API key
+	NotFoundDiscovery     bool              // Resolve the common hostnames for domains in notFound
+	APIKeys               map[string]string // User name -> API key
+	BruteForce            bool              // Enable the brute-force discovery
+	BruteForceWordlist    string            // Path to the wordlist used to brute-force
+	BruteForceWorker      int               // Number of zones brute-forced concurrently
+	BruteForceRate        int               // Number of names resolved in a second in a zone
+	BruteForceWordlistTop int               // Number of labels used from the generated wordlist if BruteForceWordlist is empty
+	BatchLookupMax        int               // Maximum number of domains in a single batch lookup
+	BatchLookupWorker     int               // Number of concurrent queries in a single batch lookup
+	CacheControl          map[string]string // Route (eg.: /api/lookup/:domain) -> Cache-Control header value
+	CacheSize             int               // Maximum number of entries in the lookup cache, negative disables the cache
+	CacheTTL              time.Duration     // Lifetime of an entry in the lookup cache
+	Bloom                 bool              // Answer the unknown domains from the Bloom filter without querying the DB
+	BloomCapacity         int               // Expected number of domains in the Bloom filter
+	BloomFPRate           float64           // Target false positive rate of the Bloom filter
+	BloomSnapshot         string            // Path to the snapshot of the Bloom filter
+	BloomRebuild          time.Duration     // Time between two rebuild of the Bloom filter
+	MigrateOnStartup      bool              // Apply the database migrations before starting the server
+	DumpDir               string            // Directory of the dump files, empty disables the dumps
+	DumpInterval          time.Duration     // Time between two generation of the dump files
+	DumpDeltaDays         int               // Number of days the delta files are kept
+	RetentionNames        int               // Remove the names without successful resolution in this many days, 0 disables
+	RetentionAction       string            // What to do with the removed names, "archive" or "delete"
+	RetentionRecords      int               // Remove the records older than this many days, 0 disables
+	RetentionRecordCap    int               // Keep this many newest values of every record type of a name, 0 disables
+	RetentionDryRun       bool              // Only report what the retention policy would remove
+	RetentionInterval     time.Duration     // Time between two run of the retention policy
+	PrivateSuffixes       bool              // Split the names based on the private suffixes of the Public Suffix List (eg.: github.io) too, so every tenant is its own domain
 )
 
 // Parse parses the config file in path and gill the global variables.
@@ -53,9 +117,31 @@ func Parse(path string) error {
 		return fmt.Errorf("failed to unmarshal: %s", err)
 	}
 
-	if c.MongoURI == "" {
-		return fmt.Errorf("MongoURI is empty")
+	if c.Store == "" {
+		c.Store = "mongodb"
 	}
+
+	switch c.Store {
+	case "mongodb":
+		if c.MongoURI == "" {
+			return fmt.Errorf("MongoURI is empty")
+		}
+	case "embedded":
+		if c.StorePath == "" {
+			return fmt.Errorf("StorePath is empty")
+		}
+		if c.Bloom {
+			return fmt.Errorf("Bloom requires the mongodb Store")
+		}
+		if c.BruteForce && c.BruteForceWordlist == "" {
+			return fmt.Errorf("BruteForceWordlist is required with the embedded Store")
+		}
+	default:
+		return fmt.Errorf("invalid Store: %s", c.Store)
+	}
+
+	Store = c.Store
+	StorePath = c.StorePath
 	MongoURI = c.MongoURI
 
 	if c.Address == "" {
@@ -104,5 +190,154 @@ func Parse(path string) error {
 
 	DomainBuffer = c.DomainBuffer
 
+	// Must be a non nil slice, it is used in a $nin query
+	TopListOptOut = make([]string, 0, len(c.TopListOptOut))
+
+	for i := range c.TopListOptOut {
+		TopListOptOut = append(TopListOptOut, dns.Clean(c.TopListOptOut[i]))
+	}
+
+	for name, key := range c.AdminKeys {
+		if key == "" {
+			return fmt.Errorf("AdminKeys: key for %s is empty", name)
+		}
+	}
+
+	AdminKeys = c.AdminKeys
+
+	NotFoundDiscovery = c.NotFoundDiscovery
+
+	for name, key := range c.APIKeys {
+		if key == "" {
+			return fmt.Errorf("APIKeys: key for %s is empty", name)
+		}
+	}
+
+	APIKeys = c.APIKeys
+
+	BruteForce = c.BruteForce
+
+	BruteForceWordlist = c.BruteForceWordlist
+
+	if c.BruteForceWorker == 0 {
+		c.BruteForceWorker = 1
+	}
+
+	BruteForceWorker = c.BruteForceWorker
+
+	if c.BruteForceRate == 0 {
+		c.BruteForceRate = 10
+	}
+
+	BruteForceRate = c.BruteForceRate
+
+	if c.BruteForceWordlistTop == 0 {
+		c.BruteForceWordlistTop = 1000
+	}
+
+	BruteForceWordlistTop = c.BruteForceWordlistTop
+
+	if c.BatchLookupMax == 0 {
+		c.BatchLookupMax = 1000
+	}
+
+	BatchLookupMax = c.BatchLookupMax
+
+	if c.BatchLookupWorker == 0 {
+		c.BatchLookupWorker = 8
+	}
+
+	BatchLookupWorker = c.BatchLookupWorker
+
+	CacheControl = c.CacheControl
+
+	if c.CacheSize == 0 {
+		c.CacheSize = 10000
+	}
+
+	CacheSize = c.CacheSize
+
+	if c.CacheTTL == 0 {
+		c.CacheTTL = 300
+	}
+
+	CacheTTL = time.Duration(c.CacheTTL) * time.Second
+
+	Bloom = c.Bloom
+
+	if c.BloomCapacity == 0 {
+		c.BloomCapacity = 10000000
+	}
+
+	if c.BloomCapacity < 0 {
+		return fmt.Errorf("BloomCapacity must be positive")
+	}
+
+	BloomCapacity = c.BloomCapacity
+
+	if c.BloomFPRate == 0 {
+		c.BloomFPRate = 0.01
+	}
+
+	if c.BloomFPRate < 0 || c.BloomFPRate >= 1 {
+		return fmt.Errorf("BloomFPRate must be between 0 and 1")
+	}
+
+	BloomFPRate = c.BloomFPRate
+
+	BloomSnapshot = c.BloomSnapshot
+
+	if c.BloomRebuild == 0 {
+		c.BloomRebuild = 6
+	}
+
+	BloomRebuild = time.Duration(c.BloomRebuild) * time.Hour
+
+	// Enabled by default
+	MigrateOnStartup = c.MigrateOnStartup == nil || *c.MigrateOnStartup
+
+	DumpDir = c.DumpDir
+
+	if c.DumpInterval == 0 {
+		c.DumpInterval = 24
+	}
+
+	DumpInterval = time.Duration(c.DumpInterval) * time.Hour
+
+	if c.DumpDeltaDays == 0 {
+		c.DumpDeltaDays = 30
+	}
+
+	DumpDeltaDays = c.DumpDeltaDays
+
+	if c.RetentionNames < 0 || c.RetentionRecords < 0 || c.RetentionRecordCap < 0 {
+		return fmt.Errorf("retention policies must not be negative")
+	}
+
+	RetentionNames = c.RetentionNames
+	RetentionRecords = c.RetentionRecords
+	RetentionRecordCap = c.RetentionRecordCap
+
+	if c.RetentionAction == "" {
+		c.RetentionAction = "archive"
+	}
+
+	if c.RetentionAction != "archive" && c.RetentionAction != "delete" {
+		return fmt.Errorf("invalid RetentionAction: %s", c.RetentionAction)
+	}
+
+	RetentionAction = c.RetentionAction
+
+	// Enabled by default, removing is an explicit decision
+	RetentionDryRun = c.RetentionDryRun == nil || *c.RetentionDryRun
+
+	if c.RetentionInterval == 0 {
+		c.RetentionInterval = 24
+	}
+
+	RetentionInterval = time.Duration(c.RetentionInterval) * time.Hour
+
+	PrivateSuffixes = c.PrivateSuffixes
+
 	return nil
 }
@@ -0,0 +1,97 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// backfill sets field to the value returned by value() for the documents in the "domains" collection that does not have field.
+// Failed updates are printed to STDERR and skipped.
+//
+// Returns the number of updated documents.
+func backfill(field string, value func(d *FastDomainSchema) interface{}) (int, error) {
+
+	cursor, err := Domains.Find(context.TODO(), bson.M{field: bson.M{"$exists": false}}, options.Find().SetProjection(bson.M{"domain": 1, "tld": 1, "sub": 1}))
+	if err != nil {
+		return 0, fmt.Errorf("failed to find: %w", err)
+	}
+	defer cursor.Close(context.TODO())
+
+	n := 0
+
+	for cursor.Next(context.TODO()) {
+
+		d := new(FastDomainSchema)
+
+		err = cursor.Decode(d)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "backfill(): Failed to decode: %s\n", err)
+			continue
+		}
+
+		filter := bson.D{{Key: "domain", Value: d.Domain}, {Key: "tld", Value: d.TLD}, {Key: "sub", Value: d.Sub}}
+
+		_, err = Domains.UpdateOne(context.TODO(), filter, bson.M{"$set": bson.M{field: value(d)}})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "backfill(): Failed to set %s for %s: %s\n", field, d.String(), err)
+			continue
+		}
+
+		n++
+	}
+
+	if err := cursor.Err(); err != nil {
+		return n, fmt.Errorf("cursor failed: %w", err)
+	}
+
+	return n, nil
+}
+
+// backfillNgrams sets the "ngrams" field used by Contains().
+func backfillNgrams() (int, error) {
+	return backfill("ngrams", func(d *FastDomainSchema) interface{} { return Ngrams(d.String()) })
+}
+
+// backfillRsub sets the "rsub" field used by the subtree lookup.
+func backfillRsub() (int, error) {
+	return backfill("rsub", func(d *FastDomainSchema) interface{} { return ReverseLabels(d.Sub) })
+}
+
+// backfillAll sets every field that Insert() sets.
+// The errors are printed to STDERR.
+func backfillAll() {
+
+	for field, f := range map[string]func() (int, error){"ngrams": backfillNgrams, "rsub": backfillRsub} {
+
+		n, err := f()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "BackfillWorker(): Failed to backfill %s: %s\n", field, err)
+		}
+
+		if n > 0 {
+			fmt.Printf("BackfillWorker(): Set %s for %d documents\n", field, n)
+		}
+	}
+}
+
+// BackfillWorker sets the fields that Insert() sets (eg.: "ngrams", "rsub") for the documents without them at the beginning and in every hour.
+// The initial backfill after the migrations and the documents inserted by other processes directly into the DB are handled here,
+// until then these names are missing from the contains search and the subtree lookups.
+//
+// This function is designed to run as a goroutine in the background.
+// The errors are printed to STDERR.
+func BackfillWorker() {
+
+	backfillAll()
+
+	t := time.Tick(time.Hour)
+
+	for range t {
+		backfillAll()
+	}
+}
@@ -0,0 +1,409 @@
+package db
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/elmasy-com/columbus-server/fault"
+	"github.com/elmasy-com/columbus-server/idn"
+	"github.com/elmasy-com/elnet/dns"
+	"github.com/elmasy-com/elnet/valid"
+)
+
+// Actions in BlockedAuditSchema.
+const (
+	BlockedActionBlock   = "block"
+	BlockedActionUnblock = "unblock"
+)
+
+// Maximum number of entries returned from the audit trail.
+const MaxBlockedAudit = 10000
+
+var (
+	blockedDomains  = make(map[string]struct{}) // The blocked domains
+	blockedPatterns []string                    // The blocked glob-style patterns
+	blockedVersion  string                      // Changes with the blocked entries, see BlockedVersion()
+	blockedNewest   int64                       // The creation time of the newest blocked entry
+	blockedM        sync.RWMutex
+)
+
+// ParseBlocked validates and normalizes the blocked entry pattern (see BlockedSchema).
+// Internationalized domains and the labels of the patterns without glob characters are converted to A-label form.
+//
+// If pattern is a glob-style pattern (contains "*", "?" or "["), returns true.
+// If the pattern is invalid, returns fault.ErrInvalidPattern.
+// If the domain is invalid or a TLD, returns fault.ErrInvalidDomain.
+func ParseBlocked(pattern string) (string, bool, error) {
+
+	pattern = strings.ToLower(strings.TrimSpace(pattern))
+
+	if strings.ContainsAny(pattern, "*?[") {
+
+		if pattern == "" || strings.Contains(pattern, "/") {
+			return "", false, fault.ErrInvalidPattern
+		}
+
+		if _, err := path.Match(pattern, ""); err != nil {
+			return "", false, fault.ErrInvalidPattern
+		}
+
+		labels := strings.Split(pattern, ".")
+
+		for i := range labels {
+
+			if strings.ContainsAny(labels[i], "*?[]") {
+				continue
+			}
+
+			l, err := idn.ToASCII(labels[i])
+			if err != nil {
+				return "", false, fault.ErrInvalidPattern
+			}
+
+			labels[i] = l
+		}
+
+		return strings.Join(labels, "."), true, nil
+	}
+
+	d, err := idn.ToASCII(pattern)
+	if err != nil || !valid.Domain(d) {
+		return "", false, fault.ErrInvalidDomain
+	}
+
+	d = dns.Clean(d)
+
+	// A TLD can not be blocked with a domain, only with a pattern
+	p := GetParts(d)
+	if p == nil || p.Domain == "" || p.TLD == "" {
+		return "", false, fault.ErrInvalidDomain
+	}
+
+	return d, false, nil
+}
+
+// BlockedLoad loads the blocked entries from the store.
+// The patterns stored before the normalization of the internationalized labels are normalized with ParseBlocked().
+//
+// If an entry is blocked since the previous load, the regeneration of the dump files is requested (see DumpWorker()).
+func BlockedLoad() error {
+
+	bs, err := store.BlockedGets()
+	if err != nil {
+		return err
+	}
+
+	domains := make(map[string]struct{}, len(bs))
+	var (
+		patterns []string
+		newest   int64
+	)
+
+	for i := range bs {
+
+		if bs[i].Created > newest {
+			newest = bs[i].Created
+		}
+
+		pattern, isPattern, err := ParseBlocked(bs[i].Pattern)
+		if err != nil {
+			pattern, isPattern = bs[i].Pattern, strings.ContainsAny(bs[i].Pattern, "*?[")
+		}
+
+		if isPattern {
+			patterns = append(patterns, pattern)
+		} else {
+			domains[pattern] = struct{}{}
+		}
+	}
+
+	blockedM.Lock()
+	loaded := blockedVersion != ""
+	prev := blockedNewest
+	blockedDomains = domains
+	blockedPatterns = patterns
+	blockedVersion = fmt.Sprintf("%d-%d", len(bs), newest)
+	blockedNewest = newest
+	blockedM.Unlock()
+
+	if loaded && newest > prev {
+		dumpRequest()
+	}
+
+	return nil
+}
+
+// BlockedVersion returns a value that changes when an entry is blocked or unblocked.
+// Used in the validators of the conditional requests, the blocked names are filtered from the responses.
+func BlockedVersion() string {
+
+	blockedM.RLock()
+	defer blockedM.RUnlock()
+
+	return blockedVersion
+}
+
+// BlockedNewest returns the creation time of the newest blocked entry, 0 if nothing is blocked.
+func BlockedNewest() int64 {
+
+	blockedM.RLock()
+	defer blockedM.RUnlock()
+
+	return blockedNewest
+}
+
+// IsBlocked returns whether the full hostname d (eg.: www.example.com) is blocked.
+// d is blocked if d or any parent domain of d is blocked or d matches any blocked pattern.
+//
+// NOTE: This function not validate and Clean() d!
+func IsBlocked(d string) bool {
+
+	blockedM.RLock()
+	defer blockedM.RUnlock()
+
+	if len(blockedDomains) == 0 && len(blockedPatterns) == 0 {
+		return false
+	}
+
+	for v := d; v != ""; {
+
+		if _, ok := blockedDomains[v]; ok {
+			return true
+		}
+
+		i := strings.IndexByte(v, '.')
+		if i == -1 {
+			break
+		}
+
+		v = v[i+1:]
+	}
+
+	for i := range blockedPatterns {
+		if ok, _ := path.Match(blockedPatterns[i], d); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isBlockedParts returns whether the name with parts p is blocked.
+func isBlockedParts(p *dns.Parts) bool {
+	return IsBlocked((&FastDomainSchema{Domain: p.Domain, TLD: p.TLD, Sub: p.Sub}).String())
+}
+
+// blockedQuery returns whether the query of the names of the domain in p is blocked.
+// The query is blocked if the domain is blocked or, if f.Subtree is set, the subtree root in p is blocked.
+// The names of a not blocked query are filtered one by one.
+func blockedQuery(p *dns.Parts, f LookupFilter) bool {
+
+	if IsBlocked(p.Domain + "." + p.TLD) {
+		return true
+	}
+
+	return f.Subtree && p.Sub != "" && isBlockedParts(p)
+}
+
+// blockedSubs returns the subdomains of the domain in p that are not blocked.
+// subs is not modified.
+func blockedSubs(p *dns.Parts, subs []string) []string {
+
+	var r []string
+
+	for i := range subs {
+		if !isBlockedParts(&dns.Parts{Domain: p.Domain, TLD: p.TLD, Sub: subs[i]}) {
+			r = append(r, subs[i])
+		}
+	}
+
+	return r
+}
+
+// blockedNames returns the full hostnames from names that are not blocked.
+// names is not modified.
+func blockedNames(names []string) []string {
+
+	var r []string
+
+	for i := range names {
+		if !IsBlocked(names[i]) {
+			r = append(r, names[i])
+		}
+	}
+
+	return r
+}
+
+// blockedTLDs returns the TLDs from tlds where the Second Level Domain d is not blocked.
+// tlds is not modified.
+func blockedTLDs(d string, tlds []string) []string {
+
+	var r []string
+
+	for i := range tlds {
+		if !IsBlocked(d + "." + tlds[i]) {
+			r = append(r, tlds[i])
+		}
+	}
+
+	return r
+}
+
+// blockedStarts removes the Second Level Domains from ds that are blocked in every TLD.
+// Only the blocked domains are checked, the patterns are ignored.
+func blockedStarts(ds []string) ([]string, error) {
+
+	slds := blockedSLDs()
+	if len(slds) == 0 {
+		return ds, nil
+	}
+
+	var r []string
+
+	for i := range ds {
+
+		if _, ok := slds[ds[i]]; ok {
+
+			tlds, err := TLD(ds[i])
+			if err != nil {
+				return nil, err
+			}
+
+			if len(tlds) == 0 {
+				continue
+			}
+		}
+
+		r = append(r, ds[i])
+	}
+
+	return r, nil
+}
+
+// blockedSLDs returns the distinct Second Level Domains of the blocked domains.
+func blockedSLDs() map[string]struct{} {
+
+	blockedM.RLock()
+	defer blockedM.RUnlock()
+
+	slds := make(map[string]struct{})
+
+	for d := range blockedDomains {
+		if p := GetParts(d); p != nil && p.Sub == "" {
+			slds[p.Domain] = struct{}{}
+		}
+	}
+
+	return slds
+}
+
+// Block blocks pattern (see ParseBlocked()) with reason reason by admin admin and records it in the audit trail.
+//
+// If the pattern is invalid, returns fault.ErrInvalidPattern or fault.ErrInvalidDomain.
+// If reason is empty, returns fault.ErrReasonMissing.
+// If pattern is already blocked, returns fault.ErrNothingToDo.
+func Block(pattern string, reason string, admin string) (BlockedSchema, error) {
+
+	pattern, _, err := ParseBlocked(pattern)
+	if err != nil {
+		return BlockedSchema{}, err
+	}
+
+	if strings.TrimSpace(reason) == "" {
+		return BlockedSchema{}, fault.ErrReasonMissing
+	}
+
+	b := BlockedSchema{Pattern: pattern, Reason: reason, Admin: admin, Created: time.Now().Unix()}
+
+	inserted, err := store.BlockedInsert(b)
+	if err != nil {
+		return b, fmt.Errorf("failed to insert: %w", err)
+	}
+	if !inserted {
+		return b, fault.ErrNothingToDo
+	}
+
+	err = store.BlockedAuditInsert(BlockedAuditSchema{Action: BlockedActionBlock, Pattern: pattern, Reason: reason, Admin: admin, Time: b.Created})
+	if err != nil {
+		return b, fmt.Errorf("failed to insert audit: %w", err)
+	}
+
+	return b, BlockedLoad()
+}
+
+// Unblock removes the blocked entry pattern with reason reason by admin admin and records it in the audit trail.
+//
+// If the pattern is invalid, returns fault.ErrInvalidPattern or fault.ErrInvalidDomain.
+// If reason is empty, returns fault.ErrReasonMissing.
+// If pattern is not blocked, returns fault.ErrNotFound.
+func Unblock(pattern string, reason string, admin string) error {
+
+	pattern, _, err := ParseBlocked(pattern)
+	if err != nil {
+		return err
+	}
+
+	if strings.TrimSpace(reason) == "" {
+		return fault.ErrReasonMissing
+	}
+
+	deleted, err := store.BlockedDelete(pattern)
+	if err != nil {
+		return fmt.Errorf("failed to delete: %w", err)
+	}
+	if !deleted {
+		return fault.ErrNotFound
+	}
+
+	err = store.BlockedAuditInsert(BlockedAuditSchema{Action: BlockedActionUnblock, Pattern: pattern, Reason: reason, Admin: admin, Time: time.Now().Unix()})
+	if err != nil {
+		return fmt.Errorf("failed to insert audit: %w", err)
+	}
+
+	return BlockedLoad()
+}
+
+// BlockedGets returns every blocked entry sorted by pattern.
+func BlockedGets() ([]BlockedSchema, error) {
+
+	bs, err := store.BlockedGets()
+	if bs == nil {
+		bs = make([]BlockedSchema, 0)
+	}
+
+	return bs, err
+}
+
+// BlockedAuditGets returns the newest limit entries of the audit trail, the newest first.
+//
+// If limit is < 1 or > MaxBlockedAudit, returns fault.ErrInvalidLimit.
+func BlockedAuditGets(limit int) ([]BlockedAuditSchema, error) {
+
+	if limit < 1 || limit > MaxBlockedAudit {
+		return nil, fault.ErrInvalidLimit
+	}
+
+	return store.BlockedAuditGets(limit)
+}
+
+// BlockedWorker reloads the blocked entries in every minute to apply the changes made by other instances.
+//
+// This function is designed to run as a goroutine in the background.
+// The errors are printed to STDERR.
+func BlockedWorker() {
+
+	for {
+
+		time.Sleep(time.Minute)
+
+		err := BlockedLoad()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "BlockedWorker(): Failed to load blocked entries: %s\n", err)
+		}
+	}
+}
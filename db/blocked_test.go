@@ -0,0 +1,101 @@
+package db
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/elmasy-com/columbus-server/fault"
+	"github.com/elmasy-com/elnet/dns"
+)
+
+func TestBlocked(t *testing.T) {
+
+	s, err := NewBoltStore(filepath.Join(t.TempDir(), "columbus.db"))
+	if err != nil {
+		t.Fatalf("FAIL: %s\n", err)
+	}
+	defer s.Close()
+
+	SetStore(s)
+
+	// Other tests must not see the blocked entries
+	defer func() {
+		blockedDomains = make(map[string]struct{})
+		blockedPatterns = nil
+	}()
+
+	for _, p := range []string{"com", "*/x", "[a-"} {
+		if _, _, err := ParseBlocked(p); err == nil {
+			t.Fatalf("FAIL: %s is valid\n", p)
+		}
+	}
+
+	if _, err = Block("Example.com.", "court order", "admin"); err != nil {
+		t.Fatalf("FAIL: failed to block: %s\n", err)
+	}
+
+	if _, err = Block("example.com", "court order", "admin"); !errors.Is(err, fault.ErrNothingToDo) {
+		t.Fatalf("FAIL: blocked twice: %v\n", err)
+	}
+
+	if _, err = Block("*.internal.example.org", "", "admin"); !errors.Is(err, fault.ErrReasonMissing) {
+		t.Fatalf("FAIL: blocked without reason: %v\n", err)
+	}
+
+	if _, err = Block("*.internal.example.org", "leaked", "admin"); err != nil {
+		t.Fatalf("FAIL: failed to block pattern: %s\n", err)
+	}
+
+	if b, err := Block("*.Bücher.de", "leaked", "admin"); err != nil || b.Pattern != "*.xn--bcher-kva.de" {
+		t.Fatalf("FAIL: failed to block IDN pattern: %v, %v\n", b, err)
+	}
+
+	cases := map[string]bool{
+		"example.com":              true,
+		"www.example.com":          true,
+		"example.net":              false,
+		"a.b.internal.example.org": true,
+		"internal.example.org":     false,
+		"www.notexample.com":       false,
+		"www.xn--bcher-kva.de":     true,
+	}
+
+	for d, blocked := range cases {
+		if IsBlocked(d) != blocked {
+			t.Fatalf("FAIL: IsBlocked(%s) != %v\n", d, blocked)
+		}
+	}
+
+	if _, err = Lookup("www.example.com", LookupFilter{Days: -1}); !errors.Is(err, fault.ErrBlocked) {
+		t.Fatalf("FAIL: lookup of blocked domain returned %v\n", err)
+	}
+
+	if _, _, err = s.Insert(&dns.Parts{Sub: "a", Domain: "example", TLD: "org"}, SourceCT); err != nil {
+		t.Fatalf("FAIL: %s\n", err)
+	}
+	if _, _, err = s.Insert(&dns.Parts{Sub: "a.internal", Domain: "example", TLD: "org"}, SourceCT); err != nil {
+		t.Fatalf("FAIL: %s\n", err)
+	}
+
+	if subs, err := Lookup("example.org", LookupFilter{Days: -1}); err != nil || len(subs) != 1 || subs[0] != "a" {
+		t.Fatalf("FAIL: lookup returned %v, %v\n", subs, err)
+	}
+
+	if err = Unblock("example.com", "lifted", "admin"); err != nil {
+		t.Fatalf("FAIL: failed to unblock: %s\n", err)
+	}
+
+	if err = Unblock("example.com", "lifted", "admin"); !errors.Is(err, fault.ErrNotFound) {
+		t.Fatalf("FAIL: unblocked twice: %v\n", err)
+	}
+
+	if IsBlocked("example.com") {
+		t.Fatalf("FAIL: example.com is blocked after unblock\n")
+	}
+
+	as, err := BlockedAuditGets(10)
+	if err != nil || len(as) != 4 || as[0].Action != BlockedActionUnblock {
+		t.Fatalf("FAIL: invalid audit trail: %v, %v\n", as, err)
+	}
+}
@@ -0,0 +1,555 @@
+package db
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"math/bits"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/elmasy-com/columbus-server/config"
+	"github.com/elmasy-com/elnet/dns"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const bloomSnapshotMagic = "CBF1"
+
+const (
+	bloomCatchUpInterval = time.Minute     // Time between two poll of the new names, see bloomCatchUp()
+	bloomCatchUpMargin   = 5 * time.Minute // The ObjectIDs are generated by many processes, so not strictly increasing
+)
+
+// BloomFilter is a Bloom filter of strings, safe for concurrent use.
+type BloomFilter struct {
+	m     *sync.RWMutex
+	words []uint64
+	bits  uint64 // Number of bits
+	k     uint64 // Number of hash functions
+	n     uint64 // Approximate number of elements
+}
+
+// NewBloomFilter returns a BloomFilter sized for capacity elements with the false positive rate fpRate.
+func NewBloomFilter(capacity int, fpRate float64) *BloomFilter {
+
+	m := math.Ceil(-float64(capacity) * math.Log(fpRate) / (math.Ln2 * math.Ln2))
+	words := uint64(math.Ceil(m / 64))
+	if words == 0 {
+		words = 1
+	}
+
+	k := uint64(math.Round(float64(words*64) / float64(capacity) * math.Ln2))
+	if k == 0 {
+		k = 1
+	}
+
+	return &BloomFilter{m: new(sync.RWMutex), words: make([]uint64, words), bits: words * 64, k: k}
+}
+
+// bloomHashes returns the two base hashes of s used in the double hashing.
+func bloomHashes(s string) (uint64, uint64) {
+
+	h1 := fnv.New64a()
+	h1.Write([]byte(s))
+
+	h2 := fnv.New64()
+	h2.Write([]byte(s))
+
+	// h2 must be odd to reach every bit
+	return h1.Sum64(), h2.Sum64() | 1
+}
+
+// Add adds s to the filter.
+func (b *BloomFilter) Add(s string) {
+
+	h1, h2 := bloomHashes(s)
+
+	b.m.Lock()
+	defer b.m.Unlock()
+
+	changed := false
+
+	for i := uint64(0); i < b.k; i++ {
+
+		pos := (h1 + i*h2) % b.bits
+
+		if b.words[pos/64]&(1<<(pos%64)) == 0 {
+			b.words[pos/64] |= 1 << (pos % 64)
+			changed = true
+		}
+	}
+
+	if changed {
+		b.n++
+	}
+}
+
+// Test returns false if s is definitely not in the filter.
+func (b *BloomFilter) Test(s string) bool {
+
+	h1, h2 := bloomHashes(s)
+
+	b.m.RLock()
+	defer b.m.RUnlock()
+
+	for i := uint64(0); i < b.k; i++ {
+
+		pos := (h1 + i*h2) % b.bits
+
+		if b.words[pos/64]&(1<<(pos%64)) == 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// EstimatedFPRate returns the estimated false positive rate based on the ratio of the set bits.
+func (b *BloomFilter) EstimatedFPRate() float64 {
+
+	b.m.RLock()
+	defer b.m.RUnlock()
+
+	set := 0
+
+	for i := range b.words {
+		set += bits.OnesCount64(b.words[i])
+	}
+
+	return math.Pow(float64(set)/float64(b.bits), float64(b.k))
+}
+
+// WriteTo writes the filter to w in binary format.
+func (b *BloomFilter) WriteTo(w io.Writer) (int64, error) {
+
+	b.m.RLock()
+	defer b.m.RUnlock()
+
+	bw := bufio.NewWriter(w)
+
+	if _, err := bw.WriteString(bloomSnapshotMagic); err != nil {
+		return 0, err
+	}
+
+	for _, v := range append([]uint64{b.bits, b.k, b.n}, b.words...) {
+		if err := binary.Write(bw, binary.LittleEndian, v); err != nil {
+			return 0, err
+		}
+	}
+
+	return int64(len(bloomSnapshotMagic) + 8*(3+len(b.words))), bw.Flush()
+}
+
+// ReadBloomFilter reads a filter written by WriteTo() from r.
+func ReadBloomFilter(r io.Reader) (*BloomFilter, error) {
+
+	br := bufio.NewReader(r)
+
+	magic := make([]byte, len(bloomSnapshotMagic))
+
+	if _, err := io.ReadFull(br, magic); err != nil {
+		return nil, fmt.Errorf("failed to read magic: %w", err)
+	}
+
+	if string(magic) != bloomSnapshotMagic {
+		return nil, fmt.Errorf("invalid magic: %q", magic)
+	}
+
+	b := &BloomFilter{m: new(sync.RWMutex)}
+
+	for _, v := range []*uint64{&b.bits, &b.k, &b.n} {
+		if err := binary.Read(br, binary.LittleEndian, v); err != nil {
+			return nil, fmt.Errorf("failed to read header: %w", err)
+		}
+	}
+
+	if b.bits == 0 || b.bits%64 != 0 || b.k == 0 {
+		return nil, fmt.Errorf("invalid header: bits=%d, k=%d", b.bits, b.k)
+	}
+
+	b.words = make([]uint64, b.bits/64)
+
+	if err := binary.Read(br, binary.LittleEndian, b.words); err != nil {
+		return nil, fmt.Errorf("failed to read bits: %w", err)
+	}
+
+	return b, nil
+}
+
+var (
+	bloomM        = new(sync.RWMutex)
+	bloom         *BloomFilter // Used to answer; nil until built or caught up
+	bloomBuilding *BloomFilter // The filter under build; Insert() adds to it too
+	bloomPending  *BloomFilter // The loaded snapshot, used after the first catch-up
+	bloomSince    time.Time    // The names inserted since are not added yet, see bloomCatchUp()
+
+	bloomPositives      atomic.Int64
+	bloomNegatives      atomic.Int64
+	bloomFalsePositives atomic.Int64
+)
+
+// bloomKey returns the key of the domain in p (eg.: example.com).
+func bloomKey(p *dns.Parts) string {
+	return p.Domain + "." + p.TLD
+}
+
+// bloomAdd adds the domain in p to the filters.
+func bloomAdd(p *dns.Parts) {
+	bloomAddKey(bloomKey(p))
+}
+
+// bloomAddKey adds the key k (see bloomKey()) to the current, the building and the pending filter.
+func bloomAddKey(k string) {
+
+	bloomM.RLock()
+	defer bloomM.RUnlock()
+
+	for _, b := range []*BloomFilter{bloom, bloomBuilding, bloomPending} {
+		if b != nil {
+			b.Add(k)
+		}
+	}
+}
+
+// BloomMayContain returns false if domain d (its subdomain is ignored) is definitely not in the "domains" collection.
+// Returns true if the filter is not ready, d is invalid or blocked, so the DB must be queried (or the error returned by the query).
+func BloomMayContain(d string) bool {
+
+	bloomM.RLock()
+	b := bloom
+	bloomM.RUnlock()
+
+	if b == nil {
+		return true
+	}
+
+	d = dns.Clean(d)
+
+	p := GetParts(d)
+	if p == nil || p.Domain == "" || p.TLD == "" || IsBlocked(d) {
+		return true
+	}
+
+	if !b.Test(bloomKey(p)) {
+		bloomNegatives.Add(1)
+		return false
+	}
+
+	bloomPositives.Add(1)
+
+	return true
+}
+
+// BloomFalsePositive records that a domain passed BloomMayContain() but not found in the DB.
+// Does nothing if the filter is not ready.
+func BloomFalsePositive() {
+
+	bloomM.RLock()
+	defer bloomM.RUnlock()
+
+	if bloom != nil {
+		bloomFalsePositives.Add(1)
+	}
+}
+
+// BloomBuild builds a new filter from the "domains" collection and replaces the current one.
+// The names inserted by other processes while building are added by bloomCatchUp().
+func BloomBuild() error {
+
+	start := time.Now()
+
+	b := NewBloomFilter(config.BloomCapacity, config.BloomFPRate)
+
+	bloomM.Lock()
+	bloomBuilding = b
+	bloomM.Unlock()
+
+	defer func() {
+		bloomM.Lock()
+		bloomBuilding = nil
+		bloomM.Unlock()
+	}()
+
+	cursor, err := Domains.Find(context.TODO(), bson.M{}, options.Find().SetProjection(bson.M{"domain": 1, "tld": 1}))
+	if err != nil {
+		return fmt.Errorf("failed to find: %w", err)
+	}
+	defer cursor.Close(context.TODO())
+
+	for cursor.Next(context.TODO()) {
+
+		d := new(FastDomainSchema)
+
+		err = cursor.Decode(d)
+		if err != nil {
+			return fmt.Errorf("failed to decode: %w", err)
+		}
+
+		b.Add(d.Domain + "." + d.TLD)
+	}
+
+	if err := cursor.Err(); err != nil {
+		return fmt.Errorf("cursor failed: %w", err)
+	}
+
+	bloomM.Lock()
+	bloom = b
+	bloomPending = nil
+	bloomSince = start
+	bloomM.Unlock()
+
+	return nil
+}
+
+// bloomCatchUp adds the names inserted since the last build or catch-up to the filters,
+// including the names inserted directly into the DB by other processes (eg.: an other instance or the CT scanner).
+// The names are found by the timestamp in the ObjectID.
+// A loaded snapshot is used to answer after the first catch-up (see BloomLoad()).
+func bloomCatchUp() error {
+
+	bloomM.RLock()
+	since := bloomSince
+	ready := bloom != nil || bloomPending != nil
+	bloomM.RUnlock()
+
+	// Nothing to catch up, the first build adds every name
+	if !ready {
+		return nil
+	}
+
+	start := time.Now()
+
+	filter := bson.M{"_id": bson.M{"$gte": primitive.NewObjectIDFromTimestamp(since.Add(-bloomCatchUpMargin))}}
+
+	cursor, err := Domains.Find(context.TODO(), filter, options.Find().SetProjection(bson.M{"domain": 1, "tld": 1}))
+	if err != nil {
+		return fmt.Errorf("failed to find: %w", err)
+	}
+	defer cursor.Close(context.TODO())
+
+	for cursor.Next(context.TODO()) {
+
+		d := new(FastDomainSchema)
+
+		err = cursor.Decode(d)
+		if err != nil {
+			return fmt.Errorf("failed to decode: %w", err)
+		}
+
+		bloomAddKey(d.Domain + "." + d.TLD)
+	}
+
+	if err := cursor.Err(); err != nil {
+		return fmt.Errorf("cursor failed: %w", err)
+	}
+
+	bloomM.Lock()
+	defer bloomM.Unlock()
+
+	// A build finished meanwhile
+	if !bloomSince.Equal(since) {
+		return nil
+	}
+
+	bloomSince = start
+
+	if bloomPending != nil {
+		bloom = bloomPending
+		bloomPending = nil
+	}
+
+	return nil
+}
+
+// BloomInvalidate drops the current filter and removes the snapshot in config.BloomSnapshot,
+// because the stored names are changed in a way that bloomCatchUp() does not find (eg.: split again, see Resplit()).
+// The DB is queried until the next build.
+func BloomInvalidate() error {
+
+	bloomM.Lock()
+	bloom = nil
+	bloomPending = nil
+	bloomM.Unlock()
+
+	if config.BloomSnapshot == "" {
+		return nil
+	}
+
+	err := os.Remove(config.BloomSnapshot)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove snapshot: %w", err)
+	}
+
+	return nil
+}
+
+// BloomSave writes the current filter to path atomically.
+// The modification time of the file is the time until the names are added, see BloomLoad().
+func BloomSave(path string) error {
+
+	bloomM.RLock()
+	b := bloom
+	since := bloomSince
+	bloomM.RUnlock()
+
+	if b == nil {
+		return fmt.Errorf("filter is not ready")
+	}
+
+	tmp := path + ".tmp"
+
+	file, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", tmp, err)
+	}
+
+	_, err = b.WriteTo(file)
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write: %w", err)
+	}
+
+	err = file.Close()
+	if err != nil {
+		return fmt.Errorf("failed to close %s: %w", tmp, err)
+	}
+
+	err = os.Chtimes(tmp, since, since)
+	if err != nil {
+		return fmt.Errorf("failed to set time of %s: %w", tmp, err)
+	}
+
+	return os.Rename(tmp, path)
+}
+
+// BloomLoad loads the filter from path.
+// The filter is used after the names inserted since the modification time of the file are added (see bloomCatchUp()).
+func BloomLoad(path string) error {
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	b, err := ReadBloomFilter(file)
+	if err != nil {
+		return err
+	}
+
+	bloomM.Lock()
+	bloomPending = b
+	bloomSince = info.ModTime()
+	bloomM.Unlock()
+
+	return nil
+}
+
+// BloomWorker loads the snapshot (if config.BloomSnapshot is set), then rebuilds the filter from the DB every config.BloomRebuild
+// and saves the snapshot after every build.
+// The names inserted by other processes directly into the DB are added in every minute (see bloomCatchUp()),
+// the loaded snapshot is used after the names inserted since the snapshot are added.
+//
+// This function is designed to run as a goroutine in the background.
+// The errors are printed to STDERR.
+func BloomWorker() {
+
+	next := time.Now()
+
+	if config.BloomSnapshot != "" {
+
+		err := BloomLoad(config.BloomSnapshot)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			fmt.Fprintf(os.Stderr, "BloomWorker(): Failed to load snapshot: %s\n", err)
+		}
+
+		// The snapshot is up to date after the catch-up
+		if err == nil {
+			bloomM.RLock()
+			next = bloomSince.Add(config.BloomRebuild)
+			bloomM.RUnlock()
+		}
+	}
+
+	go func() {
+
+		for {
+
+			err := bloomCatchUp()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "BloomWorker(): Failed to catch up: %s\n", err)
+			}
+
+			time.Sleep(bloomCatchUpInterval)
+		}
+	}()
+
+	for {
+
+		time.Sleep(time.Until(next))
+
+		start := time.Now()
+
+		err := BloomBuild()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "BloomWorker(): Failed to build: %s\n", err)
+		} else {
+			fmt.Printf("BloomWorker(): Filter built in %s\n", time.Since(start))
+		}
+
+		if err == nil && config.BloomSnapshot != "" {
+			err = BloomSave(config.BloomSnapshot)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "BloomWorker(): Failed to save snapshot: %s\n", err)
+			}
+		}
+
+		next = time.Now().Add(config.BloomRebuild)
+	}
+}
+
+// BloomStatistics returns the metrics of the filter.
+// ObservedFPRate is the ratio of the false positives to every unknown domain (false positives + negatives).
+func BloomStatistics() BloomStatisticSchema {
+
+	s := BloomStatisticSchema{
+		Positives:      bloomPositives.Load(),
+		Negatives:      bloomNegatives.Load(),
+		FalsePositives: bloomFalsePositives.Load(),
+	}
+
+	if s.FalsePositives+s.Negatives > 0 {
+		s.ObservedFPRate = float64(s.FalsePositives) / float64(s.FalsePositives+s.Negatives)
+	}
+
+	bloomM.RLock()
+	b := bloom
+	bloomM.RUnlock()
+
+	if b == nil {
+		return s
+	}
+
+	s.Ready = true
+	s.EstimatedFPRate = b.EstimatedFPRate()
+
+	b.m.RLock()
+	s.Elements, s.Bits, s.Hashes = b.n, b.bits, b.k
+	b.m.RUnlock()
+
+	return s
+}
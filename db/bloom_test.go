@@ -0,0 +1,49 @@
+package db
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func TestBloomFilter(t *testing.T) {
+
+	b := NewBloomFilter(1000, 0.01)
+
+	for i := 0; i < 1000; i++ {
+		b.Add(fmt.Sprintf("example%d.com", i))
+	}
+
+	for i := 0; i < 1000; i++ {
+		if !b.Test(fmt.Sprintf("example%d.com", i)) {
+			t.Fatalf("FAIL: false negative: example%d.com\n", i)
+		}
+	}
+
+	fp := 0
+
+	for i := 0; i < 10000; i++ {
+		if b.Test(fmt.Sprintf("unknown%d.org", i)) {
+			fp++
+		}
+	}
+
+	if fp > 300 {
+		t.Fatalf("FAIL: too many false positives: %d/10000\n", fp)
+	}
+
+	buf := new(bytes.Buffer)
+
+	if _, err := b.WriteTo(buf); err != nil {
+		t.Fatalf("FAIL: failed to write: %s\n", err)
+	}
+
+	r, err := ReadBloomFilter(buf)
+	if err != nil {
+		t.Fatalf("FAIL: failed to read: %s\n", err)
+	}
+
+	if r.n != b.n || r.k != b.k || !r.Test("example1.com") {
+		t.Fatalf("FAIL: snapshot differs\n")
+	}
+}
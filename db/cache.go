@@ -0,0 +1,287 @@
+package db
+
+import (
+	"container/list"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/elmasy-com/elnet/dns"
+)
+
+// CacheBackend stores the cached results of Lookup(), LookupFull(), TLD() and Records().
+// The values are JSON encoded, so a shared backend (eg.: Redis) can be used by multiple instances.
+// Every key belongs to one or more tags (eg.: the domain), Invalidate() removes every key of the tag.
+type CacheBackend interface {
+	Get(key string) ([]byte, bool)
+	Set(key string, tags []string, v []byte)
+	Invalidate(tag string)
+}
+
+var (
+	cacheBackend       CacheBackend
+	cacheHits          atomic.Int64
+	cacheMisses        atomic.Int64
+	cacheInvalidations atomic.Int64
+
+	// The generations of the tags by the hash of the tag, incremented by cacheInvalidate().
+	// A result queried before the invalidation of any of its tags is not stored, see cacheSet().
+	cacheGens [256]atomic.Uint64
+)
+
+// CacheSetBackend sets the backend of the lookup cache.
+// If b is nil, the cache is disabled.
+//
+// Must be called before the first query.
+func CacheSetBackend(b CacheBackend) {
+	cacheBackend = b
+}
+
+// cacheDomainTag returns the tag of the domain in p (eg.: example.com).
+func cacheDomainTag(p *dns.Parts) string {
+	return p.Domain + "." + p.TLD
+}
+
+// cacheSLDTag returns the tag of the Second Level Domain d (eg.: example), used by TLD().
+func cacheSLDTag(d string) string {
+	return "sld:" + d
+}
+
+// cacheKey returns the key of the query name with the parts of the domain and the filter f.
+// sub is used only if the result depends on the subdomain.
+func cacheKey(name string, sub string, p *dns.Parts, f LookupFilter) string {
+	return fmt.Sprintf("%s|%s|%s|%s|%d|%s|%v|%q|%t", name, sub, p.Domain, p.TLD, f.Days, f.Source, f.Types, f.Value, f.Subtree)
+}
+
+// cacheSub returns the subdomain of p if the result of the lookup depends on it (f.Subtree is true).
+func cacheSub(p *dns.Parts, f LookupFilter) string {
+
+	if f.Subtree {
+		return p.Sub
+	}
+
+	return ""
+}
+
+// cacheGet decodes the value of key into v and returns true if found.
+func cacheGet(key string, v interface{}) bool {
+
+	if cacheBackend == nil {
+		return false
+	}
+
+	out, ok := cacheBackend.Get(key)
+	if !ok || json.Unmarshal(out, v) != nil {
+		cacheMisses.Add(1)
+		return false
+	}
+
+	cacheHits.Add(1)
+
+	return true
+}
+
+// cacheGenIndex returns the index of tag in cacheGens.
+func cacheGenIndex(tag string) int {
+
+	h := fnv.New32a()
+	h.Write([]byte(tag))
+
+	return int(h.Sum32() % uint32(len(cacheGens)))
+}
+
+// cacheGen returns the generation of tags.
+// Must be called before the query of the result stored with cacheSet().
+func cacheGen(tags ...string) uint64 {
+
+	var g uint64
+
+	for i := range tags {
+		g += cacheGens[cacheGenIndex(tags[i])].Load()
+	}
+
+	return g
+}
+
+// cacheSet stores v with key and tags.
+// gen is the generation of tags before the query of v (see cacheGen()),
+// v is not stored if any tag is invalidated since, because v may be queried before the change.
+func cacheSet(key string, v interface{}, gen uint64, tags ...string) {
+
+	if cacheBackend == nil || cacheGen(tags...) != gen {
+		return
+	}
+
+	out, err := json.Marshal(v)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cacheSet(): Failed to marshal %s: %s\n", key, err)
+		return
+	}
+
+	cacheBackend.Set(key, tags, out)
+
+	// Invalidated between the check and the Set(), the Invalidate() of the backend may run before the Set()
+	if cacheGen(tags...) != gen {
+		for i := range tags {
+			cacheBackend.Invalidate(tags[i])
+		}
+	}
+}
+
+// cacheInvalidate removes every key of tags.
+// The generation of the tags is incremented before the keys are removed, see cacheSet().
+func cacheInvalidate(tags ...string) {
+
+	for i := range tags {
+		cacheGens[cacheGenIndex(tags[i])].Add(1)
+	}
+
+	if cacheBackend == nil {
+		return
+	}
+
+	for i := range tags {
+		cacheBackend.Invalidate(tags[i])
+	}
+
+	cacheInvalidations.Add(1)
+}
+
+// CacheStatistics returns the metrics of the lookup cache.
+// Entries and Evictions are set only if the backend is a *MemoryCache.
+func CacheStatistics() CacheStatisticSchema {
+
+	s := CacheStatisticSchema{
+		Enabled:       cacheBackend != nil,
+		Hits:          cacheHits.Load(),
+		Misses:        cacheMisses.Load(),
+		Invalidations: cacheInvalidations.Load(),
+	}
+
+	if m, ok := cacheBackend.(*MemoryCache); ok {
+		s.Entries, s.Evictions = m.Len(), m.evictions.Load()
+	}
+
+	return s
+}
+
+type memoryCacheEntry struct {
+	key     string
+	tags    []string
+	value   []byte
+	expires time.Time
+}
+
+// MemoryCache is an in-process LRU CacheBackend with TTL.
+type MemoryCache struct {
+	m         *sync.Mutex
+	size      int
+	ttl       time.Duration
+	lru       *list.List               // Front is the most recently used
+	entries   map[string]*list.Element // key -> element in lru
+	tags      map[string]map[string]struct{}
+	evictions atomic.Int64
+}
+
+// NewMemoryCache returns a MemoryCache that stores at most size entries for ttl.
+func NewMemoryCache(size int, ttl time.Duration) *MemoryCache {
+
+	return &MemoryCache{
+		m:       new(sync.Mutex),
+		size:    size,
+		ttl:     ttl,
+		lru:     list.New(),
+		entries: make(map[string]*list.Element),
+		tags:    make(map[string]map[string]struct{}),
+	}
+}
+
+// remove removes the element e.
+// Must be called with the lock held.
+func (c *MemoryCache) remove(e *list.Element) {
+
+	entry := e.Value.(*memoryCacheEntry)
+
+	c.lru.Remove(e)
+	delete(c.entries, entry.key)
+
+	for _, t := range entry.tags {
+
+		delete(c.tags[t], entry.key)
+
+		if len(c.tags[t]) == 0 {
+			delete(c.tags, t)
+		}
+	}
+}
+
+func (c *MemoryCache) Get(key string) ([]byte, bool) {
+
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	entry := e.Value.(*memoryCacheEntry)
+
+	if time.Now().After(entry.expires) {
+		c.remove(e)
+		return nil, false
+	}
+
+	c.lru.MoveToFront(e)
+
+	return entry.value, true
+}
+
+func (c *MemoryCache) Set(key string, tags []string, v []byte) {
+
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	if e, ok := c.entries[key]; ok {
+		c.remove(e)
+	}
+
+	c.entries[key] = c.lru.PushFront(&memoryCacheEntry{key: key, tags: tags, value: v, expires: time.Now().Add(c.ttl)})
+
+	for _, t := range tags {
+
+		if c.tags[t] == nil {
+			c.tags[t] = make(map[string]struct{})
+		}
+
+		c.tags[t][key] = struct{}{}
+	}
+
+	for c.lru.Len() > c.size {
+		c.remove(c.lru.Back())
+		c.evictions.Add(1)
+	}
+}
+
+func (c *MemoryCache) Invalidate(tag string) {
+
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	for key := range c.tags[tag] {
+		c.remove(c.entries[key])
+	}
+}
+
+// Len returns the number of entries (including the expired but not yet removed ones).
+func (c *MemoryCache) Len() int {
+
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	return c.lru.Len()
+}
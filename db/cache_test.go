@@ -0,0 +1,65 @@
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMemoryCache(t *testing.T) {
+
+	c := NewMemoryCache(2, time.Minute)
+
+	c.Set("a", []string{"example.com"}, []byte("1"))
+	c.Set("b", []string{"example.org"}, []byte("2"))
+
+	// "a" is the most recently used, "b" must be evicted
+	if _, ok := c.Get("a"); !ok {
+		t.Fatalf("FAIL: a not found\n")
+	}
+
+	c.Set("c", []string{"example.com"}, []byte("3"))
+
+	if _, ok := c.Get("b"); ok {
+		t.Fatalf("FAIL: b is not evicted\n")
+	}
+
+	c.Invalidate("example.com")
+
+	if c.Len() != 0 {
+		t.Fatalf("FAIL: invalidate failed, %d entries left\n", c.Len())
+	}
+
+	c = NewMemoryCache(2, -time.Second)
+
+	c.Set("a", nil, []byte("1"))
+
+	if _, ok := c.Get("a"); ok {
+		t.Fatalf("FAIL: expired entry returned\n")
+	}
+}
+
+func TestCacheSetAfterInvalidate(t *testing.T) {
+
+	defer CacheSetBackend(nil)
+
+	CacheSetBackend(NewMemoryCache(10, time.Minute))
+
+	gen := cacheGen("example.com")
+
+	// Changed while the result was queried
+	cacheInvalidate("example.com")
+
+	cacheSet("stale", []string{"www"}, gen, "example.com")
+
+	var v []string
+
+	if cacheGet("stale", &v) {
+		t.Fatalf("FAIL: stale result is stored: %v\n", v)
+	}
+
+	cacheSet("fresh", []string{"www"}, cacheGen("example.com"), "example.com")
+
+	if !cacheGet("fresh", &v) {
+		t.Fatalf("FAIL: fresh result is not stored\n")
+	}
+}
@@ -8,17 +8,29 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// The name of the database, changed by the tests.
+var databaseName = "columbus"
+
 var (
 	Client *mongo.Client
 
-	Domains    *mongo.Collection // The main collection to store the entries
-	NotFound   *mongo.Collection // Store domains that not found by Lookup
-	TopList    *mongo.Collection // Store and count successful lookups
-	CTLogs     *mongo.Collection // Store informations about CT Logs
-	Statistics *mongo.Collection // Store statistics history
+	Domains        *mongo.Collection // The main collection to store the entries
+	DNSRecords     *mongo.Collection // Store the observations of the DNS records (time-series)
+	NotFound       *mongo.Collection // Store domains that not found by Lookup
+	TopList        *mongo.Collection // Store and count successful lookups
+	TopListBuckets *mongo.Collection // Store the number of successful lookups per day
+	CTLogs         *mongo.Collection // Store informations about CT Logs
+	Statistics     *mongo.Collection // Store statistics history
+
+	TLDStatistics *mongo.Collection // Store the newest per TLD statistic
+	Wordlist      *mongo.Collection // Store the frequency of the subdomain labels
+	Migrations    *mongo.Collection // Store the applied schema migrations and settings
+	Archive       *mongo.Collection // Store the names removed by the retention policy
+	Blocked       *mongo.Collection // Store the blocked domains and patterns
+	BlockedAudit  *mongo.Collection // Store the changes of the blocked entries
 )
 
-// Connect connects to the database using the standard Connection URI.
+// Connect connects to the database using the standard Connection URI and sets MongoStore as the store.
 func Connect(uri string) error {
 
 	var err error
@@ -33,16 +45,28 @@ func Connect(uri string) error {
 		return fmt.Errorf("ping: %w", err)
 	}
 
-	Domains = Client.Database("columbus").Collection("domains")
-	NotFound = Client.Database("columbus").Collection("notFound")
-	TopList = Client.Database("columbus").Collection("topList")
-	CTLogs = Client.Database("columbus").Collection("ctlogs")
-	Statistics = Client.Database("columbus").Collection("statistics")
+	d := Client.Database(databaseName)
+
+	Domains = d.Collection("domains")
+	DNSRecords = d.Collection("records")
+	NotFound = d.Collection("notFound")
+	TopList = d.Collection("topList")
+	TopListBuckets = d.Collection("topListBuckets")
+	CTLogs = d.Collection("ctlogs")
+	Statistics = d.Collection("statistics")
+	TLDStatistics = d.Collection("tldStatistics")
+	Wordlist = d.Collection("wordlist")
+	Migrations = d.Collection("migrations")
+	Archive = d.Collection("archive")
+	Blocked = d.Collection("blocked")
+	BlockedAudit = d.Collection("blockedAudit")
+
+	SetStore(&MongoStore{})
 
 	return nil
 }
 
-// Disconnect gracefully disconnect from the database.
+// Disconnect gracefully disconnect from the database and closes the store.
 func Disconnect() error {
-	return Client.Disconnect(context.Background())
+	return store.Close()
 }
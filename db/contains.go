@@ -0,0 +1,155 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/elmasy-com/columbus-server/fault"
+	"github.com/elmasy-com/slices"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	NgramSize        = 3      // Size of the n-grams stored in the "ngrams" field
+	MaxContainsNames = 100000 // Maximum number of names with the n-grams of the pattern checked by Contains()
+)
+
+var validPatternRegexp = regexp.MustCompile(`^[a-z0-9\-_.*]+$`)
+
+// Ngrams returns the distinct n-grams (NgramSize long substrings) of s.
+// If s is shorter than NgramSize, returns nil.
+func Ngrams(s string) []string {
+
+	var grams []string
+
+	for i := 0; i+NgramSize <= len(s); i++ {
+		grams = slices.AppendUnique(grams, s[i:i+NgramSize])
+	}
+
+	return grams
+}
+
+// ParsePattern parses the glob-style pattern p (eg.: "*-login.*") and returns the regular expression that matches the whole name
+// and the n-grams that every matching name must contain.
+// If p does not contain "*", it is used as a keyword that can be anywhere in the name (eg.: "paypal" -> "*paypal*").
+//
+// If p contains an invalid character or does not contain at least NgramSize long literal part, returns fault.ErrInvalidPattern.
+func ParsePattern(p string) (*regexp.Regexp, []string, error) {
+
+	p = strings.ToLower(p)
+
+	if !validPatternRegexp.MatchString(p) {
+		return nil, nil, fault.ErrInvalidPattern
+	}
+
+	if !strings.Contains(p, "*") {
+		p = "*" + p + "*"
+	}
+
+	literals := strings.Split(p, "*")
+
+	var grams []string
+
+	for i := range literals {
+
+		for _, g := range Ngrams(literals[i]) {
+			grams = slices.AppendUnique(grams, g)
+		}
+
+		literals[i] = regexp.QuoteMeta(literals[i])
+	}
+
+	// The n-grams are required to use the index, without it every document would be scanned
+	if len(grams) == 0 {
+		return nil, nil, fault.ErrInvalidPattern
+	}
+
+	r, err := regexp.Compile("^" + strings.Join(literals, ".*") + "$")
+	if err != nil {
+		return nil, nil, fault.ErrInvalidPattern
+	}
+
+	return r, grams, nil
+}
+
+// Contains query the DB and returns the names that matches the glob-style pattern p (see ParsePattern()).
+// If full is true, the pattern is matched against the full hostname (eg.: www.example.com) and the hostnames are returned,
+// else the pattern is matched against the domain (eg.: example.com) and the domains are returned.
+//
+// If source is set, only the names with the source are matched (see LookupFilter).
+//
+// Returns at most limit names after skipping skip matching names, the names are ordered by the time of the insert.
+//
+// If p is invalid, returns fault.ErrInvalidPattern.
+// If more than MaxContainsNames names have the n-grams of p before limit names found, returns fault.ErrTooBroad.
+func Contains(p string, source string, full bool, limit int, skip int) ([]string, error) {
+
+	r, grams, err := ParsePattern(p)
+	if err != nil {
+		return nil, err
+	}
+
+	// The "ngrams" field contains the n-grams of the full hostname, so it can be used to filter domains too
+	filter := bson.M{"ngrams": bson.M{"$all": grams}}
+
+	if source != "" {
+		filter["sources.name"] = source
+	}
+
+	// The stable order by _id is required to paginate with skip
+	opts := options.Find().SetProjection(bson.M{"domain": 1, "tld": 1, "sub": 1}).SetSort(bson.M{"_id": 1}).SetLimit(MaxContainsNames + 1)
+
+	cursor, err := Domains.Find(context.TODO(), filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find: %w", err)
+	}
+	defer cursor.Close(context.TODO())
+
+	var (
+		names   = make([]string, 0, limit)
+		matched = make(map[string]struct{})
+		checked = 0
+	)
+
+	for cursor.Next(context.TODO()) && len(names) < limit {
+
+		checked++
+
+		if checked > MaxContainsNames {
+			return nil, fault.ErrTooBroad
+		}
+
+		d := new(FastDomainSchema)
+
+		err = cursor.Decode(d)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode: %w", err)
+		}
+
+		name := d.String()
+		if !full {
+			name = d.Domain + "." + d.TLD
+		}
+
+		if _, ok := matched[name]; ok || !r.MatchString(name) || IsBlocked(name) {
+			continue
+		}
+
+		matched[name] = struct{}{}
+
+		if len(matched) <= skip {
+			continue
+		}
+
+		names = append(names, name)
+	}
+
+	if err := cursor.Err(); err != nil {
+		return nil, fmt.Errorf("cursor failed: %w", err)
+	}
+
+	return names, nil
+}
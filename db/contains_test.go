@@ -0,0 +1,36 @@
+package db
+
+import "testing"
+
+func TestParsePattern(t *testing.T) {
+
+	r, grams, err := ParsePattern("*-login.*")
+	if err != nil {
+		t.Fatalf("FAIL: %s\n", err)
+	}
+
+	if len(grams) != 5 {
+		t.Fatalf("FAIL: Invalid n-grams: %#v\n", grams)
+	}
+
+	if !r.MatchString("paypal-login.example.com") || r.MatchString("login.example.com") {
+		t.Fatalf("FAIL: Invalid regexp: %s\n", r)
+	}
+
+	// Keyword without "*" can be anywhere
+	r, _, err = ParsePattern("paypal")
+	if err != nil {
+		t.Fatalf("FAIL: %s\n", err)
+	}
+
+	if !r.MatchString("www.paypal.example.com") {
+		t.Fatalf("FAIL: Invalid regexp: %s\n", r)
+	}
+
+	// Too short literal parts would scan the whole collection
+	for _, p := range []string{"*a*", "ab", "*", "a*bc*d", "pay/pal"} {
+		if _, _, err = ParsePattern(p); err == nil {
+			t.Fatalf("FAIL: %s is accepted\n", p)
+		}
+	}
+}
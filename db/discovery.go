@@ -0,0 +1,323 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/elmasy-com/columbus-server/config"
+	"github.com/elmasy-com/columbus-server/fault"
+	"github.com/elmasy-com/columbus-server/idn"
+	"github.com/elmasy-com/elnet/dns"
+	"github.com/elmasy-com/slices"
+)
+
+// Common hostnames checked by DiscoverCommon().
+// The empty string is the domain itself.
+var commonHostnames = []string{"", "www", "mail", "smtp", "webmail", "api", "app", "m", "blog", "shop", "dev", "vpn", "ns1", "ns2"}
+
+// isIgnoredDNSError returns whether err is a common DNS error that means that the name has no usable record.
+func isIgnoredDNSError(err error) bool {
+
+	return errors.Is(err, dns.ErrName) || errors.Is(err, dns.ErrServerFailure) || os.IsTimeout(err) || errors.Is(err, dns.ErrRefused)
+}
+
+// resolves checks whether d has an A, AAAA or CNAME record.
+// Wildcard records are ignored.
+// Common DNS errors are ignored.
+func resolves(d string) (bool, error) {
+
+	for _, t := range []uint16{dns.TypeA, dns.TypeAAAA, dns.TypeCNAME} {
+
+		wc, err := dns.IsWildcard(d, t)
+		if err != nil {
+			if isIgnoredDNSError(err) {
+				continue
+			}
+			return false, err
+		}
+		if wc {
+			continue
+		}
+
+		var r []string
+
+		switch t {
+		case dns.TypeA:
+			r, err = dns.QueryARetryStr(d)
+		case dns.TypeAAAA:
+			r, err = dns.QueryAAAARetryStr(d)
+		case dns.TypeCNAME:
+			r, err = dns.QueryCNAMERetry(d)
+		}
+
+		if err != nil {
+			if isIgnoredDNSError(err) {
+				continue
+			}
+			return false, err
+		}
+
+		if len(r) > 0 {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// DiscoverCommon resolves the domain d and the common hostnames under d and inserts the ones that resolves.
+// d must be a domain without subdomain (eg.: example.com).
+//
+// Returns the number of inserted names.
+// If d is invalid, returns fault.ErrInvalidDomain.
+func DiscoverCommon(d string) (int, error) {
+
+	if !dns.IsValid(d) {
+		return 0, fault.ErrInvalidDomain
+	}
+
+	d = dns.Clean(d)
+
+	n := 0
+
+	for i := range commonHostnames {
+
+		h := d
+		if commonHostnames[i] != "" {
+			h = commonHostnames[i] + "." + d
+		}
+
+		ok, err := resolves(h)
+		if err != nil {
+			return n, fmt.Errorf("failed to resolve %s: %w", h, err)
+		}
+		if !ok {
+			continue
+		}
+
+		_, err = Insert(h, SourceDiscovery)
+		if err != nil {
+			return n, fmt.Errorf("failed to insert %s: %w", h, err)
+		}
+
+		n++
+	}
+
+	return n, nil
+}
+
+var (
+	BruteForceChan      chan string
+	bruteForceWordlist  []string
+	bruteForceWordlistM sync.RWMutex
+	bruteForceZones     sync.Map // Zones under brute-force, used to prevent running the same zone concurrently
+)
+
+// bruteForceLabels returns the labels used to brute-force.
+// Without config.BruteForceWordlist, the labels are loaded from the generated wordlist at the first use and refreshed by WordlistWorker().
+func bruteForceLabels() ([]string, error) {
+
+	bruteForceWordlistM.RLock()
+	labels := bruteForceWordlist
+	bruteForceWordlistM.RUnlock()
+
+	if len(labels) > 0 || config.BruteForceWordlist != "" {
+		return labels, nil
+	}
+
+	return bruteForceRefreshLabels()
+}
+
+// bruteForceRefreshLabels loads the config.BruteForceWordlistTop labels from the generated wordlist.
+func bruteForceRefreshLabels() ([]string, error) {
+
+	labels, err := WordlistLabels(config.BruteForceWordlistTop)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load wordlist: %w", err)
+	}
+
+	bruteForceWordlistM.Lock()
+	bruteForceWordlist = labels
+	bruteForceWordlistM.Unlock()
+
+	return labels, nil
+}
+
+// BruteForceZone returns the normalized form of zone d used as the key of the zone (eg.: "Example.COM." -> "example.com").
+// Internationalized names are converted to A-label form.
+//
+// NOTE: This function not validate d!
+func BruteForceZone(d string) string {
+
+	if a, err := idn.ToASCII(d); err == nil {
+		d = a
+	}
+
+	return dns.Clean(strings.TrimRight(strings.ToLower(strings.TrimSpace(d)), "."))
+}
+
+// BruteForceLoadWordlist reads the labels from the file in path.
+// Every line is a label, empty lines and lines starting with "#" are ignored.
+//
+// If a line is not a valid label, returns an error.
+func BruteForceLoadWordlist(path string) ([]string, error) {
+
+	out, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	var labels []string
+
+	for i, l := range strings.Split(string(out), "\n") {
+
+		l = strings.TrimSpace(l)
+
+		if l == "" || strings.HasPrefix(l, "#") {
+			continue
+		}
+
+		if !dns.IsValidSLD(l) {
+			return nil, fmt.Errorf("invalid label in line %d: %s", i+1, l)
+		}
+
+		labels = slices.AppendUnique(labels, dns.Clean(l))
+	}
+
+	return labels, nil
+}
+
+// BruteForceResolve resolves the labels under zone d with at most rate names per second and returns the hostnames that resolves.
+// Hostnames with a wildcard record are ignored (see resolves()).
+//
+// This function does not modify the database.
+//
+// Every label is tried, if resolving a hostname fails with an unusual error, the last error is returned with the found hostnames.
+// If d is invalid, returns fault.ErrInvalidDomain.
+func BruteForceResolve(d string, labels []string, rate int) ([]string, error) {
+
+	if !dns.IsValid(d) || GetDomain(d) == "" {
+		return nil, fault.ErrInvalidDomain
+	}
+
+	if rate < 1 {
+		return nil, fmt.Errorf("invalid rate: %d", rate)
+	}
+
+	d = dns.Clean(d)
+
+	var (
+		hosts   []string
+		lastErr error
+		ticker  = time.NewTicker(time.Second / time.Duration(rate))
+	)
+	defer ticker.Stop()
+
+	for i := range labels {
+
+		<-ticker.C
+
+		h := labels[i] + "." + d
+
+		ok, err := resolves(h)
+		if err != nil {
+			lastErr = fmt.Errorf("failed to resolve %s: %w", h, err)
+			continue
+		}
+
+		if ok {
+			hosts = append(hosts, h)
+		}
+	}
+
+	return hosts, lastErr
+}
+
+// BruteForce resolves the labels of the wordlist under zone d and inserts the hostnames that resolves.
+// The records of the inserted hostnames are updated by the records updater.
+//
+// Returns the number of new hostnames.
+// If d is under brute-force, returns fault.ErrNothingToDo.
+// If d is blocked, returns fault.ErrBlocked.
+func BruteForce(d string) (int, error) {
+
+	// The same zone in different forms must not run concurrently
+	d = BruteForceZone(d)
+
+	if IsBlocked(d) {
+		return 0, fault.ErrBlocked
+	}
+
+	if _, running := bruteForceZones.LoadOrStore(d, true); running {
+		return 0, fault.ErrNothingToDo
+	}
+	defer bruteForceZones.Delete(d)
+
+	labels, err := bruteForceLabels()
+	if err != nil {
+		return 0, err
+	}
+	if len(labels) == 0 {
+		return 0, fmt.Errorf("wordlist is empty")
+	}
+
+	hosts, err := BruteForceResolve(d, labels, config.BruteForceRate)
+	if err != nil && len(hosts) == 0 {
+		return 0, err
+	}
+
+	n := 0
+
+	for i := range hosts {
+
+		isNew, err := Insert(hosts[i], SourceBruteForce)
+		if err != nil {
+			return n, fmt.Errorf("failed to insert %s: %w", hosts[i], err)
+		}
+
+		if isNew {
+			n++
+		}
+
+		internalRecordsUpdaterDomainChan <- hosts[i]
+	}
+
+	return n, err
+}
+
+// bruteForceRoutine reads zones from BruteForceChan and brute-force them.
+func bruteForceRoutine() {
+
+	for d := range BruteForceChan {
+
+		start := time.Now()
+
+		n, err := BruteForce(d)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "BruteForce(): Failed to brute-force %s: %s\n", d, err)
+		}
+
+		fmt.Printf("BruteForce(): Found %d new names under %s in %s\n", n, d, time.Since(start))
+	}
+}
+
+// BruteForceUpdater creates BruteForceChan and starts config.BruteForceWorker goroutines in the background to brute-force the zones sent to BruteForceChan with labels.
+// If labels is empty, the labels of the generated wordlist are used (see bruteForceLabels()).
+//
+// BruteForceChan is ready to use when this function returns.
+func BruteForceUpdater(labels []string) {
+
+	bruteForceWordlistM.Lock()
+	bruteForceWordlist = labels
+	bruteForceWordlistM.Unlock()
+
+	BruteForceChan = make(chan string, config.DomainBuffer)
+
+	for i := 0; i < config.BruteForceWorker; i++ {
+		go bruteForceRoutine()
+	}
+}
@@ -0,0 +1,163 @@
+package db
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/elmasy-com/elnet/dns"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// authoritativeStandIn is a minimal authoritative DNS server for testing.
+// Answers A queries for the names in hosts and for every name under the wildcard zones.
+// Every other name is NXDOMAIN.
+type authoritativeStandIn struct {
+	conn     net.PacketConn
+	hosts    map[string][4]byte
+	wildcard []string
+}
+
+func (s *authoritativeStandIn) answer(q dnsmessage.Question) (dnsmessage.RCode, *[4]byte) {
+
+	name := strings.TrimSuffix(strings.ToLower(q.Name.String()), ".")
+
+	ip, ok := s.hosts[name]
+
+	if !ok {
+		for i := range s.wildcard {
+			if strings.HasSuffix(name, "."+s.wildcard[i]) {
+				ip, ok = [4]byte{192, 0, 2, 255}, true
+			}
+		}
+	}
+
+	if !ok {
+		return dnsmessage.RCodeNameError, nil
+	}
+
+	if q.Type != dnsmessage.TypeA {
+		// NODATA
+		return dnsmessage.RCodeSuccess, nil
+	}
+
+	return dnsmessage.RCodeSuccess, &ip
+}
+
+func (s *authoritativeStandIn) serve() {
+
+	buf := make([]byte, 1500)
+
+	for {
+
+		n, addr, err := s.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+
+		var p dnsmessage.Parser
+
+		h, err := p.Start(buf[:n])
+		if err != nil {
+			continue
+		}
+
+		q, err := p.Question()
+		if err != nil {
+			continue
+		}
+
+		rcode, ip := s.answer(q)
+
+		b := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: h.ID, Response: true, Authoritative: true, RecursionDesired: h.RecursionDesired, RCode: rcode})
+		b.EnableCompression()
+		b.StartQuestions()
+		b.Question(q)
+		b.StartAnswers()
+
+		if ip != nil {
+			b.AResource(dnsmessage.ResourceHeader{Name: q.Name, Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET, TTL: 60}, dnsmessage.AResource{A: *ip})
+		}
+
+		out, err := b.Finish()
+		if err != nil {
+			continue
+		}
+
+		s.conn.WriteTo(out, addr)
+	}
+}
+
+func startAuthoritativeStandIn(t *testing.T, hosts map[string][4]byte, wildcard []string) {
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("FAIL: failed to listen: %s\n", err)
+	}
+
+	t.Cleanup(func() { conn.Close() })
+
+	s := &authoritativeStandIn{conn: conn, hosts: hosts, wildcard: wildcard}
+
+	go s.serve()
+
+	host, port, err := net.SplitHostPort(conn.LocalAddr().String())
+	if err != nil {
+		t.Fatalf("FAIL: failed to split address: %s\n", err)
+	}
+
+	dns.UpdateConf([]string{host}, port)
+
+	err = dns.UpdateClient("udp", 2*time.Second)
+	if err != nil {
+		t.Fatalf("FAIL: failed to update DNS client: %s\n", err)
+	}
+}
+
+func TestBruteForceResolve(t *testing.T) {
+
+	startAuthoritativeStandIn(t,
+		map[string][4]byte{
+			"www.example.com":  {192, 0, 2, 1},
+			"mail.example.com": {192, 0, 2, 2},
+		},
+		[]string{"example.org"})
+
+	hosts, err := BruteForceResolve("example.com", []string{"www", "mail", "nonexistent"}, 100)
+	if err != nil {
+		t.Fatalf("FAIL: %s\n", err)
+	}
+
+	if len(hosts) != 2 || hosts[0] != "www.example.com" || hosts[1] != "mail.example.com" {
+		t.Fatalf("FAIL: Invalid hosts: %#v\n", hosts)
+	}
+
+	// Every name resolves in a wildcard zone, nothing should be found
+	hosts, err = BruteForceResolve("example.org", []string{"www", "mail", "nonexistent"}, 100)
+	if err != nil {
+		t.Fatalf("FAIL: %s\n", err)
+	}
+
+	if len(hosts) != 0 {
+		t.Fatalf("FAIL: Wildcard zone returned hosts: %#v\n", hosts)
+	}
+
+	_, err = BruteForceResolve("com", []string{"www"}, 100)
+	if err == nil {
+		t.Fatalf("FAIL: TLD is accepted as zone\n")
+	}
+}
+
+func TestBruteForceZone(t *testing.T) {
+
+	for _, d := range []string{"example.com", "Example.COM", "example.com.", " EXAMPLE.com.. "} {
+		if z := BruteForceZone(d); z != "example.com" {
+			t.Fatalf("FAIL: %q normalized to %q\n", d, z)
+		}
+	}
+
+	if z := BruteForceZone("Bücher.de"); z != "xn--bcher-kva.de" {
+		t.Fatalf("FAIL: IDN normalized to %q\n", z)
+	}
+}
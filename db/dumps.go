@@ -0,0 +1,524 @@
+package db
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/elmasy-com/columbus-server/config"
+	"github.com/elmasy-com/columbus-server/fault"
+	"github.com/elmasy-com/slices"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Types of the dump files.
+const (
+	DumpNames   = "names"   // Gzipped FQDNs of a TLD, one per line
+	DumpRecords = "records" // Gzipped NDJSON export stream of a TLD with the records (see Encoder)
+	DumpDelta   = "delta"   // Gzipped FQDNs first seen on a day (UTC), one per line
+)
+
+const dumpManifestName = "manifest.json"
+
+var (
+	dumpManifest  *DumpManifestSchema
+	dumpManifestM sync.RWMutex
+	dumpRequested = make(chan struct{}, 1) // Requests the regeneration of the dump files, see dumpRequest()
+)
+
+// dumpRequest requests DumpWorker() to regenerate the dump files without waiting config.DumpInterval.
+// Used when a name is blocked to remove it from the published files.
+func dumpRequest() {
+
+	select {
+	case dumpRequested <- struct{}{}:
+	default:
+	}
+}
+
+// dumpWait waits for d or a request from dumpRequest().
+func dumpWait(d time.Duration) {
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+	case <-dumpRequested:
+	}
+}
+
+// dumpWriter writes a dump file to a temporary file and computes the SHA-256 hash of the content.
+type dumpWriter struct {
+	path string
+	tmp  *os.File
+	hash hash.Hash
+	w    io.Writer
+}
+
+func newDumpWriter(path string) (*dumpWriter, error) {
+
+	tmp, err := os.Create(path + ".tmp")
+	if err != nil {
+		return nil, err
+	}
+
+	h := sha256.New()
+
+	return &dumpWriter{path: path, tmp: tmp, hash: h, w: io.MultiWriter(tmp, h)}, nil
+}
+
+// finish closes the temporary file and fills the name, the size and the hash in f.
+// The file is published with publish().
+func (dw *dumpWriter) finish(f DumpFileSchema) (DumpFileSchema, error) {
+
+	info, err := dw.tmp.Stat()
+	if err != nil {
+		dw.abort()
+		return f, err
+	}
+
+	if err = dw.tmp.Close(); err != nil {
+		os.Remove(dw.tmp.Name())
+		return f, err
+	}
+
+	f.Name = filepath.Base(dw.path)
+	f.Size = info.Size()
+	f.SHA256 = hex.EncodeToString(dw.hash.Sum(nil))
+
+	return f, nil
+}
+
+// publish renames the finished temporary file to the final path.
+func (dw *dumpWriter) publish() error {
+	return os.Rename(dw.tmp.Name(), dw.path)
+}
+
+// abort closes and removes the temporary file.
+func (dw *dumpWriter) abort() {
+	dw.tmp.Close()
+	os.Remove(dw.tmp.Name())
+}
+
+// dumpLines is a dumpWriter with a gzipped, line based content.
+type dumpLines struct {
+	*dumpWriter
+	gz *gzip.Writer
+	bw *bufio.Writer
+}
+
+func newDumpLines(path string) (*dumpLines, error) {
+
+	dw, err := newDumpWriter(path)
+	if err != nil {
+		return nil, err
+	}
+
+	gz := gzip.NewWriter(dw.w)
+
+	return &dumpLines{dumpWriter: dw, gz: gz, bw: bufio.NewWriter(gz)}, nil
+}
+
+func (dl *dumpLines) writeLine(s string) error {
+
+	if _, err := dl.bw.WriteString(s); err != nil {
+		return err
+	}
+
+	return dl.bw.WriteByte('\n')
+}
+
+func (dl *dumpLines) finish(f DumpFileSchema) (DumpFileSchema, error) {
+
+	if err := dl.bw.Flush(); err != nil {
+		dl.abort()
+		return f, err
+	}
+
+	if err := dl.gz.Close(); err != nil {
+		dl.abort()
+		return f, err
+	}
+
+	return dl.dumpWriter.finish(f)
+}
+
+// dumpDeltaName returns the name of the delta file of the day starting at day (Unix timestamp).
+func dumpDeltaName(day int64) string {
+	return "delta-" + time.Unix(day, 0).UTC().Format("2006-01-02") + ".txt.gz"
+}
+
+// dumpFirstSeen returns the oldest first seen time of the sources of d, 0 if d has no source.
+func dumpFirstSeen(d *DomainSchema) int64 {
+
+	var first int64
+
+	for i := range d.Sources {
+		if first == 0 || (d.Sources[i].First > 0 && d.Sources[i].First < first) {
+			first = d.Sources[i].First
+		}
+	}
+
+	return first
+}
+
+// dumpTLDs returns the distinct TLDs of the names.
+func dumpTLDs() ([]string, error) {
+
+	if MongoDB() {
+
+		// Use the {tld, domain, sub} index
+		v, err := Domains.Distinct(context.TODO(), "tld", bson.M{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get distinct: %w", err)
+		}
+
+		tlds := make([]string, 0, len(v))
+
+		for i := range v {
+			if s, ok := v[i].(string); ok && s != "" {
+				tlds = append(tlds, s)
+			}
+		}
+
+		return tlds, nil
+	}
+
+	var tlds []string
+
+	err := store.Each(ExportFilter{}, func(d *DomainSchema) error {
+		tlds = slices.AppendUnique(tlds, d.TLD)
+		return nil
+	})
+
+	return tlds, err
+}
+
+// dumpTLD writes the names and the records file of the names with TLD tld to dir and appends the finished writers to finished.
+// The names first seen on a day with a writer in deltas are written to the delta file too.
+func dumpTLD(dir string, tld string, generated int64, deltas map[int64]*dumpLines, finished *[]*dumpWriter) ([]DumpFileSchema, error) {
+
+	names, err := newDumpLines(filepath.Join(dir, tld+".txt.gz"))
+	if err != nil {
+		return nil, err
+	}
+
+	rw, err := newDumpWriter(filepath.Join(dir, tld+".ndjson.gz"))
+	if err != nil {
+		names.abort()
+		return nil, err
+	}
+
+	enc, err := NewEncoder(rw.w, FormatNDJSON)
+	if err != nil {
+		names.abort()
+		rw.abort()
+		return nil, err
+	}
+
+	err = exportDomains(ExportFilter{TLD: tld, Records: true}, func(e *ExportEntrySchema) error {
+
+		fqdn := e.Domain.String()
+
+		if err := names.writeLine(fqdn); err != nil {
+			return err
+		}
+
+		if dl, ok := deltas[topListDay(time.Unix(dumpFirstSeen(e.Domain), 0))]; ok {
+			if err := dl.writeLine(fqdn); err != nil {
+				return err
+			}
+		}
+
+		return enc.Encode(e)
+	})
+
+	if err == nil {
+		err = enc.Close()
+	}
+
+	if err != nil {
+		names.abort()
+		rw.abort()
+		return nil, err
+	}
+
+	nf, err := names.finish(DumpFileSchema{Type: DumpNames, TLD: tld, Generated: generated})
+	if err != nil {
+		rw.abort()
+		return nil, err
+	}
+
+	*finished = append(*finished, names.dumpWriter)
+
+	rf, err := rw.finish(DumpFileSchema{Type: DumpRecords, TLD: tld, Generated: generated})
+	if err != nil {
+		return nil, err
+	}
+
+	*finished = append(*finished, rw)
+
+	return []DumpFileSchema{nf, rf}, nil
+}
+
+// DumpGenerate generates the dump files of every TLD and the missing delta files of the previous config.DumpDeltaDays days to config.DumpDir,
+// writes the manifest and removes the files not in the manifest.
+//
+// The delta file of a day is generated once, after the day ended, and regenerated if an entry is blocked after the generation.
+// The files are written to temporary files first and published together after every file is generated,
+// so the published files are always complete and match the manifest.
+func DumpGenerate() error {
+
+	dir := config.DumpDir
+	now := time.Now()
+	generated := now.Unix()
+
+	m := &DumpManifestSchema{Generated: generated}
+
+	today := topListDay(now)
+	oldest := topListDay(now.AddDate(0, 0, -1*config.DumpDeltaDays))
+
+	deltas := make(map[int64]*dumpLines)
+
+	var finished []*dumpWriter
+
+	abort := func() {
+		for _, dl := range deltas {
+			dl.abort()
+		}
+		for _, dw := range finished {
+			os.Remove(dw.tmp.Name())
+		}
+	}
+
+	blocked := BlockedNewest()
+
+	// Keep the existing delta files in the window that can not contain a name blocked since
+	if prev := DumpManifest(); prev != nil {
+		for _, f := range prev.Files {
+			if f.Type == DumpDelta && f.Date >= oldest && f.Generated >= blocked {
+				m.Files = append(m.Files, f)
+			}
+		}
+	}
+
+	for day := oldest; day < today; day += 86400 {
+
+		exists := false
+
+		for _, f := range m.Files {
+			if f.Type == DumpDelta && f.Date == day {
+				exists = true
+				break
+			}
+		}
+
+		if exists {
+			continue
+		}
+
+		dl, err := newDumpLines(filepath.Join(dir, dumpDeltaName(day)))
+		if err != nil {
+			abort()
+			return fmt.Errorf("failed to create delta file: %w", err)
+		}
+
+		deltas[day] = dl
+	}
+
+	tlds, err := dumpTLDs()
+	if err != nil {
+		abort()
+		return fmt.Errorf("failed to get TLDs: %w", err)
+	}
+
+	sort.Strings(tlds)
+
+	for i := range tlds {
+
+		// The TLD is used in a file name
+		if strings.ContainsAny(tlds[i], "/\\") || strings.HasPrefix(tlds[i], ".") {
+			continue
+		}
+
+		fs, err := dumpTLD(dir, tlds[i], generated, deltas, &finished)
+		if err != nil {
+			abort()
+			return fmt.Errorf("failed to dump %s: %w", tlds[i], err)
+		}
+
+		m.Files = append(m.Files, fs...)
+	}
+
+	for day, dl := range deltas {
+
+		delete(deltas, day)
+
+		f, err := dl.finish(DumpFileSchema{Type: DumpDelta, Date: day, Generated: generated})
+		if err != nil {
+			abort()
+			return fmt.Errorf("failed to write delta file %s: %w", dumpDeltaName(day), err)
+		}
+
+		finished = append(finished, dl.dumpWriter)
+
+		m.Files = append(m.Files, f)
+	}
+
+	for _, dw := range finished {
+		if err := dw.publish(); err != nil {
+			return fmt.Errorf("failed to publish %s: %w", dw.path, err)
+		}
+	}
+
+	sort.Slice(m.Files, func(i, j int) bool { return m.Files[i].Name < m.Files[j].Name })
+
+	out, err := json.Marshal(m)
+	if err != nil {
+		return fmt.Errorf("failed to marshal manifest: %w", err)
+	}
+
+	path := filepath.Join(dir, dumpManifestName)
+
+	if err = os.WriteFile(path+".tmp", out, 0644); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+
+	if err = os.Rename(path+".tmp", path); err != nil {
+		return fmt.Errorf("failed to rename manifest: %w", err)
+	}
+
+	dumpManifestM.Lock()
+	dumpManifest = m
+	dumpManifestM.Unlock()
+
+	return dumpClean(dir, m)
+}
+
+// dumpClean removes the files from dir that are not in m.
+func dumpClean(dir string, m *DumpManifestSchema) error {
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", dir, err)
+	}
+
+	for _, e := range entries {
+
+		if e.IsDir() || e.Name() == dumpManifestName || !strings.HasSuffix(e.Name(), ".gz") {
+			continue
+		}
+
+		found := false
+
+		for i := range m.Files {
+			if m.Files[i].Name == e.Name() {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			if err = os.Remove(filepath.Join(dir, e.Name())); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// DumpLoad loads the manifest from config.DumpDir.
+func DumpLoad() error {
+
+	out, err := os.ReadFile(filepath.Join(config.DumpDir, dumpManifestName))
+	if err != nil {
+		return err
+	}
+
+	m := new(DumpManifestSchema)
+
+	if err = json.Unmarshal(out, m); err != nil {
+		return fmt.Errorf("failed to unmarshal manifest: %w", err)
+	}
+
+	dumpManifestM.Lock()
+	dumpManifest = m
+	dumpManifestM.Unlock()
+
+	return nil
+}
+
+// DumpManifest returns the manifest of the current dump files.
+// Returns nil if no dump is generated yet.
+func DumpManifest() *DumpManifestSchema {
+
+	dumpManifestM.RLock()
+	defer dumpManifestM.RUnlock()
+
+	return dumpManifest
+}
+
+// DumpPath returns the path of the dump file with name name.
+// If name is not in the manifest, returns fault.ErrNotFound.
+func DumpPath(name string) (string, error) {
+
+	m := DumpManifest()
+	if m == nil {
+		return "", fault.ErrNotFound
+	}
+
+	for i := range m.Files {
+		if m.Files[i].Name == name {
+			return filepath.Join(config.DumpDir, name), nil
+		}
+	}
+
+	return "", fault.ErrNotFound
+}
+
+// DumpWorker loads the existing manifest, then generates the dump files every config.DumpInterval.
+// If the loaded manifest is newer than config.DumpInterval, the first generation is delayed.
+// When a name is blocked, the files are regenerated without waiting config.DumpInterval (see BlockedLoad()).
+//
+// This function is designed to run as a goroutine in the background.
+// The errors are printed to STDERR.
+func DumpWorker() {
+
+	err := DumpLoad()
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		fmt.Fprintf(os.Stderr, "DumpWorker(): Failed to load manifest: %s\n", err)
+	}
+
+	if m := DumpManifest(); m != nil {
+		dumpWait(time.Until(time.Unix(m.Generated, 0).Add(config.DumpInterval)))
+	}
+
+	for {
+
+		start := time.Now()
+
+		err := DumpGenerate()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "DumpWorker(): Failed to generate: %s\n", err)
+		} else {
+			fmt.Printf("DumpWorker(): Dump generated in %s\n", time.Since(start))
+		}
+
+		dumpWait(config.DumpInterval)
+	}
+}
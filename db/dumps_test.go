@@ -0,0 +1,63 @@
+package db
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/elmasy-com/columbus-server/config"
+)
+
+func TestDumpGenerate(t *testing.T) {
+
+	s, err := NewBoltStore(filepath.Join(t.TempDir(), "columbus.db"))
+	if err != nil {
+		t.Fatalf("FAIL: %s\n", err)
+	}
+	defer s.Close()
+
+	SetStore(s)
+
+	yesterday := time.Now().AddDate(0, 0, -1).Unix()
+
+	for _, d := range []*DomainSchema{
+		{Sub: "www", Domain: "example", TLD: "com", Sources: []SourceSchema{{Name: SourceCT, First: yesterday, Last: yesterday}}},
+		{Domain: "example", TLD: "org", Sources: []SourceSchema{{Name: SourceCT, First: 1, Last: 1}}},
+	} {
+		if _, err = s.Import(d); err != nil {
+			t.Fatalf("FAIL: failed to import %s: %s\n", d.String(), err)
+		}
+	}
+
+	config.DumpDir = t.TempDir()
+	config.DumpDeltaDays = 3
+
+	if err = DumpGenerate(); err != nil {
+		t.Fatalf("FAIL: %s\n", err)
+	}
+
+	m := DumpManifest()
+
+	// 2 TLDs with names and records + 3 delta files
+	if m == nil || len(m.Files) != 7 {
+		t.Fatalf("FAIL: invalid manifest: %+v\n", m)
+	}
+
+	for _, f := range m.Files {
+
+		info, err := os.Stat(filepath.Join(config.DumpDir, f.Name))
+		if err != nil || info.Size() != f.Size || len(f.SHA256) != 64 {
+			t.Fatalf("FAIL: invalid file %+v: %v\n", f, err)
+		}
+	}
+
+	if _, err = DumpPath("../columbus.db"); err == nil {
+		t.Fatalf("FAIL: DumpPath returned a file not in the manifest\n")
+	}
+
+	// The existing delta files are not generated again
+	if err = DumpGenerate(); err != nil || len(DumpManifest().Files) != 7 {
+		t.Fatalf("FAIL: second generation returned %+v, %v\n", DumpManifest(), err)
+	}
+}
@@ -0,0 +1,798 @@
+package db
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/elmasy-com/columbus-server/fault"
+	"github.com/elmasy-com/columbus-server/idn"
+	"github.com/elmasy-com/elnet/dns"
+	"github.com/elmasy-com/elnet/valid"
+	"github.com/elmasy-com/slices"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Formats of the export stream.
+const (
+	FormatNDJSON = "ndjson" // A JSON encoded ExportEntrySchema in every line
+	FormatBinary = "binary" // BSON encoded ExportEntrySchema documents after the binaryMagic
+)
+
+// Collections of the export stream.
+const (
+	ExportDomains    = "domains"
+	ExportCTLogs     = "ctlogs"
+	ExportTopList    = "topList"
+	ExportStatistics = "statistics"
+)
+
+// ExportCollections is the list of the exportable collections in the order of the export.
+var ExportCollections = []string{ExportDomains, ExportCTLogs, ExportTopList, ExportStatistics}
+
+// binaryMagic is written at the beginning of the uncompressed binary stream to tell apart from NDJSON.
+var binaryMagic = []byte("CLMBS\x01")
+
+// maxBinaryEntry is the maximum size of a BSON document in the binary stream (the MongoDB document size limit).
+const maxBinaryEntry = 16 * 1024 * 1024
+
+// ExportFilter is used to select the exported entries.
+//
+// Domain is the Second Level Domain (eg.: "example") and TLD is the TLD of the names (eg.: "com"), empty string means any.
+// If Since > 0, only the names updated at or after Since (Unix timestamp) and the statistics created at or after Since are exported.
+// If Records is true, the records of the names are exported too.
+//
+// The CT logs are never filtered. The top list is filtered by Domain and TLD.
+// The blocked names (see IsBlocked()) are never exported.
+type ExportFilter struct {
+	Domain  string
+	TLD     string
+	Source  string
+	Since   int64
+	Records bool
+}
+
+// match returns whether the name d match f.
+func (f ExportFilter) match(d *DomainSchema) bool {
+
+	if f.Domain != "" && d.Domain != f.Domain {
+		return false
+	}
+
+	if f.TLD != "" && d.TLD != f.TLD {
+		return false
+	}
+
+	if f.Source != "" {
+
+		found := false
+
+		for i := range d.Sources {
+			if d.Sources[i].Name == f.Source {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return f.Since <= 0 || d.Updated >= f.Since
+}
+
+// Encoder writes the gzip compressed export stream.
+type Encoder struct {
+	gz     *gzip.Writer
+	bw     *bufio.Writer
+	format string
+}
+
+// NewEncoder returns a new Encoder that writes the stream in format to w.
+// If format is invalid, returns an error.
+func NewEncoder(w io.Writer, format string) (*Encoder, error) {
+
+	if format != FormatNDJSON && format != FormatBinary {
+		return nil, fmt.Errorf("invalid format: %s", format)
+	}
+
+	gz := gzip.NewWriter(w)
+	bw := bufio.NewWriter(gz)
+
+	if format == FormatBinary {
+		if _, err := bw.Write(binaryMagic); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Encoder{gz: gz, bw: bw, format: format}, nil
+}
+
+// Encode writes e to the stream.
+func (enc *Encoder) Encode(e *ExportEntrySchema) error {
+
+	if enc.format == FormatBinary {
+
+		out, err := bson.Marshal(e)
+		if err != nil {
+			return fmt.Errorf("failed to marshal: %w", err)
+		}
+
+		_, err = enc.bw.Write(out)
+		return err
+	}
+
+	out, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("failed to marshal: %w", err)
+	}
+
+	if _, err = enc.bw.Write(out); err != nil {
+		return err
+	}
+
+	return enc.bw.WriteByte('\n')
+}
+
+// Close flushes the stream and closes the compression. The underlying writer is not closed.
+func (enc *Encoder) Close() error {
+
+	if err := enc.bw.Flush(); err != nil {
+		return err
+	}
+
+	return enc.gz.Close()
+}
+
+// Decoder reads the gzip compressed export stream.
+// The format of the stream is detected from the beginning of the stream.
+type Decoder struct {
+	gz     *gzip.Reader
+	br     *bufio.Reader
+	format string
+}
+
+// NewDecoder returns a new Decoder that reads the stream from r.
+func NewDecoder(r io.Reader) (*Decoder, error) {
+
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open gzip: %w", err)
+	}
+
+	dec := &Decoder{gz: gz, br: bufio.NewReaderSize(gz, 1024*1024), format: FormatNDJSON}
+
+	magic, err := dec.br.Peek(len(binaryMagic))
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to read: %w", err)
+	}
+
+	if bytes.Equal(magic, binaryMagic) {
+		dec.format = FormatBinary
+		dec.br.Discard(len(binaryMagic))
+	}
+
+	return dec, nil
+}
+
+// Format returns the format of the stream.
+func (dec *Decoder) Format() string {
+	return dec.format
+}
+
+// Decode reads the next entry from the stream into e.
+// Returns io.EOF at the end of the stream.
+func (dec *Decoder) Decode(e *ExportEntrySchema) error {
+
+	*e = ExportEntrySchema{}
+
+	if dec.format == FormatBinary {
+
+		size, err := dec.br.Peek(4)
+		if err != nil {
+			if errors.Is(err, io.EOF) && len(size) == 0 {
+				return io.EOF
+			}
+			return fmt.Errorf("failed to read size: %w", err)
+		}
+
+		l := binary.LittleEndian.Uint32(size)
+		if l < 5 || l > maxBinaryEntry {
+			return fmt.Errorf("invalid document size: %d", l)
+		}
+
+		doc := make([]byte, l)
+
+		if _, err = io.ReadFull(dec.br, doc); err != nil {
+			return fmt.Errorf("failed to read document: %w", err)
+		}
+
+		return bson.Unmarshal(doc, e)
+	}
+
+	for {
+
+		line, err := dec.br.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) == 0 {
+			if err != nil {
+				return err
+			}
+			// Skip empty lines
+			continue
+		}
+
+		if err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+
+		return json.Unmarshal(line, e)
+	}
+}
+
+// Close closes the decompression. The underlying reader is not closed.
+func (dec *Decoder) Close() error {
+	return dec.gz.Close()
+}
+
+// Export writes the entries of the collections (see ExportCollections) that match f to enc.
+// The top list is available only with MongoDB (see MongoDB()), with the embedded store it is skipped.
+//
+// progress is called after every written entry with the number of entries written so far, can be nil.
+// Returns the number of written entries.
+func Export(enc *Encoder, collections []string, f ExportFilter, progress func(n int64)) (int64, error) {
+
+	var n int64
+
+	write := func(e *ExportEntrySchema) error {
+
+		if err := enc.Encode(e); err != nil {
+			return err
+		}
+
+		n++
+
+		if progress != nil {
+			progress(n)
+		}
+
+		return nil
+	}
+
+	for _, c := range collections {
+
+		var err error
+
+		switch c {
+		case ExportDomains:
+			err = exportDomains(f, write)
+		case ExportCTLogs:
+			err = exportCTLogs(write)
+		case ExportTopList:
+			if MongoDB() {
+				err = exportTopList(f, write)
+			}
+		case ExportStatistics:
+			err = exportStatistics(f, write)
+		default:
+			err = fmt.Errorf("unknown collection: %s", c)
+		}
+
+		if err != nil {
+			return n, fmt.Errorf("failed to export %s: %w", c, err)
+		}
+	}
+
+	return n, nil
+}
+
+func exportDomains(f ExportFilter, write func(e *ExportEntrySchema) error) error {
+
+	if f.Records && MongoDB() {
+		return exportDomainsRecords(f, write)
+	}
+
+	return store.Each(f, func(d *DomainSchema) error {
+
+		if IsBlocked(d.String()) {
+			return nil
+		}
+
+		// Names without record are not queried
+		if f.Records && d.LastRecord != 0 {
+
+			p := &dns.Parts{Domain: d.Domain, TLD: d.TLD, Sub: d.Sub}
+
+			rs, err := store.Records(p, LookupFilter{}, true)
+			if err != nil {
+				return fmt.Errorf("failed to get records of %s: %w", d.String(), err)
+			}
+
+			d.Records = rs[d.Sub]
+		}
+
+		return write(&ExportEntrySchema{Collection: ExportDomains, Domain: d})
+	})
+}
+
+// exportDomainsRecords is exportDomains() with the records read from the "records" collection with one sorted aggregation instead of one query per name.
+// The aggregation is in the same order as Store.Each(), so the records are merged into the names while iterating.
+func exportDomainsRecords(f ExportFilter, write func(e *ExportEntrySchema) error) error {
+
+	match := bson.D{}
+
+	if f.Domain != "" {
+		match = append(match, bson.E{Key: "meta.domain", Value: f.Domain})
+	}
+	if f.TLD != "" {
+		match = append(match, bson.E{Key: "meta.tld", Value: f.TLD})
+	}
+
+	// The order of Store.Each()
+	order := []string{"domain", "tld", "sub"}
+
+	if f.Domain == "" && f.TLD != "" {
+		order = []string{"tld", "domain", "sub"}
+	}
+
+	sort := bson.D{}
+
+	for i := range order {
+		sort = append(sort, bson.E{Key: "_id." + order[i], Value: 1})
+	}
+
+	sort = append(sort, bson.E{Key: "_id.type", Value: 1}, bson.E{Key: "_id.value", Value: 1})
+
+	pipeline := bson.A{
+		bson.M{"$match": match},
+		bson.M{"$group": bson.M{"_id": bson.M{"domain": "$meta.domain", "tld": "$meta.tld", "sub": "$meta.sub", "type": "$meta.type", "value": "$meta.value"}, "time": bson.M{"$max": "$time"}}},
+		bson.M{"$sort": sort},
+	}
+
+	cursor, err := DNSRecords.Aggregate(context.TODO(), pipeline, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return fmt.Errorf("failed to aggregate records: %w", err)
+	}
+	defer cursor.Close(context.TODO())
+
+	type record struct {
+		ID struct {
+			Domain string `bson:"domain"`
+			TLD    string `bson:"tld"`
+			Sub    string `bson:"sub"`
+			Type   uint16 `bson:"type"`
+			Value  string `bson:"value"`
+		} `bson:"_id"`
+		Time time.Time `bson:"time"`
+	}
+
+	var next *record
+
+	// advance reads the next record, next is nil at the end
+	advance := func() error {
+
+		if !cursor.Next(context.TODO()) {
+			next = nil
+			return cursor.Err()
+		}
+
+		next = new(record)
+
+		return cursor.Decode(next)
+	}
+
+	// compare compares the name of the next record to d in the order of the iteration
+	compare := func(d *DomainSchema) int {
+
+		for _, k := range order {
+
+			var a, b string
+
+			switch k {
+			case "domain":
+				a, b = next.ID.Domain, d.Domain
+			case "tld":
+				a, b = next.ID.TLD, d.TLD
+			default:
+				a, b = next.ID.Sub, d.Sub
+			}
+
+			if c := strings.Compare(a, b); c != 0 {
+				return c
+			}
+		}
+
+		return 0
+	}
+
+	if err = advance(); err != nil {
+		return fmt.Errorf("failed to read records: %w", err)
+	}
+
+	return store.Each(f, func(d *DomainSchema) error {
+
+		// The records of the names not exported (eg.: filtered by Since)
+		for next != nil && compare(d) < 0 {
+			if err := advance(); err != nil {
+				return fmt.Errorf("failed to read records: %w", err)
+			}
+		}
+
+		for next != nil && compare(d) == 0 {
+
+			d.Records = append(d.Records, RecordSchema{Type: next.ID.Type, Value: next.ID.Value, Time: next.Time.Unix()})
+
+			if err := advance(); err != nil {
+				return fmt.Errorf("failed to read records: %w", err)
+			}
+		}
+
+		if IsBlocked(d.String()) {
+			return nil
+		}
+
+		return write(&ExportEntrySchema{Collection: ExportDomains, Domain: d})
+	})
+}
+
+func exportCTLogs(write func(e *ExportEntrySchema) error) error {
+
+	logs, err := store.CTLogsGets()
+	if err != nil {
+		return err
+	}
+
+	for i := range logs {
+		if err = write(&ExportEntrySchema{Collection: ExportCTLogs, CTLog: &logs[i]}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func exportTopList(f ExportFilter, write func(e *ExportEntrySchema) error) error {
+
+	cursor, err := TopList.Find(context.TODO(), bson.M{}, options.Find().SetSort(bson.D{{Key: "domain", Value: 1}}))
+	if err != nil {
+		return fmt.Errorf("failed to find: %w", err)
+	}
+	defer cursor.Close(context.TODO())
+
+	for cursor.Next(context.TODO()) {
+
+		t := new(TopListSchema)
+
+		err = cursor.Decode(t)
+		if err != nil {
+			return fmt.Errorf("failed to decode: %w", err)
+		}
+
+		p := GetParts(t.Domain)
+		if p == nil || (f.Domain != "" && p.Domain != f.Domain) || (f.TLD != "" && p.TLD != f.TLD) || IsBlocked(t.Domain) {
+			continue
+		}
+
+		if err = write(&ExportEntrySchema{Collection: ExportTopList, TopList: t}); err != nil {
+			return err
+		}
+	}
+
+	return cursor.Err()
+}
+
+func exportStatistics(f ExportFilter, write func(e *ExportEntrySchema) error) error {
+
+	stats, err := store.StatisticsGets()
+	if err != nil {
+		return err
+	}
+
+	// StatisticsGets() returns the newest first, export in chronological order
+	for i := len(stats) - 1; i >= 0; i-- {
+
+		if f.Since > 0 && stats[i].Date < f.Since {
+			continue
+		}
+
+		if err = write(&ExportEntrySchema{Collection: ExportStatistics, Statistic: &stats[i]}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ImportResult is the result of Import().
+// Rejected is the number of invalid entries, Skipped is the number of entries skipped on resume or with a collection not available with the store.
+type ImportResult struct {
+	Total    int64
+	Imported int64
+	Rejected int64
+	Skipped  int64
+}
+
+// Import reads the entries from dec and merges them into the database.
+// The first skip entries are not imported, used to resume an interrupted import (see ImportResult.Total).
+// Importing the same entry again does not change the database, except that the records are inserted as new observations.
+//
+// The names are validated like in Insert(), the invalid entries are counted in ImportResult.Rejected and reported with reject (can be nil).
+// progress is called after every read entry with the current result, can be nil.
+// The Bloom filter snapshot is removed after the import (see BloomInvalidate()).
+func Import(dec *Decoder, skip int64, progress func(r ImportResult), reject func(e *ExportEntrySchema, err error)) (ImportResult, error) {
+
+	var (
+		r     ImportResult
+		dates []int64
+	)
+
+	stats, err := store.StatisticsGets()
+	if err != nil {
+		return r, fmt.Errorf("failed to get statistics: %w", err)
+	}
+
+	for i := range stats {
+		dates = append(dates, stats[i].Date)
+	}
+
+	for {
+
+		e := new(ExportEntrySchema)
+
+		err := dec.Decode(e)
+		if errors.Is(err, io.EOF) {
+
+			// The snapshot of an instance is rebuilt with the imported names
+			if r.Imported > 0 {
+				return r, BloomInvalidate()
+			}
+
+			return r, nil
+		}
+		if err != nil {
+			return r, fmt.Errorf("failed to decode entry %d: %w", r.Total+1, err)
+		}
+
+		r.Total++
+
+		switch {
+		case r.Total <= skip:
+			r.Skipped++
+		case e.Collection == ExportTopList && !MongoDB():
+			r.Skipped++
+		default:
+
+			err = importEntry(e, &dates)
+
+			if errors.As(err, &fault.ColumbusError{}) {
+				r.Rejected++
+				if reject != nil {
+					reject(e, err)
+				}
+			} else if err != nil {
+				return r, fmt.Errorf("failed to import entry %d: %w", r.Total, err)
+			} else {
+				r.Imported++
+			}
+		}
+
+		if progress != nil {
+			progress(r)
+		}
+	}
+}
+
+// importEntry imports e.
+// If e is invalid, returns a fault.ColumbusError.
+func importEntry(e *ExportEntrySchema, dates *[]int64) error {
+
+	switch e.Collection {
+	case ExportDomains:
+
+		if e.Domain == nil {
+			return fault.ErrInvalidBody
+		}
+
+		return importDomain(e.Domain)
+
+	case ExportCTLogs:
+
+		if e.CTLog == nil || e.CTLog.Name == "" {
+			return fault.ErrInvalidBody
+		}
+
+		l, err := store.CTLogsGet(e.CTLog.Name)
+		if err != nil && !errors.Is(err, fault.ErrNotFound) {
+			return err
+		}
+
+		// Never move back the index of a log
+		if l != nil && l.Index >= e.CTLog.Index {
+			return nil
+		}
+
+		return store.CTLogsUpdate(e.CTLog.Name, e.CTLog.Index, e.CTLog.Size)
+
+	case ExportTopList:
+
+		if e.TopList == nil || !valid.Domain(e.TopList.Domain) {
+			return fault.ErrInvalidDomain
+		}
+
+		d := GetDomain(dns.Clean(e.TopList.Domain))
+		if d == "" {
+			return fault.ErrInvalidDomain
+		}
+
+		_, err := TopList.UpdateOne(context.TODO(), bson.M{"domain": d}, bson.M{"$setOnInsert": bson.M{"domain": d}, "$max": bson.M{"count": e.TopList.Count}}, options.Update().SetUpsert(true))
+
+		return err
+
+	case ExportStatistics:
+
+		if e.Statistic == nil || e.Statistic.Date <= 0 {
+			return fault.ErrInvalidBody
+		}
+
+		// The statistics has no unique key, the entries with an existing date are ignored
+		if slices.Contains(*dates, e.Statistic.Date) {
+			return nil
+		}
+
+		if err := store.StatisticsInsert(*e.Statistic); err != nil {
+			return err
+		}
+
+		*dates = append(*dates, e.Statistic.Date)
+
+		return nil
+
+	default:
+		return fault.ErrInvalidBody
+	}
+}
+
+// validRecordType returns whether t is in RecordTypes.
+func validRecordType(t uint16) bool {
+
+	for _, v := range RecordTypes {
+		if v == t {
+			return true
+		}
+	}
+
+	return false
+}
+
+// newRecords returns the records in rs that are not stored for the name with parts p with the same or newer time.
+func newRecords(p *dns.Parts, rs []RecordSchema) ([]RecordSchema, error) {
+
+	if len(rs) == 0 {
+		return nil, nil
+	}
+
+	stored, err := store.Records(p, LookupFilter{}, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get records: %w", err)
+	}
+
+	var r []RecordSchema
+
+	for i := range rs {
+
+		found := false
+
+		for _, s := range stored[p.Sub] {
+			if s.Type == rs[i].Type && s.Value == rs[i].Value && s.Time >= rs[i].Time {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			r = append(r, rs[i])
+		}
+	}
+
+	return r, nil
+}
+
+// importDomain validates d like Insert() and merges into the database with the records.
+// The names without source (eg.: exported before the sources were recorded) get the SourceUnknown source.
+//
+// The import is idempotent, the records already stored with the same or newer time are skipped,
+// so a resumed import does not store the same observations twice.
+func importDomain(d *DomainSchema) error {
+
+	if len(d.Sources) == 0 {
+		d.Sources = []SourceSchema{{Name: SourceUnknown, First: d.Updated, Last: d.Updated}}
+	}
+
+	for i := range d.Sources {
+		if d.Sources[i].Name == "" {
+			return fault.ErrInvalidSource
+		}
+	}
+
+	for i := range d.Records {
+		if !validRecordType(d.Records[i].Type) {
+			return fault.ErrInvalidType
+		}
+	}
+
+	v, err := idn.ToASCII(d.String())
+	if err != nil || !valid.Domain(v) {
+		return fault.ErrInvalidDomain
+	}
+
+	v = dns.Clean(v)
+
+	if IsPublicSuffix(v) {
+		return fault.ErrPublicSuffix
+	}
+
+	p := GetParts(v)
+	if p == nil || p.Domain == "" || p.TLD == "" {
+		return fault.ErrGetPartsFailed
+	}
+
+	if IsBlocked(v) {
+		return fault.ErrBlocked
+	}
+
+	// The validated parts are stored
+	n := &DomainSchema{Domain: p.Domain, TLD: p.TLD, Sub: p.Sub, Updated: d.Updated, Sources: d.Sources}
+
+	if _, err = store.Import(n); err != nil {
+		return err
+	}
+
+	rs, err := newRecords(p, d.Records)
+	if err != nil {
+		return err
+	}
+
+	if len(rs) > 0 {
+		if _, err = store.InsertRecords(p, rs); err != nil {
+			return err
+		}
+	}
+
+	bloomAdd(p)
+	cacheInvalidate(cacheDomainTag(p), cacheSLDTag(p.Domain))
+
+	return nil
+}
+
+// ParseExportCollections parses the comma separated list of collections in s.
+// If s is empty, returns ExportCollections.
+func ParseExportCollections(s string) ([]string, error) {
+
+	if s == "" {
+		return ExportCollections, nil
+	}
+
+	var cs []string
+
+	for _, c := range strings.Split(s, ",") {
+
+		c = strings.TrimSpace(c)
+
+		if !slices.Contains(ExportCollections, c) {
+			return nil, fmt.Errorf("unknown collection: %s", c)
+		}
+
+		cs = slices.AppendUnique(cs, c)
+	}
+
+	return cs, nil
+}
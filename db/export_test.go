@@ -0,0 +1,115 @@
+package db
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/elmasy-com/elnet/dns"
+)
+
+func TestExportImport(t *testing.T) {
+
+	src, err := NewBoltStore(filepath.Join(t.TempDir(), "src.db"))
+	if err != nil {
+		t.Fatalf("FAIL: %s\n", err)
+	}
+	defer src.Close()
+
+	www := &dns.Parts{Sub: "www", Domain: "example", TLD: "com"}
+
+	for _, p := range []*dns.Parts{www, {Domain: "example", TLD: "org"}} {
+		if _, _, err = src.Insert(p, SourceCT); err != nil {
+			t.Fatalf("FAIL: failed to insert %v: %s\n", p, err)
+		}
+	}
+
+	if _, err = src.InsertRecords(www, []RecordSchema{{Type: dns.TypeA, Value: "192.0.2.1", Time: 1}}); err != nil {
+		t.Fatalf("FAIL: failed to insert records: %s\n", err)
+	}
+
+	if err = src.CTLogsUpdate("argon", 10, 20); err != nil {
+		t.Fatalf("FAIL: failed to update ctlog: %s\n", err)
+	}
+
+	for _, format := range []string{FormatNDJSON, FormatBinary} {
+
+		SetStore(src)
+
+		buf := new(bytes.Buffer)
+
+		enc, err := NewEncoder(buf, format)
+		if err != nil {
+			t.Fatalf("FAIL: %s\n", err)
+		}
+
+		n, err := Export(enc, ExportCollections, ExportFilter{TLD: "com", Records: true}, nil)
+		if err != nil || n != 2 {
+			t.Fatalf("FAIL: %s export returned %d, %v\n", format, n, err)
+		}
+
+		if err = enc.Close(); err != nil {
+			t.Fatalf("FAIL: %s\n", err)
+		}
+
+		dst, err := NewBoltStore(filepath.Join(t.TempDir(), format+".db"))
+		if err != nil {
+			t.Fatalf("FAIL: %s\n", err)
+		}
+		defer dst.Close()
+
+		SetStore(dst)
+
+		dec, err := NewDecoder(buf)
+		if err != nil {
+			t.Fatalf("FAIL: %s\n", err)
+		}
+
+		if dec.Format() != format {
+			t.Fatalf("FAIL: detected format %s instead of %s\n", dec.Format(), format)
+		}
+
+		r, err := Import(dec, 1, nil, nil)
+		if err != nil || r.Total != 2 || r.Skipped != 1 || r.Imported != 1 {
+			t.Fatalf("FAIL: %s import returned %+v, %v\n", format, r, err)
+		}
+
+		if _, err = dst.Get(www); err == nil {
+			t.Fatalf("FAIL: %s: skipped name is imported\n", format)
+		}
+
+		if l, err := dst.CTLogsGet("argon"); err != nil || l.Index != 10 {
+			t.Fatalf("FAIL: %s: invalid ctlog: %v, %v\n", format, l, err)
+		}
+	}
+
+	SetStore(src)
+
+	buf := new(bytes.Buffer)
+
+	enc, _ := NewEncoder(buf, FormatNDJSON)
+	enc.Encode(&ExportEntrySchema{Collection: ExportDomains, Domain: &DomainSchema{Domain: "exa mple", TLD: "com", Sources: []SourceSchema{{Name: SourceCT}}}})
+	enc.Encode(&ExportEntrySchema{Collection: ExportDomains, Domain: &DomainSchema{Sub: "www", Domain: "example", TLD: "com", Updated: 5, Sources: []SourceSchema{{Name: SourceUser, First: 1, Last: 2}}}})
+	enc.Encode(&ExportEntrySchema{Collection: ExportDomains, Domain: &DomainSchema{Domain: "example", TLD: "net", Updated: 3}})
+	enc.Close()
+
+	dec, err := NewDecoder(buf)
+	if err != nil {
+		t.Fatalf("FAIL: %s\n", err)
+	}
+
+	r, err := Import(dec, 0, nil, nil)
+	if err != nil || r.Rejected != 1 || r.Imported != 2 {
+		t.Fatalf("FAIL: import returned %+v, %v\n", r, err)
+	}
+
+	d, err := src.Get(www)
+	if err != nil || d.Updated != 5 || len(d.Sources) != 2 {
+		t.Fatalf("FAIL: invalid merged name: %v, %v\n", d, err)
+	}
+
+	d, err = src.Get(&dns.Parts{Domain: "example", TLD: "net"})
+	if err != nil || len(d.Sources) != 1 || d.Sources[0].Name != SourceUnknown {
+		t.Fatalf("FAIL: invalid name without source: %v, %v\n", d, err)
+	}
+}
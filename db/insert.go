@@ -3,86 +3,180 @@ package db
 import (
 	"context"
 	"fmt"
+	"os"
+	"time"
 
 	"github.com/elmasy-com/columbus-server/fault"
+	"github.com/elmasy-com/columbus-server/idn"
 	"github.com/elmasy-com/elnet/dns"
 	"github.com/elmasy-com/elnet/valid"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-// Insert inserts the given domain d to the *domains* database.
+// Sources of the names.
+const (
+	SourceCT           = "ct"          // Certificate Transparency logs
+	SourceUser         = "user"        // Inserted by a user
+	SourceBruteForce   = "bruteforce"  // DNS brute-force
+	SourceDiscovery    = "discovery"   // Active discovery of the common hostnames
+	SourceZoneTransfer = "axfr"        // DNS zone transfer
+	SourcePermutation  = "permutation" // Resolved permutation of the known names
+	SourceUnknown      = "unknown"     // Stored before the sources were recorded
+)
+
+// Insert inserts the given domain d to the *domains* database and updates the source of d.
 // Checks if d is valid, do a Clean() and then splits into sub|domain|tld parts.
+// Internationalized names (eg.: bücher.de) are converted to A-label form (eg.: xn--bcher-kva.de).
+//
+// source is the name of the source of d (eg.: SourceCT).
+// If source is new for d, it is appended to the "sources" field, else the last seen time is updated.
 //
 // Returns true if d is new and inserted into the database.
 // If domain is invalid, returns fault.ErrInvalidDomain.
-// If failed to get parts of d (eg.: d is a TLD), returns ault.ErrGetPartsFailed.
+// If failed to get parts of d, returns fault.ErrGetPartsFailed.
+// If d is a public suffix (eg.: co.uk or a TLD), returns fault.ErrPublicSuffix.
+// If source is empty, returns fault.ErrInvalidSource.
+// Blocked names (see IsBlocked()) are skipped, returns false.
 //
 // NOTE: Use RecordsUpdate() after Insert()!
-func Insert(d string) (bool, error) {
+func Insert(d string, source string) (bool, error) {
+
+	// Internationalized names are stored in A-label form
+	d, err := idn.ToASCII(d)
+	if err != nil {
+		return false, fault.ErrInvalidDomain
+	}
 
 	if !valid.Domain(d) {
 		return false, fault.ErrInvalidDomain
 	}
 
+	if source == "" {
+		return false, fault.ErrInvalidSource
+	}
+
 	d = dns.Clean(d)
 
-	p := dns.GetParts(d)
+	if IsPublicSuffix(d) {
+		return false, fault.ErrPublicSuffix
+	}
+
+	p := GetParts(d)
 	if p == nil || p.Domain == "" || p.TLD == "" {
 		return false, fault.ErrGetPartsFailed
 	}
 
-	doc := bson.D{{Key: "domain", Value: p.Domain}, {Key: "tld", Value: p.TLD}, {Key: "sub", Value: p.Sub}}
+	if IsBlocked(d) {
+		return false, nil
+	}
 
-	// UpdateOne will insert the document with $setOnInsert + upsert or do nothing
-	res, err := Domains.UpdateOne(context.TODO(), doc, bson.M{"$setOnInsert": doc}, options.Update().SetUpsert(true))
+	isNew, newSource, err := store.Insert(p, source)
 	if err != nil {
-		return false, fmt.Errorf("failed to update: %w", err)
+		return false, err
 	}
 
-	return res.UpsertedCount != 0, nil
+	bloomAdd(p)
+
+	// New name or new source, the cached lookups of the domain are outdated
+	if isNew || newSource {
+		cacheInvalidate(cacheDomainTag(p), cacheSLDTag(p.Domain))
+	}
+
+	return isNew, nil
 }
 
-// InsertNotFound inserts the given domain d to the *notFound* database.
+// InsertNotFound inserts the given domain d to the *notFound* database or increase the counter if exists.
+// The time of the first and the last request is stored too.
 // Checks if d is valid, do a Clean() and removes the subdomain from d.
 //
 // Returns true if d is new and inserted into the database.
 // If domain is invalid or failed to remove the subdomain, returns fault.ErrInvalidDomain.
+// With the embedded store (see MongoDB()), nothing is recorded.
 func InsertNotFound(d string) (bool, error) {
 
+	if !MongoDB() {
+		return false, nil
+	}
+
 	if !valid.Domain(d) {
 		return false, fault.ErrInvalidDomain
 	}
 
 	d = dns.Clean(d)
 
-	v := dns.GetDomain(d)
+	v := GetDomain(d)
 	if v == "" {
 		return false, fault.ErrInvalidDomain
 	}
 
 	doc := bson.M{"domain": v}
 
-	// UpdateOne will insert the document with $setOnInsert + upsert or do nothing
-	res, err := NotFound.UpdateOne(context.TODO(), doc, bson.M{"$setOnInsert": doc}, options.Update().SetUpsert(true))
+	now := time.Now().Unix()
 
-	return res.UpsertedCount != 0, err
+	// UpdateOne will insert the document with $setOnInsert + $inc + $set + upsert or update the counter and the last time
+	res, err := NotFound.UpdateOne(context.TODO(), doc, bson.M{"$setOnInsert": bson.M{"domain": v, "first": now}, "$inc": bson.M{"count": 1}, "$set": bson.M{"last": now}}, options.Update().SetUpsert(true))
+	if err != nil {
+		return false, err
+	}
+
+	return res.UpsertedCount != 0, nil
+}
+
+var notFoundChan = make(chan string, 1000)
+
+// InsertNotFoundAsync sends d to the notFound inserter (see NotFoundInserter()) to keep the DB write off the request path.
+// Returns false if the buffer is full and d is dropped.
+func InsertNotFoundAsync(d string) bool {
+
+	// Nothing is recorded with the embedded store, see InsertNotFound()
+	if !MongoDB() {
+		return true
+	}
+
+	select {
+	case notFoundChan <- d:
+		return true
+	default:
+		return false
+	}
+}
+
+// NotFoundInserter inserts the domains sent with InsertNotFoundAsync() with InsertNotFound().
+//
+// This function is designed to run as a goroutine in the background.
+// The errors are printed to STDERR.
+func NotFoundInserter() {
+
+	for d := range notFoundChan {
+
+		_, err := InsertNotFound(d)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "NotFoundInserter(): Failed to insert %s: %s\n", d, err)
+		}
+	}
 }
 
 // InsertTopList inserts the given domain d to the *topList* database or increase the counter if exists.
+// The counter of the current day in the *topListBuckets* database is increased too.
 // Checks if d is valid, do a Clean() and removes the subdomain from d.
 //
 // Returns true if d is new and inserted into the database.
 // If domain is invalid or failed to remove the subdomain, returns fault.ErrInvalidDomain.
+// With the embedded store (see MongoDB()), nothing is recorded.
 func InsertTopList(d string) (bool, error) {
 
+	if !MongoDB() {
+		return false, nil
+	}
+
 	if !valid.Domain(d) {
 		return false, fault.ErrInvalidDomain
 	}
 
 	d = dns.Clean(d)
 
-	v := dns.GetDomain(d)
+	v := GetDomain(d)
 	if v == "" {
 		return false, fault.ErrInvalidDomain
 	}
@@ -91,6 +185,13 @@ func InsertTopList(d string) (bool, error) {
 
 	// UpdateOne will insert the document with $setOnInsert + $inc + upsert or do nothing
 	res, err := TopList.UpdateOne(context.TODO(), doc, bson.M{"$setOnInsert": doc, "$inc": bson.M{"count": 1}}, options.Update().SetUpsert(true))
+	if err != nil {
+		return false, err
+	}
+
+	bucket := bson.D{{Key: "domain", Value: v}, {Key: "date", Value: topListDay(time.Now())}}
+
+	_, err = TopListBuckets.UpdateOne(context.TODO(), bucket, bson.M{"$setOnInsert": bucket, "$inc": bson.M{"count": 1}}, options.Update().SetUpsert(true))
 
 	return res.UpsertedCount != 0, err
 }
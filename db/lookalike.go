@@ -0,0 +1,91 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Lookalikes query the DB and returns the domains from doms that exist in the "domains" collection.
+// Every element in doms must be a domain without subdomain (eg.: example.com).
+//
+// The records of the domain itself (without subdomain) and the first time when any name of the domain was seen are included.
+//
+// The elements of doms that are not domains (eg.: a public suffix or a name with subdomain) are skipped,
+// a generated candidate can be a public suffix (eg.: "co.uk" from "ca.uk").
+func Lookalikes(doms []string) ([]LookalikeSchema, error) {
+
+	if len(doms) == 0 {
+		return nil, nil
+	}
+
+	// Group the domains by TLD to create a smaller filter
+	byTLD := make(map[string][]string)
+
+	for i := range doms {
+
+		p := GetParts(doms[i])
+		if p == nil || p.Domain == "" || p.TLD == "" || p.Sub != "" {
+			continue
+		}
+
+		byTLD[p.TLD] = append(byTLD[p.TLD], p.Domain)
+	}
+
+	if len(byTLD) == 0 {
+		return nil, nil
+	}
+
+	or := make(bson.A, 0, len(byTLD))
+
+	for tld, ds := range byTLD {
+		or = append(or, bson.D{{Key: "domain", Value: bson.M{"$in": ds}}, {Key: "tld", Value: tld}})
+	}
+
+	pipeline := bson.A{
+		bson.M{"$match": bson.M{"$or": or}},
+		bson.M{"$group": bson.M{"_id": bson.M{"domain": "$domain", "tld": "$tld"}, "names": bson.M{"$sum": 1}, "first": bson.M{"$min": bson.M{"$min": "$sources.first"}}}},
+		bson.M{"$project": bson.M{"_id": 0, "domain": bson.M{"$concat": bson.A{"$_id.domain", ".", "$_id.tld"}}, "names": 1, "first": bson.M{"$ifNull": bson.A{"$first", 0}}}},
+		bson.M{"$sort": bson.M{"domain": 1}},
+	}
+
+	cursor, err := Domains.Aggregate(context.TODO(), pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate: %w", err)
+	}
+	defer cursor.Close(context.TODO())
+
+	var ls []LookalikeSchema
+
+	for cursor.Next(context.TODO()) {
+
+		l := new(LookalikeSchema)
+
+		err = cursor.Decode(l)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode: %w", err)
+		}
+
+		// Blocked domains are never returned
+		if IsBlocked(l.Domain) {
+			continue
+		}
+
+		ls = append(ls, *l)
+	}
+
+	if err := cursor.Err(); err != nil {
+		return nil, fmt.Errorf("cursor failed: %w", err)
+	}
+
+	for i := range ls {
+
+		ls[i].Records, err = Records(ls[i].Domain, LookupFilter{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to find records for %s: %w", ls[i].Domain, err)
+		}
+	}
+
+	return ls, nil
+}
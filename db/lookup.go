@@ -1,28 +1,164 @@
 package db
 
 import (
-	"context"
+	"errors"
 	"fmt"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/elmasy-com/columbus-server/fault"
 	"github.com/elmasy-com/elnet/dns"
 	"github.com/elmasy-com/slices"
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// RecordTypes is the record types stored in the "records" collection by name.
+var RecordTypes = map[string]uint16{
+	"A":     dns.TypeA,
+	"AAAA":  dns.TypeAAAA,
+	"CAA":   dns.TypeCAA,
+	"CNAME": dns.TypeCNAME,
+	"DNAME": dns.TypeDNAME,
+	"MX":    dns.TypeMX,
+	"NS":    dns.TypeNS,
+	"SOA":   dns.TypeSOA,
+	"SRV":   dns.TypeSRV,
+	"TXT":   dns.TypeTXT,
+}
+
+// ParseRecordTypes parses the comma separated list of record types in s (eg.: "A,AAAA,CNAME").
+// The type names are case insensitive.
+//
+// If s is empty, returns nil.
+// If any type is unknown, returns fault.ErrInvalidType.
+func ParseRecordTypes(s string) ([]uint16, error) {
+
+	if s == "" {
+		return nil, nil
+	}
+
+	var types []uint16
+
+	for _, n := range strings.Split(s, ",") {
+
+		t, ok := RecordTypes[strings.ToUpper(strings.TrimSpace(n))]
+		if !ok {
+			return nil, fault.ErrInvalidType
+		}
+
+		types = slices.AppendUnique(types, t)
+	}
+
+	return types, nil
+}
+
+// LookupFilter is the filter used in Lookup(), LookupFull() and Records().
+//
+// Days specify, that the returned name must had a valid record in the previous n days.
+// If Days is 0, return every name that has a record regardless of the time.
+// If Days is -1, every name returned, including names that does not have a record.
+// If Source is not empty, returns only the names that found in Source (eg.: SourceCT).
+// If Types is not empty, returns only the names that have a record with any of the types.
+// If Value is not empty, returns only the names that have a record with the exact value.
+// If Subtree is true, the subdomain of the queried name is kept and only the names under it (including itself) are returned
+// (eg.: corp.example.com -> corp.example.com, www.corp.example.com).
+//
+// Days, Types and Value must match the same record.
+type LookupFilter struct {
+	Days    int
+	Source  string
+	Types   []uint16
+	Value   string
+	Subtree bool
+}
+
+// ReverseLabels returns the labels of sub in reversed order (eg.: "www.corp" -> "corp.www").
+// Stored in the "rsub" field, so the names under a subdomain can be found with an indexed prefix match.
+func ReverseLabels(sub string) string {
+
+	labels := strings.Split(sub, ".")
+
+	for i, j := 0, len(labels)-1; i < j; i, j = i+1, j-1 {
+		labels[i], labels[j] = labels[j], labels[i]
+	}
+
+	return strings.Join(labels, ".")
+}
+
+// recordMatch returns whether r matches the Types and Value of f.
+func (f LookupFilter) recordMatch(r RecordSchema) bool {
+
+	if len(f.Types) > 0 && !slices.Contains(f.Types, r.Type) {
+		return false
+	}
+
+	return f.Value == "" || f.Value == r.Value
+}
+
+// match returns whether the name d is a name of the domain in p and matches f.
+// The subdomain of p is used only if f.Subtree is true.
+//
+// Used by the embedded store that filters the names after reading (see lookupFilter() for the query of MongoStore).
+func (f LookupFilter) match(p *dns.Parts, d *DomainSchema) bool {
+
+	if d.Domain != p.Domain || d.TLD != p.TLD {
+		return false
+	}
+
+	if f.Subtree && p.Sub != "" {
+
+		rev := ReverseLabels(p.Sub)
+		rsub := ReverseLabels(d.Sub)
+
+		if rsub != rev && !strings.HasPrefix(rsub, rev+".") {
+			return false
+		}
+	}
+
+	if f.Source != "" {
+
+		found := false
+
+		for i := range d.Sources {
+			if d.Sources[i].Name == f.Source {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	if f.Days <= 0 && len(f.Types) == 0 && f.Value == "" {
+		// Days -1 returns every name, Days 0 returns the names with records
+		return f.Days == -1 || len(d.Records) > 0
+	}
+
+	after := time.Now().AddDate(0, 0, -1*f.Days).Unix()
+
+	// Every condition must match the same record
+	for i := range d.Records {
+		if (f.Days <= 0 || d.Records[i].Time > after) && f.recordMatch(d.Records[i]) {
+			return true
+		}
+	}
+
+	return false
+}
+
 // Lookup validate, Clean() and query the DB and returns a list subdomains only.
-// days specify, that the returned subdomain must had a valid record in the previous n days.
-// If days is 0, return every subdomain that has a record regardless of the time.
-// If days is -1, every subdomain returned, including domains that does not have a record.
+// See LookupFilter for f.
 //
-// If d has a subdomain, removes it before the query.
+// If d has a subdomain, removes it before the query, unless f.Subtree is true.
 //
 // If d is invalid return fault.ErrInvalidDomain.
-// If failed to get parts of d (eg.: d is a TLD), returns fault.ErrGetPartsFailed.
-// If days if < -1, returns fault.ErrInvalidDays.
-func Lookup(d string, days int) ([]string, error) {
+// If failed to get parts of d, returns fault.ErrGetPartsFailed.
+// If d is a public suffix (eg.: co.uk or a TLD), returns fault.ErrPublicSuffix.
+// If f.Days if < -1, returns fault.ErrInvalidDays.
+// If the domain of d (or d with f.Subtree) is blocked, returns fault.ErrBlocked, the blocked names are never returned (see IsBlocked()).
+func Lookup(d string, f LookupFilter) ([]string, error) {
 
 	if !dns.IsValid(d) {
 		return nil, fault.ErrInvalidDomain
@@ -30,65 +166,111 @@ func Lookup(d string, days int) ([]string, error) {
 
 	d = dns.Clean(d)
 
-	p := dns.GetParts(d)
+	if IsPublicSuffix(d) {
+		return nil, fault.ErrPublicSuffix
+	}
+
+	p := GetParts(d)
 	if p == nil || p.Domain == "" || p.TLD == "" {
 		return nil, fault.ErrGetPartsFailed
 	}
 
-	var doc primitive.D
-
-	if days == 0 {
-		// "records" field is exists
-		doc = bson.D{{Key: "domain", Value: p.Domain}, {Key: "tld", Value: p.TLD}, {Key: "records", Value: bson.D{{Key: "$exists", Value: true}}}}
-	} else if days == -1 {
-		// Return every domain, the "records" filed doesnt matter
-		doc = bson.D{{Key: "domain", Value: p.Domain}, {Key: "tld", Value: p.TLD}}
-	} else if days > 0 {
-		// Return every domain that has a record found in the last days days
-		doc = bson.D{{Key: "domain", Value: p.Domain}, {Key: "tld", Value: p.TLD}, {Key: "records.time", Value: bson.D{{Key: "$gt", Value: time.Now().AddDate(0, 0, -1*days).Unix()}}}}
-	} else {
+	if f.Days < -1 {
 		return nil, fault.ErrInvalidDays
 	}
 
-	// Use Find() to find every shard of the domain
-	cursor, err := Domains.Find(context.TODO(), doc)
-	if err != nil {
-		return nil, fmt.Errorf("failed to find: %s", err)
+	if blockedQuery(p, f) {
+		return nil, fault.ErrBlocked
 	}
-	defer cursor.Close(context.TODO())
 
 	var subs []string
 
-	for cursor.Next(context.TODO()) {
+	key := cacheKey("lookup", cacheSub(p, f), p, f)
 
-		r := new(FastDomainSchema)
+	if cacheGet(key, &subs) {
+		return blockedSubs(p, subs), nil
+	}
 
-		err = cursor.Decode(r)
-		if err != nil {
-			return nil, fmt.Errorf("failed to decode: %s", err)
-		}
+	gen := cacheGen(cacheDomainTag(p))
 
-		subs = append(subs, r.Sub)
+	ds, err := store.Find(p, f)
+	if err != nil {
+		return nil, err
 	}
 
-	if err := cursor.Err(); err != nil {
-		return subs, fmt.Errorf("cursor failed: %w", err)
+	for i := range ds {
+		subs = append(subs, ds[i].Sub)
 	}
 
-	return subs, nil
+	cacheSet(key, subs, gen, cacheDomainTag(p))
+
+	return blockedSubs(p, subs), nil
+}
+
+// LookupManyResult is the result of a single domain in LookupMany().
+type LookupManyResult struct {
+	Domain string
+	Subs   []string
+	Err    error
+}
+
+// LookupMany calls Lookup() for every domain in ds concurrently with workers number of goroutines.
+// See LookupFilter for f.
+//
+// The results are in the same order as ds, the errors are returned per domain in LookupManyResult.Err.
+func LookupMany(ds []string, f LookupFilter, workers int) []LookupManyResult {
+
+	results := make([]LookupManyResult, len(ds))
+
+	if workers < 1 {
+		workers = 1
+	}
+
+	indexes := make(chan int)
+	wg := sync.WaitGroup{}
+
+	for i := 0; i < workers; i++ {
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for i := range indexes {
+
+				// Unknown domain, the DB is not queried
+				if !BloomMayContain(ds[i]) {
+					results[i] = LookupManyResult{Domain: ds[i]}
+					continue
+				}
+
+				subs, err := Lookup(ds[i], f)
+				results[i] = LookupManyResult{Domain: ds[i], Subs: subs, Err: err}
+			}
+		}()
+	}
+
+	for i := range ds {
+		indexes <- i
+	}
+
+	close(indexes)
+	wg.Wait()
+
+	return results
 }
 
 // LookupFull validate, Clean() and query the DB and returns a list full domains with subdomain.
-// days specify, that the returned subdomain must had a valid record in the previous n days.
-// If days is 0, return every subdomain that has a record regardless of the time.
-// If days is -1, every subdomain returned, including domains that does not have a record.
+// See LookupFilter for f.
 //
-// If d has a subdomain, removes it before the query.
+// If d has a subdomain, removes it before the query, unless f.Subtree is true.
 //
 // If d is invalid return fault.ErrInvalidDomain.
-// If failed to get parts of d (eg.: d is a TLD), returns ault.ErrGetPartsFailed.
-// If days if < -1, returns fault.ErrInvalidDays.
-func LookupFull(d string, days int) ([]string, error) {
+// If failed to get parts of d, returns fault.ErrGetPartsFailed.
+// If d is a public suffix (eg.: co.uk or a TLD), returns fault.ErrPublicSuffix.
+// If f.Days if < -1, returns fault.ErrInvalidDays.
+// If the domain of d (or d with f.Subtree) is blocked, returns fault.ErrBlocked, the blocked names are never returned (see IsBlocked()).
+func LookupFull(d string, f LookupFilter) ([]string, error) {
 
 	if !dns.IsValid(d) {
 		return nil, fault.ErrInvalidDomain
@@ -96,52 +278,197 @@ func LookupFull(d string, days int) ([]string, error) {
 
 	d = dns.Clean(d)
 
-	p := dns.GetParts(d)
+	if IsPublicSuffix(d) {
+		return nil, fault.ErrPublicSuffix
+	}
+
+	p := GetParts(d)
 	if p == nil || p.Domain == "" || p.TLD == "" {
 		return nil, fault.ErrGetPartsFailed
 	}
 
-	var doc primitive.D
+	if f.Days < -1 {
+		return nil, fault.ErrInvalidDays
+	}
+
+	if blockedQuery(p, f) {
+		return nil, fault.ErrBlocked
+	}
+
+	var doms []string
+
+	key := cacheKey("lookupFull", cacheSub(p, f), p, f)
+
+	if cacheGet(key, &doms) {
+		return blockedNames(doms), nil
+	}
+
+	gen := cacheGen(cacheDomainTag(p))
+
+	ds, err := store.Find(p, f)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range ds {
+		doms = append(doms, ds[i].String())
+	}
+
+	cacheSet(key, doms, gen, cacheDomainTag(p))
+
+	return blockedNames(doms), nil
+}
+
+// LookupDetails validate, Clean() and query the DB and returns the names with metadata.
+// See LookupFilter for f.
+//
+// If d has a subdomain, removes it before the query, unless f.Subtree is true.
+//
+// If d is invalid return fault.ErrInvalidDomain.
+// If failed to get parts of d, returns fault.ErrGetPartsFailed.
+// If d is a public suffix (eg.: co.uk or a TLD), returns fault.ErrPublicSuffix.
+// If f.Days if < -1, returns fault.ErrInvalidDays.
+// If the domain of d (or d with f.Subtree) is blocked, returns fault.ErrBlocked, the blocked names are never returned (see IsBlocked()).
+func LookupDetails(d string, f LookupFilter) ([]LookupDetailSchema, error) {
+
+	if !dns.IsValid(d) {
+		return nil, fault.ErrInvalidDomain
+	}
+
+	d = dns.Clean(d)
+
+	if IsPublicSuffix(d) {
+		return nil, fault.ErrPublicSuffix
+	}
+
+	p := GetParts(d)
+	if p == nil || p.Domain == "" || p.TLD == "" {
+		return nil, fault.ErrGetPartsFailed
+	}
 
-	if days == 0 {
-		// "records" field is exists
-		doc = bson.D{{Key: "domain", Value: p.Domain}, {Key: "tld", Value: p.TLD}, {Key: "records", Value: bson.D{{Key: "$exists", Value: true}}}}
-	} else if days == -1 {
-		// Return every domain, the "records" filed doesnt matter
-		doc = bson.D{{Key: "domain", Value: p.Domain}, {Key: "tld", Value: p.TLD}}
-	} else if days > 0 {
-		// Return every domain that has a record found in the last days days
-		doc = bson.D{{Key: "domain", Value: p.Domain}, {Key: "tld", Value: p.TLD}, {Key: "records.time", Value: bson.D{{Key: "$gt", Value: time.Now().AddDate(0, 0, -1*days).Unix()}}}}
-	} else {
+	if f.Days < -1 {
 		return nil, fault.ErrInvalidDays
 	}
 
-	// Use Find() to find every shard of the domain
-	cursor, err := Domains.Find(context.TODO(), doc)
+	if blockedQuery(p, f) {
+		return nil, fault.ErrBlocked
+	}
+
+	ds, err := store.Find(p, f)
 	if err != nil {
-		return nil, fmt.Errorf("failed to find: %s", err)
+		return nil, err
 	}
-	defer cursor.Close(context.TODO())
 
-	var doms []string
+	var details []LookupDetailSchema
+
+	for i, r := range ds {
+
+		if IsBlocked(r.String()) {
+			continue
+		}
+
+		v := LookupDetailSchema{Sub: r.Sub, FQDN: r.String(), First: dumpFirstSeen(&ds[i]), Sources: make([]string, 0, len(r.Sources)), Updated: r.Updated, HasRecords: r.LastRecord != 0, LastRecordTime: r.LastRecord}
+
+		for j := range r.Sources {
+			v.Sources = append(v.Sources, r.Sources[j].Name)
+		}
+
+		details = append(details, v)
+	}
+
+	return details, nil
+}
 
-	for cursor.Next(context.TODO()) {
+// add adds the name d to v.
+func (v *VersionSchema) add(d *DomainSchema) {
 
-		r := new(FastDomainSchema)
+	v.Names++
 
-		err = cursor.Decode(r)
-		if err != nil {
-			return nil, fmt.Errorf("failed to decode: %s", err)
+	for i := range d.Sources {
+		if d.Sources[i].First > v.First {
+			v.First = d.Sources[i].First
 		}
+	}
 
-		doms = append(doms, r.String())
+	if d.Updated > v.Updated {
+		v.Updated = d.Updated
 	}
 
-	if err := cursor.Err(); err != nil {
-		return nil, fmt.Errorf("cursor failed: %w", err)
+	if d.LastRecord > v.LastRecord {
+		v.LastRecord = d.LastRecord
+	}
+}
+
+// LookupVersion validate, Clean() and query the DB and returns the number and the newest timestamps of the names returned by Lookup() with d and f.
+// It reads the metadata of the names only, so the conditional requests can be answered before the full query.
+//
+// Returns the same errors as Lookup().
+func LookupVersion(d string, f LookupFilter) (VersionSchema, error) {
+
+	if !dns.IsValid(d) {
+		return VersionSchema{}, fault.ErrInvalidDomain
+	}
+
+	d = dns.Clean(d)
+
+	if IsPublicSuffix(d) {
+		return VersionSchema{}, fault.ErrPublicSuffix
+	}
+
+	p := GetParts(d)
+	if p == nil || p.Domain == "" || p.TLD == "" {
+		return VersionSchema{}, fault.ErrGetPartsFailed
 	}
 
-	return doms, nil
+	if f.Days < -1 {
+		return VersionSchema{}, fault.ErrInvalidDays
+	}
+
+	if blockedQuery(p, f) {
+		return VersionSchema{}, fault.ErrBlocked
+	}
+
+	return store.Version(p, f)
+}
+
+// RecordsVersion validate, Clean() and query the DB and returns the newest timestamps of the exact domain d, see Records().
+// If d is not found, returns an empty VersionSchema.
+//
+// Returns the same errors as Records().
+func RecordsVersion(d string) (VersionSchema, error) {
+
+	var v VersionSchema
+
+	if !dns.IsValid(d) {
+		return v, fault.ErrInvalidDomain
+	}
+
+	d = dns.Clean(d)
+
+	if IsPublicSuffix(d) {
+		return v, fault.ErrPublicSuffix
+	}
+
+	p := GetParts(d)
+	if p == nil || p.Domain == "" || p.TLD == "" {
+		return v, fault.ErrGetPartsFailed
+	}
+
+	if blockedQuery(p, LookupFilter{}) {
+		return v, fault.ErrBlocked
+	}
+
+	r, err := store.Get(p)
+	if err != nil {
+		if errors.Is(err, fault.ErrNotFound) {
+			return v, nil
+		}
+		return v, err
+	}
+
+	v.add(r)
+
+	return v, nil
 }
 
 // TLD query the DB and returns a list of TLDs for the given domain d.
@@ -151,32 +478,24 @@ func LookupFull(d string, days int) ([]string, error) {
 // NOTE: This function not validate adn Clean() d!
 func TLD(d string) ([]string, error) {
 
-	// Use Find() to find every shard of the domain
-	cursor, err := Domains.Find(context.TODO(), bson.M{"domain": d})
-	if err != nil {
-		return nil, fmt.Errorf("failed to find: %s", err)
-	}
-	defer cursor.Close(context.TODO())
-
 	var tlds []string
 
-	for cursor.Next(context.TODO()) {
+	key := "tld|" + d
 
-		var r FastDomainSchema
+	if cacheGet(key, &tlds) {
+		return blockedTLDs(d, tlds), nil
+	}
 
-		err = cursor.Decode(&r)
-		if err != nil {
-			return nil, fmt.Errorf("failed to decode: %s", err)
-		}
+	gen := cacheGen(cacheSLDTag(d))
 
-		tlds = slices.AppendUnique(tlds, r.TLD)
+	tlds, err := store.TLDs(d)
+	if err != nil {
+		return nil, err
 	}
 
-	if err := cursor.Err(); err != nil {
-		return nil, fmt.Errorf("cursor failed: %w", err)
-	}
+	cacheSet(key, tlds, gen, cacheSLDTag(d))
 
-	return tlds, nil
+	return blockedTLDs(d, tlds), nil
 }
 
 // Starts query the DB and returns a list of Second Level Domains (eg.: example) that starts with d.
@@ -184,6 +503,8 @@ func TLD(d string) ([]string, error) {
 // Domain d must be a valid Second Level Domain (eg.: "example").
 // This function validate with IsValidSLD() and Clean().
 //
+// The domains blocked in every TLD are not returned.
+//
 // Returns fault.ErrInvalidDomain is d is not a valid Second Level Domain.
 func Starts(d string) ([]string, error) {
 
@@ -191,48 +512,119 @@ func Starts(d string) ([]string, error) {
 		return nil, fault.ErrInvalidDomain
 	}
 
-	d = dns.Clean(d)
+	ds, err := store.Starts(dns.Clean(d))
+	if err != nil {
+		return nil, err
+	}
+
+	return blockedStarts(ds)
+}
 
-	doc := bson.M{"domain": bson.M{"$regex": fmt.Sprintf("^%s", d)}}
+// sourceRecords returns the records in rs of the names that have the source.
+// The records are stored by name without the source, so the names are queried with the source filter.
+// If subtree is true, only the names under the subdomain of p are queried.
+func sourceRecords(p *dns.Parts, source string, subtree bool, rs map[string][]RecordSchema) (map[string][]RecordSchema, error) {
 
-	// Use Find() to find every shard of the domain
-	cursor, err := Domains.Find(context.TODO(), doc)
+	ds, err := store.Find(p, LookupFilter{Days: -1, Source: source, Subtree: subtree})
 	if err != nil {
-		return nil, fmt.Errorf("failed to find: %s", err)
+		return nil, err
 	}
-	defer cursor.Close(context.TODO())
 
-	var domains []string
+	filtered := make(map[string][]RecordSchema, len(ds))
 
-	for cursor.Next(context.TODO()) {
+	for i := range ds {
+		if r, ok := rs[ds[i].Sub]; ok {
+			filtered[ds[i].Sub] = r
+		}
+	}
 
-		var r FastDomainSchema
+	return filtered, nil
+}
 
-		err = cursor.Decode(&r)
-		if err != nil {
-			return nil, fmt.Errorf("failed to decode: %s", err)
-		}
+// Records query the DB and returns a list RecordSchema.
+// Every type and value is returned once with the time of the newest observation.
+// See LookupFilter for f, the returned records must match f.Types and f.Value
+// and must be observed in the previous f.Days days if f.Days > 0 (f.Days -1 is the same as 0).
+// If f.Source is set, the records are returned only if d has the source.
+//
+// Returns records for the exact domain d.
+//
+// If d is invalid return fault.ErrInvalidDomain.
+// If failed to get parts of d, returns fault.ErrGetPartsFailed.
+// If d is a public suffix (eg.: co.uk or a TLD), returns fault.ErrPublicSuffix.
+// If f.Days if < -1, returns fault.ErrInvalidDays.
+// If the domain of d (or d with f.Subtree) is blocked, returns fault.ErrBlocked, the blocked names are never returned (see IsBlocked()).
+func Records(d string, f LookupFilter) ([]RecordSchema, error) {
 
-		domains = slices.AppendUnique(domains, r.Domain)
+	if !dns.IsValid(d) {
+		return nil, fault.ErrInvalidDomain
+	}
+
+	d = dns.Clean(d)
+
+	if IsPublicSuffix(d) {
+		return nil, fault.ErrPublicSuffix
+	}
+
+	p := GetParts(d)
+	if p == nil || p.Domain == "" || p.TLD == "" {
+		return nil, fault.ErrGetPartsFailed
+	}
+
+	if f.Days < -1 {
+		return nil, fault.ErrInvalidDays
+	}
+
+	if blockedQuery(p, f) {
+		return nil, fault.ErrBlocked
+	}
+
+	if f.Days == -1 {
+		f.Days = 0
+	}
+
+	// The exact sub is used
+	f.Subtree = false
+
+	var records = make([]RecordSchema, 0)
+
+	key := cacheKey("records", p.Sub, p, f)
+
+	if cacheGet(key, &records) {
+		return records, nil
+	}
+
+	gen := cacheGen(cacheDomainTag(p))
+
+	rs, err := store.Records(p, f, true)
+	if err != nil {
+		return nil, err
 	}
 
-	if err := cursor.Err(); err != nil {
-		return nil, fmt.Errorf("cursor failed: %w", err)
+	if f.Source != "" {
+		if rs, err = sourceRecords(p, f.Source, true, rs); err != nil {
+			return nil, err
+		}
 	}
 
-	return domains, nil
+	records = append(records, rs[p.Sub]...)
+
+	cacheSet(key, records, gen, cacheDomainTag(p))
+
+	return records, nil
 }
 
-// Records query the DB and returns a list RecordSchema.
-// days specify, that the returned record must be updated in the previous n days.
-// If days is 0 or -1, return every record regardless of the time.
+// RecordsDomain query the DB and returns the records of every name of the domain of d by full hostname (eg.: www.example.com).
+// See Records() for f.
 //
-// Returns records for the exact domain d.
+// If d has a subdomain, removes it before the query.
 //
 // If d is invalid return fault.ErrInvalidDomain.
-// If failed to get parts of d (eg.: d is a TLD), returns ault.ErrGetPartsFailed.
-// If days if < -1, returns fault.ErrInvalidDays.
-func Records(d string, days int) ([]RecordSchema, error) {
+// If failed to get parts of d, returns fault.ErrGetPartsFailed.
+// If d is a public suffix (eg.: co.uk or a TLD), returns fault.ErrPublicSuffix.
+// If f.Days if < -1, returns fault.ErrInvalidDays.
+// If the domain of d (or d with f.Subtree) is blocked, returns fault.ErrBlocked, the blocked names are never returned (see IsBlocked()).
+func RecordsDomain(d string, f LookupFilter) (map[string][]RecordSchema, error) {
 
 	if !dns.IsValid(d) {
 		return nil, fault.ErrInvalidDomain
@@ -240,47 +632,80 @@ func Records(d string, days int) ([]RecordSchema, error) {
 
 	d = dns.Clean(d)
 
-	p := dns.GetParts(d)
+	if IsPublicSuffix(d) {
+		return nil, fault.ErrPublicSuffix
+	}
+
+	p := GetParts(d)
 	if p == nil || p.Domain == "" || p.TLD == "" {
 		return nil, fault.ErrGetPartsFailed
 	}
 
-	var doc primitive.D
-
-	if days == 0 || days == -1 {
-		// "records" field is/must exists
-		doc = bson.D{{Key: "domain", Value: p.Domain}, {Key: "tld", Value: p.TLD}, {Key: "sub", Value: p.Sub}, {Key: "records", Value: bson.D{{Key: "$exists", Value: true}}}}
-	} else if days > 0 {
-		// Return every domain that has a record found in the last days days
-		doc = bson.D{{Key: "domain", Value: p.Domain}, {Key: "tld", Value: p.TLD}, {Key: "sub", Value: p.Sub}, {Key: "records.time", Value: bson.D{{Key: "$gt", Value: time.Now().AddDate(0, 0, -1*days).Unix()}}}}
-	} else {
+	if f.Days < -1 {
 		return nil, fault.ErrInvalidDays
 	}
 
-	// Use Find() to find every shard of the domain
-	cursor, err := Domains.Find(context.TODO(), doc)
+	if blockedQuery(p, f) {
+		return nil, fault.ErrBlocked
+	}
+
+	rs, err := store.Records(p, f, false)
 	if err != nil {
-		return nil, fmt.Errorf("failed to find: %s", err)
+		return nil, err
 	}
-	defer cursor.Close(context.TODO())
 
-	var records = make([]RecordSchema, 0)
+	if f.Source != "" {
+		if rs, err = sourceRecords(p, f.Source, false, rs); err != nil {
+			return nil, err
+		}
+	}
 
-	for cursor.Next(context.TODO()) {
+	records := make(map[string][]RecordSchema, len(rs))
 
-		r := new(DomainSchema)
+	for sub := range rs {
 
-		err = cursor.Decode(r)
-		if err != nil {
-			return nil, fmt.Errorf("failed to decode: %s", err)
+		name := (&FastDomainSchema{Domain: p.Domain, TLD: p.TLD, Sub: sub}).String()
+
+		if !IsBlocked(name) {
+			records[name] = rs[sub]
 		}
+	}
+
+	return records, nil
+}
+
+// Sources query the DB and returns the sources of the exact domain d.
+//
+// If d is invalid return fault.ErrInvalidDomain.
+// If failed to get parts of d, returns fault.ErrGetPartsFailed.
+// If d is a public suffix (eg.: co.uk or a TLD), returns fault.ErrPublicSuffix.
+// If d is not found, returns fault.ErrNotFound.
+// If d is blocked, returns fault.ErrBlocked.
+func Sources(d string) ([]SourceSchema, error) {
 
-		records = append(records, r.Records...)
+	if !dns.IsValid(d) {
+		return nil, fault.ErrInvalidDomain
 	}
 
-	if err := cursor.Err(); err != nil {
-		return nil, fmt.Errorf("cursor failed: %w", err)
+	d = dns.Clean(d)
+
+	if IsPublicSuffix(d) {
+		return nil, fault.ErrPublicSuffix
 	}
 
-	return records, nil
+	p := GetParts(d)
+	if p == nil || p.Domain == "" || p.TLD == "" {
+		return nil, fault.ErrGetPartsFailed
+	}
+
+	if IsBlocked(d) {
+		return nil, fault.ErrBlocked
+	}
+
+	r, err := store.Get(p)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find: %w", err)
+	}
+
+	return r.Sources, nil
 }
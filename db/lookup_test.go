@@ -0,0 +1,151 @@
+package db
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/elmasy-com/columbus-server/fault"
+	"github.com/elmasy-com/elnet/dns"
+	"github.com/elmasy-com/slices"
+)
+
+func TestParseRecordTypes(t *testing.T) {
+
+	types, err := ParseRecordTypes("A, aaaa,CNAME,A")
+	if err != nil {
+		t.Fatalf("FAIL: %s\n", err)
+	}
+
+	if len(types) != 3 || types[0] != dns.TypeA || types[1] != dns.TypeAAAA || types[2] != dns.TypeCNAME {
+		t.Fatalf("FAIL: Invalid types: %#v\n", types)
+	}
+
+	if _, err = ParseRecordTypes("A,PTR"); err == nil {
+		t.Fatalf("FAIL: Unknown type is accepted\n")
+	}
+}
+
+func TestReverseLabels(t *testing.T) {
+
+	for sub, want := range map[string]string{"": "", "corp": "corp", "www.dev.corp": "corp.dev.www"} {
+		if got := ReverseLabels(sub); got != want {
+			t.Fatalf("FAIL: %s: want %s, got %s\n", sub, want, got)
+		}
+	}
+}
+
+func TestLookupDetails(t *testing.T) {
+
+	s, err := NewBoltStore(filepath.Join(t.TempDir(), "columbus.db"))
+	if err != nil {
+		t.Fatalf("FAIL: %s\n", err)
+	}
+	defer s.Close()
+
+	SetStore(s)
+
+	www := &DomainSchema{Sub: "www", Domain: "example", TLD: "com", Updated: 300, Sources: []SourceSchema{{Name: SourceCT, First: 200, Last: 250}, {Name: SourceBruteForce, First: 100, Last: 100}}}
+	apex := &DomainSchema{Domain: "example", TLD: "com", Sources: []SourceSchema{{Name: SourceCT, First: 150, Last: 150}}}
+
+	for _, d := range []*DomainSchema{www, apex} {
+		if _, err = s.Import(d); err != nil {
+			t.Fatalf("FAIL: failed to import %s: %s\n", d.String(), err)
+		}
+	}
+
+	if _, err = s.InsertRecords(&dns.Parts{Sub: "www", Domain: "example", TLD: "com"}, []RecordSchema{{Type: dns.TypeA, Value: "192.0.2.1", Time: 400}}); err != nil {
+		t.Fatalf("FAIL: failed to insert records: %s\n", err)
+	}
+
+	full, err := LookupFull("www.example.com", LookupFilter{Days: -1})
+	if err != nil || len(full) != 2 || !slices.Contains(full, "example.com") || !slices.Contains(full, "www.example.com") {
+		t.Fatalf("FAIL: LookupFull returned %v, %v\n", full, err)
+	}
+
+	details, err := LookupDetails("example.com", LookupFilter{Days: -1})
+	if err != nil || len(details) != 2 {
+		t.Fatalf("FAIL: LookupDetails returned %v, %v\n", details, err)
+	}
+
+	for _, v := range details {
+
+		switch v.FQDN {
+		case "www.example.com":
+			if v.Sub != "www" || v.First != 100 || len(v.Sources) != 2 || v.Updated != 300 || !v.HasRecords || v.LastRecordTime != 400 {
+				t.Fatalf("FAIL: invalid details of www.example.com: %+v\n", v)
+			}
+		case "example.com":
+			if v.Sub != "" || v.First != 150 || len(v.Sources) != 1 || v.Sources[0] != SourceCT || v.HasRecords || v.LastRecordTime != 0 {
+				t.Fatalf("FAIL: invalid details of example.com: %+v\n", v)
+			}
+		default:
+			t.Fatalf("FAIL: unknown name: %+v\n", v)
+		}
+	}
+}
+
+func TestLookupMany(t *testing.T) {
+
+	s, err := NewBoltStore(filepath.Join(t.TempDir(), "columbus.db"))
+	if err != nil {
+		t.Fatalf("FAIL: %s\n", err)
+	}
+	defer s.Close()
+
+	SetStore(s)
+
+	// Other tests must not see the blocked entries
+	defer func() {
+		blockedDomains = make(map[string]struct{})
+		blockedPatterns = nil
+	}()
+
+	for _, p := range []*dns.Parts{{Sub: "www", Domain: "example", TLD: "com"}, {Sub: "mail", Domain: "example", TLD: "net"}, {Sub: "www", Domain: "example", TLD: "org"}} {
+		if _, _, err = s.Insert(p, SourceCT); err != nil {
+			t.Fatalf("FAIL: %s\n", err)
+		}
+	}
+
+	if _, err = Block("example.org", "court order", "admin"); err != nil {
+		t.Fatalf("FAIL: failed to block: %s\n", err)
+	}
+
+	ds := []string{"example.com", "invalid..domain", "co.uk", "example.org", "example.net", "unknown.com"}
+
+	rs := LookupMany(ds, LookupFilter{Days: -1}, 3)
+
+	if len(rs) != len(ds) {
+		t.Fatalf("FAIL: %d results for %d domains\n", len(rs), len(ds))
+	}
+
+	for i := range rs {
+		if rs[i].Domain != ds[i] {
+			t.Fatalf("FAIL: result %d is %s, want %s\n", i, rs[i].Domain, ds[i])
+		}
+	}
+
+	if rs[0].Err != nil || len(rs[0].Subs) != 1 || rs[0].Subs[0] != "www" {
+		t.Fatalf("FAIL: example.com returned %v, %v\n", rs[0].Subs, rs[0].Err)
+	}
+
+	if !errors.Is(rs[1].Err, fault.ErrInvalidDomain) {
+		t.Fatalf("FAIL: invalid domain returned %v\n", rs[1].Err)
+	}
+
+	if !errors.Is(rs[2].Err, fault.ErrPublicSuffix) {
+		t.Fatalf("FAIL: public suffix returned %v\n", rs[2].Err)
+	}
+
+	if !errors.Is(rs[3].Err, fault.ErrBlocked) {
+		t.Fatalf("FAIL: blocked domain returned %v\n", rs[3].Err)
+	}
+
+	if rs[4].Err != nil || len(rs[4].Subs) != 1 || rs[4].Subs[0] != "mail" {
+		t.Fatalf("FAIL: example.net returned %v, %v\n", rs[4].Subs, rs[4].Err)
+	}
+
+	if rs[5].Err != nil || len(rs[5].Subs) != 0 {
+		t.Fatalf("FAIL: unknown domain returned %v, %v\n", rs[5].Subs, rs[5].Err)
+	}
+}
@@ -0,0 +1,603 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/elmasy-com/elnet/dns"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// migration is a versioned step of the database schema.
+// Every step must be idempotent, so a partially applied step can be run again.
+type migration struct {
+	Version     int
+	Description string
+	Up          func() error
+}
+
+// The migrations in ascending order of Version.
+// New steps must be appended with a greater Version, the existing steps must not be modified.
+var migrations = []migration{
+	{1, "remove the duplicated names from domains", migrateRemoveDuplicates},
+	{2, "create the unique {domain, tld, sub} index on domains", func() error {
+
+		// The names inserted since the previous step
+		if err := migrateRemoveDuplicates(); err != nil {
+			return err
+		}
+
+		return createIndex(Domains, bson.D{{Key: "domain", Value: 1}, {Key: "tld", Value: 1}, {Key: "sub", Value: 1}}, true)
+	}},
+	{3, "create the records.time index on domains", func() error {
+		return createIndex(Domains, bson.D{{Key: "records.time", Value: 1}}, false)
+	}},
+	{4, "create the unique name index on ctlogs", func() error {
+
+		if err := dedupe(CTLogs, []string{"name"}, map[string]string{"index": "$max", "size": "$max"}); err != nil {
+			return err
+		}
+
+		return createIndex(CTLogs, bson.D{{Key: "name", Value: 1}}, true)
+	}},
+	{5, "create the indexes of notFound, topList and topListBuckets", func() error {
+
+		if err := dedupe(NotFound, []string{"domain"}, map[string]string{"count": "$sum", "first": "$min", "last": "$max", "checked": "$max"}); err != nil {
+			return err
+		}
+
+		if err := createIndex(NotFound, bson.D{{Key: "domain", Value: 1}}, true); err != nil {
+			return err
+		}
+
+		if err := dedupe(TopList, []string{"domain"}, map[string]string{"count": "$sum"}); err != nil {
+			return err
+		}
+
+		if err := createIndex(TopList, bson.D{{Key: "domain", Value: 1}}, true); err != nil {
+			return err
+		}
+
+		if err := dedupe(TopListBuckets, []string{"domain", "date"}, map[string]string{"count": "$sum"}); err != nil {
+			return err
+		}
+
+		return createIndex(TopListBuckets, bson.D{{Key: "domain", Value: 1}, {Key: "date", Value: 1}}, true)
+	}},
+	{6, "rename the Updated field to updated in statistics", func() error {
+		// StatisticSchema had a `bsn:"updated"` tag, the driver stores untagged fields lowercased,
+		// but documents written by other tools may have the field name as is.
+		_, err := Statistics.UpdateMany(context.TODO(), bson.M{"Updated": bson.M{"$exists": true}}, bson.M{"$rename": bson.M{"Updated": "updated"}})
+		return err
+	}},
+	// The ngrams and rsub fields are set by BackfillWorker() in the background, the backfill of a large collection blocks the startup for hours
+	{7, "create the ngrams index on domains", func() error {
+		return createIndex(Domains, bson.D{{Key: "ngrams", Value: 1}}, false)
+	}},
+	{8, "create the {domain, tld, rsub} index on domains", func() error {
+		return createIndex(Domains, bson.D{{Key: "domain", Value: 1}, {Key: "tld", Value: 1}, {Key: "rsub", Value: 1}}, false)
+	}},
+	{9, "create the records time-series collection", migrateCreateRecords},
+	{10, "move the embedded records from domains to the records collection", migrateMoveRecords},
+	{11, "replace the records.time index with the {domain, tld, lastRecord} index on domains", func() error {
+
+		if err := createIndex(Domains, bson.D{{Key: "domain", Value: 1}, {Key: "tld", Value: 1}, {Key: "lastRecord", Value: 1}}, false); err != nil {
+			return err
+		}
+
+		_, err := Domains.Indexes().DropOne(context.TODO(), "records.time_1")
+		if err != nil && !isIndexNotFound(err) {
+			return fmt.Errorf("failed to drop records.time index: %w", err)
+		}
+
+		return nil
+	}},
+	{12, "create the {tld, domain, sub} index on domains", func() error {
+		return createIndex(Domains, bson.D{{Key: "tld", Value: 1}, {Key: "domain", Value: 1}, {Key: "sub", Value: 1}}, false)
+	}},
+	{13, "create the blocked and blockedAudit indexes", func() error {
+
+		if err := dedupe(Blocked, []string{"pattern"}, nil); err != nil {
+			return err
+		}
+
+		if err := createIndex(Blocked, bson.D{{Key: "pattern", Value: 1}}, true); err != nil {
+			return err
+		}
+
+		return createIndex(BlockedAudit, bson.D{{Key: "time", Value: -1}}, false)
+	}},
+	{14, "create the {date, domain} index on topListBuckets", func() error {
+		return createIndex(TopListBuckets, bson.D{{Key: "date", Value: 1}, {Key: "domain", Value: 1}}, false)
+	}},
+	{15, "set the unknown source of the names stored before the sources were recorded", migrateDefaultSource},
+	{16, "create the {tld, count, label} index on wordlist", func() error {
+		// $out in WordlistInsert() keeps the indexes of the replaced collection
+		return createIndex(Wordlist, bson.D{{Key: "tld", Value: 1}, {Key: "count", Value: -1}, {Key: "label", Value: 1}}, false)
+	}},
+	{17, "create the {meta.tld, meta.domain, meta.sub} index on records", func() error {
+		// Used by the export of the records of a TLD (eg.: the dumps)
+		return createIndex(DNSRecords, bson.D{{Key: "meta.tld", Value: 1}, {Key: "meta.domain", Value: 1}, {Key: "meta.sub", Value: 1}}, false)
+	}},
+}
+
+// isIndexNotFound returns whether err is an IndexNotFound server error.
+func isIndexNotFound(err error) bool {
+
+	var cmdErr mongo.CommandError
+
+	return errors.As(err, &cmdErr) && cmdErr.Code == 27
+}
+
+// migrateDefaultSource sets the SourceUnknown source for the names without "sources", so the source filter can be used for every name.
+// The first and last seen times are the time of the last update of the name.
+func migrateDefaultSource() error {
+
+	filter := bson.M{"$or": bson.A{bson.M{"sources": bson.M{"$exists": false}}, bson.M{"sources": bson.M{"$size": 0}}}}
+
+	// Pipeline-style update to use the "updated" field of the document
+	updated := bson.M{"$ifNull": bson.A{"$updated", 0}}
+
+	up := bson.A{bson.M{"$set": bson.M{"sources": bson.A{bson.M{"name": SourceUnknown, "first": updated, "last": updated}}}}}
+
+	_, err := Domains.UpdateMany(context.TODO(), filter, up)
+	if err != nil {
+		return fmt.Errorf("failed to update: %w", err)
+	}
+
+	return nil
+}
+
+// collectionInfo is the description of a collection returned by listCollections.
+// Type is "collection", "timeseries" or "view", ExpireAfterSeconds is set if the collection has a TTL.
+type collectionInfo struct {
+	Type    string `bson:"type"`
+	Options struct {
+		ExpireAfterSeconds *int64 `bson:"expireAfterSeconds"`
+	} `bson:"options"`
+}
+
+// collectionGetInfo returns the description of collection c.
+// Returns nil if c does not exist.
+func collectionGetInfo(c *mongo.Collection) (*collectionInfo, error) {
+
+	cursor, err := c.Database().ListCollections(context.TODO(), bson.M{"name": c.Name()})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list collections: %w", err)
+	}
+
+	var colls []collectionInfo
+
+	err = cursor.All(context.TODO(), &colls)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode collections: %w", err)
+	}
+
+	if len(colls) == 0 {
+		return nil, nil
+	}
+
+	return &colls[0], nil
+}
+
+// collectionType returns the type of collection c ("collection", "timeseries" or "view").
+// Returns an empty string if c does not exist.
+func collectionType(c *mongo.Collection) (string, error) {
+
+	info, err := collectionGetInfo(c)
+	if err != nil || info == nil {
+		return "", err
+	}
+
+	return info.Type, nil
+}
+
+// migrateCreateRecords creates the "records" time-series collection if not exists and the index used by the queries of a name.
+// If a "records" collection exists that is not a time-series collection (eg.: created by an other tool), returns an error,
+// the collection must be renamed or dropped before the migration.
+//
+// Time-series collections requires MongoDB 5.0 or newer.
+func migrateCreateRecords() error {
+
+	typ, err := collectionType(DNSRecords)
+	if err != nil {
+		return err
+	}
+
+	switch typ {
+	case "":
+
+		opts := options.CreateCollection().SetTimeSeriesOptions(options.TimeSeries().SetTimeField("time").SetMetaField("meta").SetGranularity("hours"))
+
+		err = DNSRecords.Database().CreateCollection(context.TODO(), DNSRecords.Name(), opts)
+		if err != nil {
+			return fmt.Errorf("failed to create collection: %w", err)
+		}
+
+	case "timeseries":
+	default:
+		return fmt.Errorf("%s exists and it is a %s, not a timeseries collection: rename or drop it", DNSRecords.Name(), typ)
+	}
+
+	return createIndex(DNSRecords, bson.D{{Key: "meta.domain", Value: 1}, {Key: "meta.tld", Value: 1}, {Key: "meta.sub", Value: 1}, {Key: "time", Value: -1}}, false)
+}
+
+// migrateMoveRecords inserts the elements of the embedded "records" arrays into the "records" collection as observations,
+// sets the "lastRecord" field and removes the array from the "domains" documents.
+//
+// If the migration is interrupted, the observations of the last document may be inserted twice,
+// the duplicates are merged by the queries (see Store.Records()).
+func migrateMoveRecords() error {
+
+	cursor, err := Domains.Find(context.TODO(), bson.M{"records": bson.M{"$exists": true}}, options.Find().SetProjection(bson.M{"domain": 1, "tld": 1, "sub": 1, "records": 1}))
+	if err != nil {
+		return fmt.Errorf("failed to find: %w", err)
+	}
+	defer cursor.Close(context.TODO())
+
+	for cursor.Next(context.TODO()) {
+
+		d := new(DomainSchema)
+
+		err = cursor.Decode(d)
+		if err != nil {
+			return fmt.Errorf("failed to decode: %w", err)
+		}
+
+		p := &dns.Parts{Domain: d.Domain, TLD: d.TLD, Sub: d.Sub}
+
+		// Sets the lastRecord too
+		_, err = MongoStore{}.InsertRecords(p, d.Records)
+		if err != nil {
+			return fmt.Errorf("failed to move records of %s: %w", d.String(), err)
+		}
+
+		filter := bson.D{{Key: "domain", Value: d.Domain}, {Key: "tld", Value: d.TLD}, {Key: "sub", Value: d.Sub}}
+
+		_, err = Domains.UpdateOne(context.TODO(), filter, bson.M{"$unset": bson.M{"records": ""}})
+		if err != nil {
+			return fmt.Errorf("failed to unset records of %s: %w", d.String(), err)
+		}
+	}
+
+	return cursor.Err()
+}
+
+// createIndex creates the index with keys on collection c.
+func createIndex(c *mongo.Collection, keys bson.D, unique bool) error {
+
+	_, err := c.Indexes().CreateOne(context.TODO(), mongo.IndexModel{Keys: keys, Options: options.Index().SetUnique(unique)})
+	if err != nil {
+		return fmt.Errorf("failed to create index on %s: %w", c.Name(), err)
+	}
+
+	return nil
+}
+
+// dedupe merges the documents of collection c with the same keys into the oldest one (the smallest _id) and removes the others,
+// so the unique index on keys can be created.
+// merge is the accumulator of the merged fields by field name (eg.: "count": "$sum"), the other fields of the oldest document are kept.
+func dedupe(c *mongo.Collection, keys []string, merge map[string]string) error {
+
+	id := bson.M{}
+
+	for i := range keys {
+		id[keys[i]] = "$" + keys[i]
+	}
+
+	group := bson.M{"_id": id, "ids": bson.M{"$push": "$_id"}, "n": bson.M{"$sum": 1}}
+
+	for field, acc := range merge {
+		group[field] = bson.M{acc: "$" + field}
+	}
+
+	pipeline := bson.A{
+		// The oldest document is the first in "ids"
+		bson.M{"$sort": bson.M{"_id": 1}},
+		bson.M{"$group": group},
+		bson.M{"$match": bson.M{"n": bson.M{"$gt": 1}}},
+	}
+
+	cursor, err := c.Aggregate(context.TODO(), pipeline, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return fmt.Errorf("failed to aggregate %s: %w", c.Name(), err)
+	}
+	defer cursor.Close(context.TODO())
+
+	for cursor.Next(context.TODO()) {
+
+		var dup bson.M
+
+		err = cursor.Decode(&dup)
+		if err != nil {
+			return fmt.Errorf("failed to decode: %w", err)
+		}
+
+		ids, _ := dup["ids"].(bson.A)
+
+		set := bson.M{}
+
+		for field := range merge {
+			// The accumulators returns null if no document has the field
+			if dup[field] != nil {
+				set[field] = dup[field]
+			}
+		}
+
+		if len(set) > 0 {
+			_, err = c.UpdateOne(context.TODO(), bson.M{"_id": ids[0]}, bson.M{"$set": set})
+			if err != nil {
+				return fmt.Errorf("failed to update %s: %w", c.Name(), err)
+			}
+		}
+
+		_, err = c.DeleteMany(context.TODO(), bson.M{"_id": bson.M{"$in": ids[1:]}})
+		if err != nil {
+			return fmt.Errorf("failed to delete from %s: %w", c.Name(), err)
+		}
+	}
+
+	return cursor.Err()
+}
+
+// mergeSources returns the union of the sources in ss by name with the oldest first and the newest last seen time.
+func mergeSources(ss [][]SourceSchema) []SourceSchema {
+
+	var r []SourceSchema
+
+	for i := range ss {
+		for _, s := range ss[i] {
+
+			found := false
+
+			for j := range r {
+
+				if r[j].Name != s.Name {
+					continue
+				}
+
+				if s.First < r[j].First {
+					r[j].First = s.First
+				}
+				if s.Last > r[j].Last {
+					r[j].Last = s.Last
+				}
+
+				found = true
+				break
+			}
+
+			if !found {
+				r = append(r, s)
+			}
+		}
+	}
+
+	return r
+}
+
+// mergeRecords returns the union of the records in rs by type and value with the newest time.
+func mergeRecords(rs [][]RecordSchema) []RecordSchema {
+
+	var r []RecordSchema
+
+	for i := range rs {
+		for _, v := range rs[i] {
+
+			found := false
+
+			for j := range r {
+				if r[j].Type == v.Type && r[j].Value == v.Value {
+					if v.Time > r[j].Time {
+						r[j].Time = v.Time
+					}
+					found = true
+					break
+				}
+			}
+
+			if !found {
+				r = append(r, v)
+			}
+		}
+	}
+
+	return r
+}
+
+// migrateRemoveDuplicates merges the names that stored multiple times in the "domains" collection into the oldest document,
+// so the unique index can be created.
+// The sources and the embedded records are merged, the timestamps are the newest ones.
+func migrateRemoveDuplicates() error {
+
+	pipeline := bson.A{
+		// The oldest document is the first in "ids"
+		bson.M{"$sort": bson.M{"_id": 1}},
+		bson.M{"$group": bson.M{
+			"_id":        bson.M{"domain": "$domain", "tld": "$tld", "sub": "$sub"},
+			"ids":        bson.M{"$push": "$_id"},
+			"count":      bson.M{"$sum": 1},
+			"updated":    bson.M{"$max": "$updated"},
+			"lastRecord": bson.M{"$max": "$lastRecord"},
+			"sources":    bson.M{"$push": bson.M{"$ifNull": bson.A{"$sources", bson.A{}}}},
+			"records":    bson.M{"$push": bson.M{"$ifNull": bson.A{"$records", bson.A{}}}},
+		}},
+		bson.M{"$match": bson.M{"count": bson.M{"$gt": 1}}},
+	}
+
+	cursor, err := Domains.Aggregate(context.TODO(), pipeline, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return fmt.Errorf("failed to aggregate: %w", err)
+	}
+	defer cursor.Close(context.TODO())
+
+	for cursor.Next(context.TODO()) {
+
+		var dup struct {
+			IDs        []interface{}    `bson:"ids"`
+			Updated    int64            `bson:"updated"`
+			LastRecord int64            `bson:"lastRecord"`
+			Sources    [][]SourceSchema `bson:"sources"`
+			Records    [][]RecordSchema `bson:"records"`
+		}
+
+		err = cursor.Decode(&dup)
+		if err != nil {
+			return fmt.Errorf("failed to decode: %w", err)
+		}
+
+		set := bson.M{"updated": dup.Updated}
+
+		if dup.LastRecord > 0 {
+			set["lastRecord"] = dup.LastRecord
+		}
+		if sources := mergeSources(dup.Sources); len(sources) > 0 {
+			set["sources"] = sources
+		}
+		if records := mergeRecords(dup.Records); len(records) > 0 {
+			set["records"] = records
+		}
+
+		_, err = Domains.UpdateOne(context.TODO(), bson.M{"_id": dup.IDs[0]}, bson.M{"$set": set})
+		if err != nil {
+			return fmt.Errorf("failed to update: %w", err)
+		}
+
+		_, err = Domains.DeleteMany(context.TODO(), bson.M{"_id": bson.M{"$in": dup.IDs[1:]}})
+		if err != nil {
+			return fmt.Errorf("failed to delete: %w", err)
+		}
+	}
+
+	return cursor.Err()
+}
+
+// MigrationsVersion returns the version of the newest applied migration.
+// Returns 0 if no migration applied.
+func MigrationsVersion() (int, error) {
+
+	m := new(MigrationSchema)
+
+	// The collection stores other documents too (eg.: the lock)
+	err := Migrations.FindOne(context.TODO(), bson.M{"version": bson.M{"$exists": true}}, options.FindOne().SetSort(bson.M{"version": -1})).Decode(m)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return 0, nil
+	}
+
+	return m.Version, err
+}
+
+const (
+	migrationsLockID      = "lock"
+	migrationsLockTimeout = 10 * time.Minute // The lock is refreshed in every minute while held
+)
+
+// migrationsLock takes the lock document in the "migrations" collection, so only one instance applies the migrations.
+// Waits until the lock is released or expired (the owner crashed).
+// The lock is refreshed in the background until the returned function is called to release it.
+func migrationsLock() (func(), error) {
+
+	host, _ := os.Hostname()
+	owner := fmt.Sprintf("%s-%d-%d", host, os.Getpid(), time.Now().UnixNano())
+
+	for {
+
+		now := time.Now()
+
+		// The upsert fails with a duplicate key error if the lock is held and not expired
+		filter := bson.M{"_id": migrationsLockID, "expires": bson.M{"$lt": now.Unix()}}
+		up := bson.M{"$set": bson.M{"owner": owner, "expires": now.Add(migrationsLockTimeout).Unix()}}
+
+		_, err := Migrations.UpdateOne(context.TODO(), filter, up, options.Update().SetUpsert(true))
+		if err == nil {
+			break
+		}
+		if !mongo.IsDuplicateKeyError(err) {
+			return nil, fmt.Errorf("failed to take lock: %w", err)
+		}
+
+		fmt.Printf("Migrate(): Waiting for the migrations of an other instance...\n")
+
+		time.Sleep(5 * time.Second)
+	}
+
+	done := make(chan struct{})
+
+	go func() {
+
+		t := time.NewTicker(time.Minute)
+		defer t.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-t.C:
+				_, err := Migrations.UpdateOne(context.TODO(), bson.M{"_id": migrationsLockID, "owner": owner}, bson.M{"$set": bson.M{"expires": time.Now().Add(migrationsLockTimeout).Unix()}})
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Migrate(): Failed to refresh lock: %s\n", err)
+				}
+			}
+		}
+	}()
+
+	release := func() {
+
+		close(done)
+
+		_, err := Migrations.DeleteOne(context.TODO(), bson.M{"_id": migrationsLockID, "owner": owner})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Migrate(): Failed to release lock: %s\n", err)
+		}
+	}
+
+	return release, nil
+}
+
+// Migrate applies the migrations newer than the current version in order and records them in the "migrations" collection.
+// Stops at the first failed migration.
+// The instances are serialized with a lock document in the "migrations" collection (see migrationsLock()).
+func Migrate() error {
+
+	release, err := migrationsLock()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	// Read after the lock, an other instance may applied the migrations
+	version, err := MigrationsVersion()
+	if err != nil {
+		return fmt.Errorf("failed to get version: %w", err)
+	}
+
+	for _, m := range migrations {
+
+		if m.Version <= version {
+			continue
+		}
+
+		fmt.Printf("Migrate(): Applying %d: %s...\n", m.Version, m.Description)
+
+		start := time.Now()
+
+		err = m.Up()
+		if err != nil {
+			return fmt.Errorf("migration %d failed: %w", m.Version, err)
+		}
+
+		_, err = Migrations.InsertOne(context.TODO(), MigrationSchema{Version: m.Version, Description: m.Description, Applied: time.Now().Unix()})
+		if err != nil {
+			return fmt.Errorf("failed to record migration %d: %w", m.Version, err)
+		}
+
+		fmt.Printf("Migrate(): Applied %d in %s\n", m.Version, time.Since(start))
+	}
+
+	return nil
+}
@@ -0,0 +1,107 @@
+package db
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestMigrationsOrder(t *testing.T) {
+
+	for i := range migrations {
+		if migrations[i].Version != i+1 {
+			t.Fatalf("FAIL: migration at index %d has version %d\n", i, migrations[i].Version)
+		}
+	}
+}
+
+func TestMergeSources(t *testing.T) {
+
+	ss := mergeSources([][]SourceSchema{
+		{{Name: SourceCT, First: 2, Last: 5}},
+		{{Name: SourceCT, First: 1, Last: 3}, {Name: SourceUser, First: 4, Last: 4}},
+	})
+
+	if len(ss) != 2 || ss[0] != (SourceSchema{Name: SourceCT, First: 1, Last: 5}) || ss[1].Name != SourceUser {
+		t.Fatalf("FAIL: invalid merged sources: %v\n", ss)
+	}
+}
+
+// TestMigrate applies the migrations on the database "columbus_test" of the MongoDB server in the COLUMBUS_TEST_MONGODB_URI environment variable.
+// The database is dropped before and after the test.
+func TestMigrate(t *testing.T) {
+
+	uri := os.Getenv("COLUMBUS_TEST_MONGODB_URI")
+	if uri == "" {
+		t.Skip("COLUMBUS_TEST_MONGODB_URI is not set")
+	}
+
+	databaseName = "columbus_test"
+	defer func() { databaseName = "columbus" }()
+
+	if err := Connect(uri); err != nil {
+		t.Fatalf("FAIL: failed to connect: %s\n", err)
+	}
+	defer Disconnect()
+
+	if err := Domains.Database().Drop(context.TODO()); err != nil {
+		t.Fatalf("FAIL: failed to drop: %s\n", err)
+	}
+	defer Domains.Database().Drop(context.TODO())
+
+	// Duplicates before the unique indexes
+	_, err := Domains.InsertMany(context.TODO(), []interface{}{
+		DomainSchema{Domain: "example", TLD: "com", Sub: "www", Updated: 1, Sources: []SourceSchema{{Name: SourceCT, First: 1, Last: 1}}, Records: []RecordSchema{{Type: 1, Value: "192.0.2.1", Time: 1}}},
+		DomainSchema{Domain: "example", TLD: "com", Sub: "www", Updated: 2, Sources: []SourceSchema{{Name: SourceUser, First: 2, Last: 2}}},
+	})
+	if err != nil {
+		t.Fatalf("FAIL: failed to insert domains: %s\n", err)
+	}
+
+	_, err = TopList.InsertMany(context.TODO(), []interface{}{TopListSchema{Domain: "example.com", Count: 2}, TopListSchema{Domain: "example.com", Count: 3}})
+	if err != nil {
+		t.Fatalf("FAIL: failed to insert topList: %s\n", err)
+	}
+
+	if err = Migrate(); err != nil {
+		t.Fatalf("FAIL: failed to migrate: %s\n", err)
+	}
+
+	if v, err := MigrationsVersion(); err != nil || v != len(migrations) {
+		t.Fatalf("FAIL: invalid version after migrate: %d, %v\n", v, err)
+	}
+
+	// Applied migrations are skipped
+	if err = Migrate(); err != nil {
+		t.Fatalf("FAIL: failed to migrate again: %s\n", err)
+	}
+
+	d := new(DomainSchema)
+
+	if err = Domains.FindOne(context.TODO(), bson.M{"domain": "example", "tld": "com", "sub": "www"}).Decode(d); err != nil {
+		t.Fatalf("FAIL: merged name not found: %s\n", err)
+	}
+	if len(d.Sources) != 2 || d.Updated != 2 || d.LastRecord != 1 || len(d.Records) != 0 {
+		t.Fatalf("FAIL: invalid merged name: %+v\n", d)
+	}
+
+	if n, err := DNSRecords.CountDocuments(context.TODO(), bson.M{}); err != nil || n != 1 {
+		t.Fatalf("FAIL: invalid number of moved records: %d, %v\n", n, err)
+	}
+
+	tl := new(TopListSchema)
+
+	if err = TopList.FindOne(context.TODO(), bson.M{"domain": "example.com"}).Decode(tl); err != nil || tl.Count != 5 {
+		t.Fatalf("FAIL: invalid merged topList: %+v, %v\n", tl, err)
+	}
+
+	if _, err = TopList.InsertOne(context.TODO(), TopListSchema{Domain: "example.com"}); err == nil {
+		t.Fatalf("FAIL: unique index on topList is missing\n")
+	}
+
+	if n, err := Migrations.CountDocuments(context.TODO(), bson.M{"_id": migrationsLockID}); err != nil || n != 0 {
+		t.Fatalf("FAIL: lock is not released: %d, %v\n", n, err)
+	}
+}
@@ -0,0 +1,152 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/elmasy-com/columbus-server/config"
+	"github.com/elmasy-com/columbus-server/fault"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	NotFoundDiscoveryInterval = 7 * 24 * time.Hour // Minimum time between two active discovery of the same domain
+)
+
+// notFoundExists checks whether domain d has any entry in the *domains* collection.
+func notFoundExists(d string) (bool, error) {
+
+	if IsPublicSuffix(d) {
+		return false, fault.ErrPublicSuffix
+	}
+
+	p := GetParts(d)
+	if p == nil || p.Domain == "" || p.TLD == "" {
+		return false, fault.ErrGetPartsFailed
+	}
+
+	n, err := Domains.CountDocuments(context.TODO(), bson.D{{Key: "domain", Value: p.Domain}, {Key: "tld", Value: p.TLD}}, options.Count().SetLimit(1))
+
+	return n > 0, err
+}
+
+// NotFoundRemove removes domain d from the *notFound* collection.
+func NotFoundRemove(d string) error {
+
+	_, err := NotFound.DeleteOne(context.TODO(), bson.M{"domain": d})
+
+	return err
+}
+
+// notFoundCheck checks domain d and removes it from the *notFound* collection if found in *domains*.
+// If config.NotFoundDiscovery is true and d is not checked in NotFoundDiscoveryInterval, d is discovered with DiscoverCommon().
+func notFoundCheck(d NotFoundSchema) error {
+
+	found, err := notFoundExists(d.Domain)
+	if err != nil {
+		return fmt.Errorf("failed to check: %w", err)
+	}
+
+	if found {
+		return NotFoundRemove(d.Domain)
+	}
+
+	if !config.NotFoundDiscovery || time.Since(time.Unix(d.Checked, 0)) < NotFoundDiscoveryInterval {
+		return nil
+	}
+
+	n, err := DiscoverCommon(d.Domain)
+	if err != nil {
+		return fmt.Errorf("failed to discover: %w", err)
+	}
+
+	if n == 0 {
+		_, err = NotFound.UpdateOne(context.TODO(), bson.M{"domain": d.Domain}, bson.M{"$set": bson.M{"checked": time.Now().Unix()}})
+		return err
+	}
+
+	// Update the records of the newly found names
+	internalRecordsUpdaterDomainChan <- d.Domain
+
+	return NotFoundRemove(d.Domain)
+}
+
+// NotFoundWorker periodically checks the domains in the *notFound* collection and removes the ones that found in the *domains* collection.
+// If config.NotFoundDiscovery is true, the remaining domains are discovered with DiscoverCommon().
+//
+// This function is designed to run as a goroutine in the background.
+// The errors are printed to STDERR.
+func NotFoundWorker() {
+
+	t := time.Tick(3600 * time.Second)
+
+	for range t {
+
+		start := time.Now()
+
+		cursor, err := NotFound.Find(context.TODO(), bson.M{}, options.Find().SetSort(bson.M{"count": -1}))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "NotFoundWorker(): Failed to find notFound entries: %s\n", err)
+			continue
+		}
+
+		for cursor.Next(context.TODO()) {
+
+			d := new(NotFoundSchema)
+
+			err = cursor.Decode(d)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "NotFoundWorker(): Failed to decode: %s\n", err)
+				continue
+			}
+
+			err = notFoundCheck(*d)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "NotFoundWorker(): Failed to check %s: %s\n", d.Domain, err)
+			}
+		}
+
+		err = cursor.Err()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "NotFoundWorker(): Cursor failed: %s\n", err)
+		}
+
+		cursor.Close(context.TODO())
+		fmt.Printf("NotFoundWorker(): Finished checking notFound in %s\n", time.Since(start))
+	}
+}
+
+// NotFoundGets returns limit entries from the *notFound* collection after skipping skip entries.
+// The entries are sorted by the number of requests.
+func NotFoundGets(limit int, skip int) ([]NotFoundSchema, error) {
+
+	cursor, err := NotFound.Find(context.TODO(), bson.M{}, options.Find().SetSort(bson.D{{Key: "count", Value: -1}, {Key: "domain", Value: 1}}).SetSkip(int64(skip)).SetLimit(int64(limit)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to find: %w", err)
+	}
+	defer cursor.Close(context.TODO())
+
+	r := make([]NotFoundSchema, 0, limit)
+
+	for cursor.Next(context.TODO()) {
+
+		d := new(NotFoundSchema)
+
+		err = cursor.Decode(d)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode: %w", err)
+		}
+
+		r = append(r, *d)
+	}
+
+	err = cursor.Err()
+	if err != nil {
+		return nil, fmt.Errorf("cursor failed: %w", err)
+	}
+
+	return r, nil
+}
@@ -20,6 +20,7 @@ import (
 
 var (
 	RecordsUpdaterDomainChan         chan string
+	RecordsResolveChan               chan string // Candidate FQDNs that inserted only if resolves
 	internalRecordsUpdaterDomainChan chan string
 	totalUpdated                     atomic.Uint64
 	startTime                        time.Time
@@ -41,7 +42,8 @@ func increaseTotalUpdated() {
 // RecordsUpdateUpdatedTime updated the "updated" timestamp to the current time.
 //
 // If d is invalid return fault.ErrInvalidDomain.
-// If failed to get parts of d (eg.: d is a TLD), returns fault.ErrGetPartsFailed.
+// If failed to get parts of d, returns fault.ErrGetPartsFailed.
+// If d is a public suffix (eg.: co.uk or a TLD), returns fault.ErrPublicSuffix.
 func RecordsUpdateUpdatedTime(d string) error {
 
 	if !valid.Domain(d) {
@@ -50,24 +52,23 @@ func RecordsUpdateUpdatedTime(d string) error {
 
 	d = dns.Clean(d)
 
-	p := dns.GetParts(d)
+	if IsPublicSuffix(d) {
+		return fault.ErrPublicSuffix
+	}
+
+	p := GetParts(d)
 	if p == nil || p.Domain == "" || p.TLD == "" {
 		return fault.ErrGetPartsFailed
 	}
 
-	filter := bson.D{{Key: "domain", Value: p.Domain}, {Key: "tld", Value: p.TLD}, {Key: "sub", Value: p.Sub}}
-
-	up := bson.D{{Key: "$set", Value: bson.D{{Key: "updated", Value: time.Now().Unix()}}}}
-
-	_, err := Domains.UpdateOne(context.TODO(), filter, up)
-
-	return err
+	return store.SetUpdated(p, time.Now().Unix())
 }
 
 // RecordsUpdatedRecently check whether domain d is updated recently (in the previous hour).
 //
 // If d is invalid return fault.ErrInvalidDomain.
-// If failed to get parts of d (eg.: d is a TLD), returns fault.ErrGetPartsFailed.
+// If failed to get parts of d, returns fault.ErrGetPartsFailed.
+// If d is a public suffix (eg.: co.uk or a TLD), returns fault.ErrPublicSuffix.
 func RecordsUpdatedRecently(d string) (bool, error) {
 
 	if !valid.Domain(d) {
@@ -76,18 +77,21 @@ func RecordsUpdatedRecently(d string) (bool, error) {
 
 	d = dns.Clean(d)
 
-	p := dns.GetParts(d)
+	if IsPublicSuffix(d) {
+		return false, fault.ErrPublicSuffix
+	}
+
+	p := GetParts(d)
 	if p == nil || p.Domain == "" || p.TLD == "" {
 		return false, fault.ErrGetPartsFailed
 	}
 
-	filter := bson.D{{Key: "domain", Value: p.Domain}, {Key: "tld", Value: p.TLD}, {Key: "sub", Value: p.Sub}}
-
-	dom := new(DomainSchema)
-
-	err := Domains.FindOne(context.TODO(), filter).Decode(dom)
+	dom, err := store.Get(p)
+	if err != nil {
+		return false, err
+	}
 
-	return dom.Updated > time.Now().Unix()-3600, err
+	return dom.Updated > time.Now().Unix()-3600, nil
 }
 
 // Update type t records for d.
@@ -101,7 +105,11 @@ func recordsUpdateRecord(d string, t uint16) error {
 
 	d = dns.Clean(d)
 
-	p := dns.GetParts(d)
+	if IsPublicSuffix(d) {
+		return fault.ErrPublicSuffix
+	}
+
+	p := GetParts(d)
 	if p == nil || p.Domain == "" || p.TLD == "" {
 		return fault.ErrGetPartsFailed
 	}
@@ -178,46 +186,29 @@ func recordsUpdateRecord(d string, t uint16) error {
 		return err
 	}
 
-	for i := range r {
-
-		// "records" field should contain only one element with "type" t and "value" v.
-		// Try to update first!
-		// If MatchedCount is 0, the record with "type" t and "value" r[i] is new and the new record will be appended to the array.
-		// If MatchedCount is 1, only one record is exist with "type" t and "value" v and the time for the element is updated.
-		// If MatchedCount is > 1, duplicate record found, ERROR!
-		filter := bson.D{{Key: "domain", Value: p.Domain}, {Key: "tld", Value: p.TLD}, {Key: "sub", Value: p.Sub}, {Key: "records.type", Value: t}, {Key: "records.value", Value: r[i]}}
-
-		up := bson.D{{Key: "$set", Value: bson.D{{Key: "records.$.time", Value: time.Now().Unix()}}}}
-
-		result, err := Domains.UpdateOne(context.TODO(), filter, up)
-		if err != nil {
-			return err
-		}
-		if result.MatchedCount > 1 {
-			return fmt.Errorf("duplicate record found: %s", r[i])
-		}
-		if result.MatchedCount == 1 {
-			continue
-		}
+	now := time.Now().Unix()
+	rs := make([]RecordSchema, 0, len(r))
 
-		// Append new record to "records"
-		filter = bson.D{{Key: "domain", Value: p.Domain}, {Key: "tld", Value: p.TLD}, {Key: "sub", Value: p.Sub}}
+	for i := range r {
+		rs = append(rs, RecordSchema{Type: t, Value: r[i], Time: now})
+	}
 
-		up = bson.D{{Key: "$addToSet", Value: bson.D{{Key: "records", Value: RecordSchema{Type: t, Value: r[i], Time: time.Now().Unix()}}}}}
+	isNew, err := store.InsertRecords(p, rs)
+	if err != nil {
+		return err
+	}
 
-		_, err = Domains.UpdateOne(context.TODO(), filter, up)
-		if err != nil {
-			return err
-		}
+	// The cached results change only with a new type or value, the time of the newest observation may be stale until the cache expires
+	if isNew {
+		cacheInvalidate(cacheDomainTag(p))
 	}
 
 	return nil
 }
 
-// RecordsUpdate updates the records field for domain d if d is not update recently (in the previous hour).
+// RecordsUpdate updates the records of domain d if d is not update recently (in the previous hour).
 // This function updates the "updated" field to the current time and the records in the database.
-// If the same record found, updates the "time" field in element.
-// If new record found, append it to the "records" field.
+// Every record found is stored as a new observation (see Store.InsertRecords()).
 //
 // Checks if d is a wildcard record before update.
 //
@@ -225,9 +216,15 @@ func recordsUpdateRecord(d string, t uint16) error {
 // If ignoreUpdated is true, ignore when was the last update based on the "updated" timestamp.
 //
 // If domain d is invalid, returns fault.ErrInvalidDomain.
-// If failed to get parts of d (eg.: d is a TLD), returns fault.ErrGetPartsFailed.
+// If failed to get parts of d, returns fault.ErrGetPartsFailed.
+// If d is a public suffix (eg.: co.uk or a TLD), returns fault.ErrPublicSuffix.
+// Blocked names (see IsBlocked()) are skipped.
 func RecordsUpdate(d string, ignoreError bool, ignoreUpdated bool) error {
 
+	if IsBlocked(dns.Clean(d)) {
+		return nil
+	}
+
 	if !ignoreUpdated {
 
 		updated, err := RecordsUpdatedRecently(d)
@@ -387,8 +384,28 @@ func RecordsUpdate(d string, ignoreError bool, ignoreUpdated bool) error {
 	return nil
 }
 
-// recordsUpdaterRoutine reads from DomainChan and internalDomainChan
+// recordsResolve inserts the candidate FQDN d with source SourcePermutation and updates the records if d resolves.
+func recordsResolve(d string) error {
+
+	ok, err := resolves(d)
+	if err != nil {
+		return fmt.Errorf("failed to resolve: %w", err)
+	}
+	if !ok {
+		return nil
+	}
+
+	_, err = Insert(d, SourcePermutation)
+	if err != nil {
+		return fmt.Errorf("failed to insert: %w", err)
+	}
+
+	return RecordsUpdate(d, true, true)
+}
+
+// recordsUpdaterRoutine reads from DomainChan, internalDomainChan and RecordsResolveChan
 // and updates the FQDN coming from the channel.
+// The FQDNs coming from RecordsResolveChan are inserted first if resolves.
 func recordsUpdaterRoutine(wg *sync.WaitGroup) {
 
 	defer wg.Done()
@@ -403,6 +420,17 @@ func recordsUpdaterRoutine(wg *sync.WaitGroup) {
 
 		case dom := <-internalRecordsUpdaterDomainChan:
 			d = dom
+
+		case dom := <-RecordsResolveChan:
+
+			increaseTotalUpdated()
+
+			err := recordsResolve(dom)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to resolve candidate %s: %s\n", dom, err)
+			}
+
+			continue
 		}
 
 		if dns.HasSub(d) {
@@ -418,7 +446,7 @@ func recordsUpdaterRoutine(wg *sync.WaitGroup) {
 		} else {
 
 			// If domain sent instead of FQDN, get every subdomain and updates it
-			ds, err := LookupFull(d, -1)
+			ds, err := LookupFull(d, LookupFilter{Days: -1})
 			if err != nil {
 				fmt.Fprintf(os.Stderr, "Failed to update DNS records for %s: %s\n", d, err)
 				continue
@@ -509,7 +537,7 @@ func TopListUpdater(wg *sync.WaitGroup) {
 				break
 			}
 
-			ds, err := LookupFull(d.Domain, -1)
+			ds, err := LookupFull(d.Domain, LookupFilter{Days: -1})
 			if err != nil {
 				fmt.Fprintf(os.Stderr, "TopListUpdater() failed to lookup full for %s: %s\n", d.Domain, err)
 				continue
@@ -533,6 +561,7 @@ func TopListUpdater(wg *sync.WaitGroup) {
 func RecordsUpdater() {
 
 	RecordsUpdaterDomainChan = make(chan string, config.DomainBuffer)
+	RecordsResolveChan = make(chan string, config.DomainBuffer)
 	internalRecordsUpdaterDomainChan = make(chan string, config.DomainBuffer)
 	startTime = time.Now()
 
@@ -543,11 +572,15 @@ func RecordsUpdater() {
 		go recordsUpdaterRoutine(wg)
 	}
 
-	wg.Add(1)
-	go RandomDomainUpdater(wg)
+	// The random sample and the top list are available only in MongoDB
+	if MongoDB() {
+
+		wg.Add(1)
+		go RandomDomainUpdater(wg)
 
-	wg.Add(1)
-	go TopListUpdater(wg)
+		wg.Add(1)
+		go TopListUpdater(wg)
+	}
 
 	wg.Wait()
 }
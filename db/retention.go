@@ -0,0 +1,136 @@
+package db
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/elmasy-com/columbus-server/config"
+	"github.com/elmasy-com/elnet/dns"
+)
+
+// Maximum number of names in RetentionReportSchema.Examples.
+const retentionMaxExamples = 100
+
+var (
+	retentionReport  *RetentionReportSchema
+	retentionReportM sync.RWMutex
+)
+
+// RetentionRun applies the retention policy set in the config:
+//   - the names without successful resolution in the previous config.RetentionNames days are archived or deleted (see config.RetentionAction)
+//   - the records older than config.RetentionRecords days are trimmed
+//   - only the newest config.RetentionRecordCap values are kept of every record type of a name
+//
+// A policy with 0 value is disabled. If dryRun is true, nothing is removed, the report contains what would be removed.
+// The report is saved (see RetentionReport()) even if the run failed.
+func RetentionRun(dryRun bool) (*RetentionReportSchema, error) {
+
+	r := &RetentionReportSchema{Started: time.Now().Unix(), DryRun: dryRun, Action: config.RetentionAction, Examples: []string{}}
+
+	err := retentionRun(r)
+	if err != nil {
+		r.Error = err.Error()
+	}
+
+	r.Finished = time.Now().Unix()
+
+	retentionReportM.Lock()
+	retentionReport = r
+	retentionReportM.Unlock()
+
+	return r, err
+}
+
+func retentionRun(r *RetentionReportSchema) error {
+
+	var err error
+
+	if config.RetentionNames > 0 {
+
+		before := time.Now().AddDate(0, 0, -1*config.RetentionNames).Unix()
+
+		r.Names, err = store.PruneNames(before, config.RetentionAction == "archive", r.DryRun, func(d *DomainSchema) {
+
+			if len(r.Examples) < retentionMaxExamples {
+				r.Examples = append(r.Examples, d.String())
+			}
+
+			if !r.DryRun {
+				p := &dns.Parts{Domain: d.Domain, TLD: d.TLD, Sub: d.Sub}
+				cacheInvalidate(cacheDomainTag(p), cacheSLDTag(p.Domain))
+			}
+		})
+		if err != nil {
+			return fmt.Errorf("failed to prune names: %w", err)
+		}
+	}
+
+	if config.RetentionRecords > 0 {
+
+		r.Records, err = store.TrimRecords(config.RetentionRecords, r.DryRun)
+		if err != nil {
+			return fmt.Errorf("failed to trim records: %w", err)
+		}
+	}
+
+	if config.RetentionRecordCap > 0 {
+
+		r.Capped, err = store.CapRecords(config.RetentionRecordCap, r.DryRun)
+		if err != nil {
+			return fmt.Errorf("failed to cap records: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// RetentionClear clears the background removal of the records set by a previous run (see Store.TrimRecords())
+// if config.RetentionRecords is disabled or config.RetentionDryRun is set.
+func RetentionClear() error {
+
+	if config.RetentionRecords > 0 && !config.RetentionDryRun {
+		return nil
+	}
+
+	_, err := store.TrimRecords(0, false)
+
+	return err
+}
+
+// RetentionReport returns the report of the last run.
+// Returns nil if the retention policy is not applied yet.
+func RetentionReport() *RetentionReportSchema {
+
+	retentionReportM.RLock()
+	defer retentionReportM.RUnlock()
+
+	return retentionReport
+}
+
+// RetentionWorker applies the retention policy every config.RetentionInterval.
+// With config.RetentionDryRun, only the report is created.
+//
+// This function is designed to run as a goroutine in the background.
+// The errors are printed to STDERR, the report is printed to STDOUT.
+func RetentionWorker() {
+
+	for {
+
+		r, err := RetentionRun(config.RetentionDryRun)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "RetentionWorker(): Failed to apply retention policy: %s\n", err)
+		}
+
+		verb := "Removed"
+		if r.DryRun {
+			verb = "Would remove"
+		}
+
+		fmt.Printf("RetentionWorker(): %s %d names (%s), %d old records and %d record values over the cap in %s\n",
+			verb, r.Names, r.Action, r.Records, r.Capped, time.Duration(r.Finished-r.Started)*time.Second)
+
+		time.Sleep(config.RetentionInterval)
+	}
+}
@@ -0,0 +1,74 @@
+package db
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/elmasy-com/columbus-server/config"
+	"github.com/elmasy-com/elnet/dns"
+)
+
+func TestRetentionRun(t *testing.T) {
+
+	s, err := NewBoltStore(filepath.Join(t.TempDir(), "columbus.db"))
+	if err != nil {
+		t.Fatalf("FAIL: %s\n", err)
+	}
+	defer s.Close()
+
+	SetStore(s)
+
+	old := time.Now().AddDate(-1, 0, 0).Unix()
+	now := time.Now().Unix()
+
+	dead := &DomainSchema{Sub: "old", Domain: "example", TLD: "com", Updated: now, Sources: []SourceSchema{{Name: SourceCT, First: old, Last: old}}}
+	fresh := &DomainSchema{Sub: "new", Domain: "example", TLD: "com", Updated: now, Sources: []SourceSchema{{Name: SourceCT, First: now, Last: now}}}
+	live := &DomainSchema{Sub: "www", Domain: "example", TLD: "com", Updated: now, Sources: []SourceSchema{{Name: SourceCT, First: old, Last: old}}}
+	unchecked := &DomainSchema{Sub: "mail", Domain: "example", TLD: "com", Updated: old, Sources: []SourceSchema{{Name: SourceCT, First: old, Last: old}}}
+
+	for _, d := range []*DomainSchema{dead, fresh, live, unchecked} {
+		if _, err = s.Import(d); err != nil {
+			t.Fatalf("FAIL: failed to import %s: %s\n", d.String(), err)
+		}
+	}
+
+	p := &dns.Parts{Sub: "www", Domain: "example", TLD: "com"}
+
+	_, err = s.InsertRecords(p, []RecordSchema{{Type: dns.TypeA, Value: "192.0.2.1", Time: old}, {Type: dns.TypeA, Value: "192.0.2.2", Time: now - 1}, {Type: dns.TypeA, Value: "192.0.2.3", Time: now}})
+	if err != nil {
+		t.Fatalf("FAIL: failed to insert records: %s\n", err)
+	}
+
+	config.RetentionNames = 30
+	config.RetentionAction = "archive"
+	config.RetentionRecords = 30
+	config.RetentionRecordCap = 1
+
+	r, err := RetentionRun(true)
+	if err != nil || r.Names != 1 || r.Examples[0] != "old.example.com" || r.Records != 1 || r.Capped != 2 {
+		t.Fatalf("FAIL: dry run returned %+v, %v\n", r, err)
+	}
+
+	if _, err = s.Get(&dns.Parts{Sub: "old", Domain: "example", TLD: "com"}); err != nil {
+		t.Fatalf("FAIL: dry run removed the name: %s\n", err)
+	}
+
+	r, err = RetentionRun(false)
+	if err != nil || r.Names != 1 || r.Records != 1 || r.Capped != 1 {
+		t.Fatalf("FAIL: run returned %+v, %v\n", r, err)
+	}
+
+	if _, err = s.Get(&dns.Parts{Sub: "old", Domain: "example", TLD: "com"}); err == nil {
+		t.Fatalf("FAIL: dead name is not removed\n")
+	}
+
+	if _, err = s.Get(&dns.Parts{Sub: "mail", Domain: "example", TLD: "com"}); err != nil {
+		t.Fatalf("FAIL: name without recent check is removed: %s\n", err)
+	}
+
+	rs, err := s.Records(p, LookupFilter{}, true)
+	if err != nil || len(rs["www"]) != 1 || rs["www"][0].Value != "192.0.2.3" {
+		t.Fatalf("FAIL: invalid records after run: %v, %v\n", rs, err)
+	}
+}
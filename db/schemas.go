@@ -2,11 +2,18 @@ package db
 
 import (
 	"strings"
+	"time"
 )
 
 // Schema used in *notFound* collection.
+// First and Last are the Unix timestamps of the first and the last request.
+// Checked is the Unix timestamp of the last active discovery.
 type NotFoundSchema struct {
-	Domain string `bson:"domain" json:"domain"`
+	Domain  string `bson:"domain" json:"domain"`
+	Count   int    `bson:"count" json:"count"`
+	First   int64  `bson:"first" json:"first"`
+	Last    int64  `bson:"last" json:"last"`
+	Checked int64  `bson:"checked,omitempty" json:"checked,omitempty"`
 }
 
 // Schema used in *topList* collection.
@@ -15,6 +22,63 @@ type TopListSchema struct {
 	Count  int    `bson:"count" json:"count"`
 }
 
+// Schema used in *topListBuckets* collection.
+// Date is the beginning of the day (UTC) as a Unix timestamp.
+type TopListBucketSchema struct {
+	Domain string `bson:"domain" json:"domain"`
+	Date   int64  `bson:"date" json:"date"`
+	Count  int    `bson:"count" json:"count"`
+}
+
+// Schema used to return the trending domains.
+// Count is the number of lookups in the current window, Previous is the number of lookups in the previous window.
+type TrendingSchema struct {
+	Domain   string `bson:"_id" json:"domain"`
+	Count    int    `bson:"count" json:"count"`
+	Previous int    `bson:"previous" json:"previous"`
+	Growth   int    `bson:"growth" json:"growth"`
+}
+
+// Schema used in *wordlist* collection.
+// TLD is empty for the count across every TLD.
+type WordlistSchema struct {
+	TLD   string `bson:"tld" json:"tld"`
+	Label string `bson:"label" json:"label"`
+	Count int64  `bson:"count" json:"count"`
+}
+
+// Schema used to return an existing lookalike domain.
+// Names is the number of names (including subdomains) of the domain, First is the Unix timestamp of the first time when any of them was seen.
+// Records are the records of the domain itself (without subdomain).
+type LookalikeSchema struct {
+	Domain  string         `bson:"domain" json:"domain"`
+	Names   int64          `bson:"names" json:"names"`
+	First   int64          `bson:"first" json:"first"`
+	Records []RecordSchema `bson:"records,omitempty" json:"records,omitempty"`
+}
+
+// Schema used to return a name with metadata in LookupDetails().
+// First is the oldest first seen time of the sources, Sources are the names of the sources.
+// LastRecordTime is the Unix timestamp of the newest record, 0 if the name has no record.
+type LookupDetailSchema struct {
+	Sub            string   `json:"sub"`
+	FQDN           string   `json:"fqdn"`
+	First          int64    `json:"first"`
+	Sources        []string `json:"sources"`
+	Updated        int64    `json:"updated"`
+	HasRecords     bool     `json:"hasRecords"`
+	LastRecordTime int64    `json:"lastRecordTime"`
+}
+
+// Schema used to answer the conditional requests without the full query, see LookupVersion().
+// The timestamps are the newest values of the matching names.
+type VersionSchema struct {
+	Names      int64 `bson:"names" json:"names"`
+	First      int64 `bson:"first" json:"first"` // First seen time of a source
+	Updated    int64 `bson:"updated" json:"updated"`
+	LastRecord int64 `bson:"lastRecord" json:"lastRecord"`
+}
+
 // Schema used in Lookup() to ignore the Records field.
 type FastDomainSchema struct {
 	Domain string `bson:"domain" json:"domain"`
@@ -39,13 +103,42 @@ type RecordSchema struct {
 	Time  int64  `bson:"time" json:"time"`
 }
 
+// Schema used in RecordObservationSchema to identify the name and the record.
+type RecordMetaSchema struct {
+	Domain string `bson:"domain" json:"domain"`
+	TLD    string `bson:"tld" json:"tld"`
+	Sub    string `bson:"sub" json:"sub"`
+	Type   uint16 `bson:"type" json:"type"`
+	Value  string `bson:"value" json:"value"`
+}
+
+// Schema used in the "records" time-series collection.
+// Every resolution of a record is stored as a new observation.
+type RecordObservationSchema struct {
+	Time time.Time        `bson:"time" json:"time"`
+	Meta RecordMetaSchema `bson:"meta" json:"meta"`
+}
+
+// Schema used to store a source of the name in DomainSchema.
+// First and Last are the Unix timestamps when the name first and last seen in the source.
+type SourceSchema struct {
+	Name  string `bson:"name" json:"name"`
+	First int64  `bson:"first" json:"first"`
+	Last  int64  `bson:"last" json:"last"`
+}
+
 // Schema used in the "domains" collection.
+// LastRecord is the Unix timestamp of the newest record, the field is not exists if the name has no record.
+// The records are stored in the "records" collection in MongoDB (see RecordObservationSchema),
+// Records is used only by the embedded store.
 type DomainSchema struct {
-	Domain  string         `bson:"domain" json:"domain"`
-	TLD     string         `bson:"tld" json:"tld"`
-	Sub     string         `bson:"sub" json:"sub"`
-	Updated int64          `bson:"updated" json:"updated"`
-	Records []RecordSchema `bson:"records,omitempty" json:"records,omitempty"`
+	Domain     string         `bson:"domain" json:"domain"`
+	TLD        string         `bson:"tld" json:"tld"`
+	Sub        string         `bson:"sub" json:"sub"`
+	Updated    int64          `bson:"updated" json:"updated"`
+	LastRecord int64          `bson:"lastRecord,omitempty" json:"lastRecord,omitempty"`
+	Records    []RecordSchema `bson:"records,omitempty" json:"records,omitempty"`
+	Sources    []SourceSchema `bson:"sources,omitempty" json:"sources,omitempty"`
 }
 
 // Returns the full hostname (eg.: sub.domain.tld).
@@ -63,6 +156,49 @@ func (d *DomainSchema) FullDomain() string {
 	return strings.Join([]string{d.Domain, d.TLD}, ".")
 }
 
+// Schema used in the "archive" collection.
+// Archived is the Unix timestamp when the name removed by the retention policy.
+type ArchivedDomainSchema struct {
+	DomainSchema `bson:",inline"`
+	Archived     int64 `bson:"archived" json:"archived"`
+}
+
+// Schema used to return the report of a run of the retention policy.
+// Names is the number of the names removed (or would be removed with DryRun), Examples contains some of them.
+// Records is the number of trimmed records, Capped is the number of record values removed by the cap per type.
+type RetentionReportSchema struct {
+	Started  int64    `json:"started"`
+	Finished int64    `json:"finished"`
+	DryRun   bool     `json:"dryRun"`
+	Action   string   `json:"action"`
+	Names    int64    `json:"names"`
+	Examples []string `json:"examples"`
+	Records  int64    `json:"records"`
+	Capped   int64    `json:"capped"`
+	Error    string   `json:"error,omitempty"`
+}
+
+// Schema used in the "blocked" collection.
+// Pattern is a domain (eg.: example.com) that blocks the domain and every subdomain of it,
+// or a glob-style pattern (eg.: "*.internal.example.com") matched against the full hostname (see path.Match()).
+// Admin is the name of the admin who blocked the entry, Created is the Unix timestamp of the block.
+type BlockedSchema struct {
+	Pattern string `bson:"pattern" json:"pattern"`
+	Reason  string `bson:"reason" json:"reason"`
+	Admin   string `bson:"admin" json:"admin"`
+	Created int64  `bson:"created" json:"created"`
+}
+
+// Schema used in the "blockedAudit" collection to record every change of the blocked entries.
+// Action is "block" or "unblock", Time is the Unix timestamp of the change.
+type BlockedAuditSchema struct {
+	Action  string `bson:"action" json:"action"`
+	Pattern string `bson:"pattern" json:"pattern"`
+	Reason  string `bson:"reason" json:"reason"`
+	Admin   string `bson:"admin" json:"admin"`
+	Time    int64  `bson:"time" json:"time"`
+}
+
 // Schema used in "ctlogs" collection
 type CTLogSchema struct {
 	Name  string `bson:"name" json:"name"`
@@ -73,7 +209,111 @@ type CTLogSchema struct {
 type StatisticSchema struct {
 	Date    int64         `bson:"date" json:"date"`
 	Total   int64         `bson:"total" json:"total"`
-	Updated int64         `bsn:"updated" json:"updated"`
+	Updated int64         `bson:"updated" json:"updated"`
 	Valid   int64         `bson:"valid" json:"valid"`
 	CTLogs  []CTLogSchema `bson:"ctlogs" json:"ctlogs"`
 }
+
+// Schema used in "tldStatistics" collection to store the number of names and domains for a TLD.
+type TLDStatisticSchema struct {
+	TLD     string `bson:"tld" json:"tld"`
+	Total   int64  `bson:"total" json:"total"`
+	Domains int64  `bson:"domains" json:"domains"`
+}
+
+// Schema used in "tldStatistics" collection.
+type TLDStatisticsSchema struct {
+	Date int64                `bson:"date" json:"date"`
+	TLDs []TLDStatisticSchema `bson:"tlds" json:"tlds"`
+}
+
+// Schema used in DomainStatisticSchema to store the number of records with type Type.
+type RecordTypeStatisticSchema struct {
+	Type  uint16 `bson:"_id" json:"type"`
+	Count int64  `bson:"count" json:"count"`
+}
+
+// Schema used to return the statistic of a single domain.
+type DomainStatisticSchema struct {
+	Domain  string                      `json:"domain"`
+	Total   int64                       `json:"total"`
+	Valid   int64                       `json:"valid"`
+	Records []RecordTypeStatisticSchema `json:"records"`
+	Updated int64                       `json:"updated"`
+}
+
+// Schema used to return the metrics of the lookup cache.
+type CacheStatisticSchema struct {
+	Enabled       bool  `json:"enabled"`
+	Hits          int64 `json:"hits"`
+	Misses        int64 `json:"misses"`
+	Invalidations int64 `json:"invalidations"`
+	Entries       int   `json:"entries"`
+	Evictions     int64 `json:"evictions"`
+}
+
+// Schema used to return the metrics of the Bloom filter.
+type BloomStatisticSchema struct {
+	Ready           bool    `json:"ready"`
+	Elements        uint64  `json:"elements"`
+	Bits            uint64  `json:"bits"`
+	Hashes          uint64  `json:"hashes"`
+	EstimatedFPRate float64 `json:"estimatedFPRate"`
+	Positives       int64   `json:"positives"`
+	Negatives       int64   `json:"negatives"`
+	FalsePositives  int64   `json:"falsePositives"`
+	ObservedFPRate  float64 `json:"observedFPRate"`
+}
+
+// Schema used as an entry of an export stream (see Encoder).
+// Collection is the name of the source collection and exactly one of the other fields is set.
+type ExportEntrySchema struct {
+	Collection string           `bson:"collection" json:"collection"`
+	Domain     *DomainSchema    `bson:"domain,omitempty" json:"domain,omitempty"`
+	CTLog      *CTLogSchema     `bson:"ctlog,omitempty" json:"ctlog,omitempty"`
+	TopList    *TopListSchema   `bson:"topList,omitempty" json:"topList,omitempty"`
+	Statistic  *StatisticSchema `bson:"statistic,omitempty" json:"statistic,omitempty"`
+}
+
+// Schema used in DumpManifestSchema to describe a dump file.
+// Type is DumpNames, DumpRecords or DumpDelta. TLD is set for the names and the records files,
+// Date is the beginning of the day (UTC) as a Unix timestamp for the delta files.
+// Generated is the Unix timestamp of the generation.
+type DumpFileSchema struct {
+	Name      string `json:"name"`
+	Type      string `json:"type"`
+	TLD       string `json:"tld,omitempty"`
+	Date      int64  `json:"date,omitempty"`
+	Size      int64  `json:"size"`
+	SHA256    string `json:"sha256"`
+	Generated int64  `json:"generated"`
+}
+
+// Schema used to return the list of the dump files.
+// Generated is the Unix timestamp of the last generation.
+type DumpManifestSchema struct {
+	Generated int64            `json:"generated"`
+	Files     []DumpFileSchema `json:"files"`
+}
+
+// Schema used in "migrations" collection.
+// Applied is the Unix timestamp when the migration applied.
+type MigrationSchema struct {
+	Version     int    `bson:"version" json:"version"`
+	Description string `bson:"description" json:"description"`
+	Applied     int64  `bson:"applied" json:"applied"`
+}
+
+// Schema of the setting documents in the *migrations* collection, see Store.SettingGet().
+type SettingSchema struct {
+	Key   string `bson:"_id" json:"key"`
+	Value string `bson:"value" json:"value"`
+}
+
+// Schema of the lock document in the *migrations* collection, held by the instance that applies the migrations.
+// Expires is the Unix timestamp when the lock can be taken over (the owner is considered crashed).
+type MigrationLockSchema struct {
+	ID      string `bson:"_id" json:"id"`
+	Owner   string `bson:"owner" json:"owner"`
+	Expires int64  `bson:"expires" json:"expires"`
+}
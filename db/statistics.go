@@ -7,6 +7,8 @@ import (
 	"os"
 	"time"
 
+	"github.com/elmasy-com/columbus-server/fault"
+	"github.com/elmasy-com/elnet/dns"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
@@ -18,19 +20,19 @@ const (
 // StatisticsCountTotal returns the total number of entries in "domain" collection.
 func StatisticsCountTotal() (int64, error) {
 
-	return Domains.CountDocuments(context.TODO(), bson.M{})
+	return store.CountTotal()
 }
 
 // StatisticsCountUpdated returns the total number of entries that updated in "domain" collection.
 func StatisticsCountUpdated() (int64, error) {
 
-	return Domains.CountDocuments(context.TODO(), bson.M{"updated": bson.M{"$exists": true}})
+	return store.CountUpdated()
 }
 
-// StatisticsCountValid returns the total number of entries that has at least on valid record in the "records" field in "domain" collection.
+// StatisticsCountValid returns the total number of entries that has at least on valid record in "domain" collection.
 func StatisticsCountValid() (int64, error) {
 
-	return Domains.CountDocuments(context.TODO(), bson.M{"records": bson.M{"$exists": true}})
+	return store.CountValid()
 }
 
 // StatisticsInsert get the stats and insert a new entry in the "statistics" collection.
@@ -63,9 +65,7 @@ func StatisticsInsert() error {
 
 	s.Date = time.Now().Unix()
 
-	_, err = Statistics.InsertOne(context.TODO(), *s)
-
-	return err
+	return store.StatisticsInsert(*s)
 }
 
 // StatisticsInsertWorker insert a new Statistic entry at the beginning and at a random time in an infinite loop.
@@ -100,92 +100,188 @@ func StatisticsCleanWorker() {
 
 	for range t {
 
-		n, err := Statistics.CountDocuments(context.TODO(), bson.M{})
+		err := store.StatisticsClean(MaxStatisticsEntry)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "StatisticsRemoveOldEntries(): Failed to count total statistic entries: %s\n", err)
-			continue
+			fmt.Fprintf(os.Stderr, "StatisticsRemoveOldEntries(): %s\n", err)
 		}
+	}
+}
 
-		if n <= MaxStatisticsEntry {
-			continue
-		}
+// StatisticsGetNewest returns the newest entry from the "statistics" collection.
+func StatisticsGetNewest() (StatisticSchema, error) {
 
-		i := 0
+	return store.StatisticsGetNewest()
+}
 
-		cursor, err := Statistics.Find(context.TODO(), bson.M{}, options.Find().SetSort(bson.M{"date": -1}))
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "StatisticsRemoveOldEntries(): Failed to find statistic entries: %s\n", err)
-			continue
-		}
+// StatisticsGets returns every entry in the "statistics".
+func StatisticsGets() ([]StatisticSchema, error) {
 
-		for cursor.Next(context.TODO()) {
+	return store.StatisticsGets()
+}
 
-			if i <= MaxStatisticsEntry {
-				i++
-				continue
-			}
+// StatisticsTLDInsert counts the names and the distinct domains for every TLD in the "domains" collection
+// and replace the entry in the "tldStatistics" collection.
+//
+// This function is **very** slow!
+func StatisticsTLDInsert() error {
 
-			s := new(StatisticSchema)
+	pipeline := bson.A{
+		bson.M{"$group": bson.M{"_id": bson.M{"tld": "$tld", "domain": "$domain"}, "total": bson.M{"$sum": 1}}},
+		bson.M{"$group": bson.M{"_id": "$_id.tld", "total": bson.M{"$sum": "$total"}, "domains": bson.M{"$sum": 1}}},
+		bson.M{"$project": bson.M{"_id": 0, "tld": "$_id", "total": 1, "domains": 1}},
+		bson.M{"$sort": bson.D{{Key: "total", Value: -1}, {Key: "tld", Value: 1}}},
+	}
 
-			err = cursor.Decode(s)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "StatisticsRemoveOldEntries(): Failed to decode: %s\n", err)
-				continue
-			}
+	cursor, err := Domains.Aggregate(context.TODO(), pipeline, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return fmt.Errorf("failed to aggregate: %w", err)
+	}
+	defer cursor.Close(context.TODO())
 
-			_, err := Statistics.DeleteOne(context.TODO(), *s)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "StatisticsRemoveOldEntries(): Failed to remove entry (date: %d, total: %d, updated: %d, valid: %d): %s\n", s.Date, s.Total, s.Updated, s.Valid, err)
-			}
-		}
+	s := TLDStatisticsSchema{TLDs: make([]TLDStatisticSchema, 0)}
 
-		err = cursor.Err()
+	for cursor.Next(context.TODO()) {
+
+		t := new(TLDStatisticSchema)
+
+		err = cursor.Decode(t)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "StatisticsRemoveOldEntries(): Cursor failed: %s\n", err)
+			return fmt.Errorf("failed to decode: %w", err)
 		}
 
-		cursor.Close(context.TODO())
+		s.TLDs = append(s.TLDs, *t)
+	}
 
+	err = cursor.Err()
+	if err != nil {
+		return fmt.Errorf("cursor failed: %w", err)
 	}
+
+	s.Date = time.Now().Unix()
+
+	_, err = TLDStatistics.ReplaceOne(context.TODO(), bson.M{}, s, options.Replace().SetUpsert(true))
+
+	return err
 }
 
-// StatisticsGetNewest returns the newest entry from the "statistics" collection.
-func StatisticsGetNewest() (StatisticSchema, error) {
+// StatisticsTLDWorker updates the TLD statistic at the beginning and at a random time in an infinite loop.
+//
+// This function is designed to run as a goroutine in the background.
+// The errors are printed to STDERR.
+func StatisticsTLDWorker() {
 
-	s := new(StatisticSchema)
+	err := StatisticsTLDInsert()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to insert new TLD statistic entry: %s\n", err)
+	}
 
-	err := Statistics.FindOne(context.TODO(), bson.M{}, options.FindOne().SetSort(bson.M{"date": -1})).Decode(s)
+	for {
+
+		time.Sleep(time.Duration(rand.Int63n(86400)) * time.Second)
+
+		err := StatisticsTLDInsert()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to insert new TLD statistic entry: %s\n", err)
+		}
+	}
+}
+
+// StatisticsTLDGetNewest returns the newest entry from the "tldStatistics" collection.
+func StatisticsTLDGetNewest() (TLDStatisticsSchema, error) {
+
+	s := new(TLDStatisticsSchema)
+
+	err := TLDStatistics.FindOne(context.TODO(), bson.M{}).Decode(s)
 
 	return *s, err
 }
 
-// StatisticsGets returns every entry in the "statistics".
-func StatisticsGets() ([]StatisticSchema, error) {
+// StatisticsDomain returns the statistic of domain d.
+// If d has a subdomain, removes it before the query.
+//
+// If d is invalid return fault.ErrInvalidDomain.
+// If failed to get parts of d, returns fault.ErrGetPartsFailed.
+// If d is a public suffix (eg.: co.uk or a TLD), returns fault.ErrPublicSuffix.
+// If d is not found in the "domains" collection, returns fault.ErrNotFound.
+// If the domain of d is blocked, returns fault.ErrBlocked.
+func StatisticsDomain(d string) (DomainStatisticSchema, error) {
+
+	if !dns.IsValid(d) {
+		return DomainStatisticSchema{}, fault.ErrInvalidDomain
+	}
+
+	d = dns.Clean(d)
+
+	if IsPublicSuffix(d) {
+		return DomainStatisticSchema{}, fault.ErrPublicSuffix
+	}
+
+	p := GetParts(d)
+	if p == nil || p.Domain == "" || p.TLD == "" {
+		return DomainStatisticSchema{}, fault.ErrGetPartsFailed
+	}
+
+	if IsBlocked(p.Domain + "." + p.TLD) {
+		return DomainStatisticSchema{}, fault.ErrBlocked
+	}
 
-	cursor, err := Statistics.Find(context.TODO(), bson.M{}, options.Find().SetSort(bson.M{"date": -1}))
+	s := DomainStatisticSchema{Domain: fmt.Sprintf("%s.%s", p.Domain, p.TLD), Records: make([]RecordTypeStatisticSchema, 0)}
+
+	var err error
+
+	s.Total, err = Domains.CountDocuments(context.TODO(), bson.D{{Key: "domain", Value: p.Domain}, {Key: "tld", Value: p.TLD}})
 	if err != nil {
-		return nil, fmt.Errorf("failed to find: %w", err)
+		return s, fmt.Errorf("failed to count total: %w", err)
+	}
+
+	if s.Total == 0 {
+		return s, fault.ErrNotFound
 	}
-	defer cursor.Close(context.TODO())
 
-	r := make([]StatisticSchema, 0, MaxStatisticsEntry)
+	s.Valid, err = Domains.CountDocuments(context.TODO(), bson.D{{Key: "domain", Value: p.Domain}, {Key: "tld", Value: p.TLD}, {Key: "lastRecord", Value: bson.D{{Key: "$exists", Value: true}}}})
+	if err != nil {
+		return s, fmt.Errorf("failed to count valid: %w", err)
+	}
+
+	// Count the distinct records of the names by type
+	pipeline := bson.A{
+		bson.M{"$match": bson.D{{Key: "meta.domain", Value: p.Domain}, {Key: "meta.tld", Value: p.TLD}}},
+		bson.M{"$group": bson.M{"_id": bson.M{"sub": "$meta.sub", "type": "$meta.type", "value": "$meta.value"}}},
+		bson.M{"$group": bson.M{"_id": "$_id.type", "count": bson.M{"$sum": 1}}},
+		bson.M{"$sort": bson.M{"_id": 1}},
+	}
+
+	cursor, err := DNSRecords.Aggregate(context.TODO(), pipeline, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return s, fmt.Errorf("failed to aggregate: %w", err)
+	}
+	defer cursor.Close(context.TODO())
 
 	for cursor.Next(context.TODO()) {
 
-		s := new(StatisticSchema)
+		r := new(RecordTypeStatisticSchema)
 
-		err = cursor.Decode(s)
+		err = cursor.Decode(r)
 		if err != nil {
-			return nil, fmt.Errorf("failed to decode: %w", err)
+			return s, fmt.Errorf("failed to decode: %w", err)
 		}
 
-		r = append(r, *s)
+		s.Records = append(s.Records, *r)
 	}
 
 	err = cursor.Err()
 	if err != nil {
-		return nil, fmt.Errorf("cursor failed: %w", err)
+		return s, fmt.Errorf("cursor failed: %w", err)
 	}
 
-	return r, nil
+	dom := new(DomainSchema)
+
+	err = Domains.FindOne(context.TODO(), bson.D{{Key: "domain", Value: p.Domain}, {Key: "tld", Value: p.TLD}}, options.FindOne().SetSort(bson.M{"updated": -1}).SetProjection(domainProjection)).Decode(dom)
+	if err != nil {
+		return s, fmt.Errorf("failed to find last updated: %w", err)
+	}
+
+	s.Updated = dom.Updated
+
+	return s, nil
 }
@@ -0,0 +1,156 @@
+package db
+
+import (
+	"github.com/elmasy-com/elnet/dns"
+)
+
+// Store is the storage backend of the names, the records, the statistics and the CT logs.
+//
+// The methods get validated and Clean()ed parts, the validation, the caching and the formatting of the results are done by the package level functions (eg.: Lookup()).
+// Use SetStore() to select the implementation, MongoStore is set by Connect().
+type Store interface {
+
+	// Insert inserts the name with parts p if not exists and updates the source of the name.
+	// If source is new for the name, it is appended to the sources, else the last seen time is updated.
+	// Returns whether the name is new and whether the source is new.
+	Insert(p *dns.Parts, source string) (bool, bool, error)
+
+	// Find returns the names of the domain in p that match f (see LookupFilter) without the records.
+	// The subdomain of p is used only if f.Subtree is true.
+	Find(p *dns.Parts, f LookupFilter) ([]DomainSchema, error)
+
+	// Version returns the number of the names of the domain in p that match f (see Find()) and the newest timestamps of them.
+	Version(p *dns.Parts, f LookupFilter) (VersionSchema, error)
+
+	// Get returns the name with parts p.
+	// If the name is not found, returns fault.ErrNotFound.
+	Get(p *dns.Parts) (*DomainSchema, error)
+
+	// Each calls fn with every name that match f (see ExportFilter) without the records, sorted by domain, TLD and subdomain.
+	// If fn returns an error, the iteration stops and the error is returned.
+	Each(f ExportFilter, fn func(d *DomainSchema) error) error
+
+	// Import inserts the name d if not exists and merges the sources and the "updated" timestamp of d into the stored name.
+	// The first seen time of a source is the older, the last seen time is the newer one. The records of d are not stored.
+	// Returns whether the name is new.
+	Import(d *DomainSchema) (bool, error)
+
+	// TLDs returns the distinct TLDs of the Second Level Domain d (eg.: "example").
+	TLDs(d string) ([]string, error)
+
+	// Starts returns the distinct Second Level Domains that starts with d.
+	Starts(d string) ([]string, error)
+
+	// SetUpdated sets the "updated" timestamp of the name with parts p to t.
+	SetUpdated(p *dns.Parts, t int64) error
+
+	// InsertRecords stores the records rs observed for the name with parts p and updates the "lastRecord" timestamp of the name.
+	// Returns whether any type and value in rs is new for the name.
+	InsertRecords(p *dns.Parts, rs []RecordSchema) (bool, error)
+
+	// Records returns the records of the names of the domain in p by subdomain.
+	// Every type and value is returned once with the time of the newest observation.
+	// The records must match f.Types and f.Value and must be observed in the previous f.Days days if f.Days > 0.
+	// If exact is true, returns the records of the name p only.
+	Records(p *dns.Parts, f LookupFilter, exact bool) (map[string][]RecordSchema, error)
+
+	// PruneNames removes the names that were tried to resolve since before (Unix timestamp, see SetUpdated()), but has no record since before
+	// and every source of the name is first seen before before.
+	// The names that are not tried to resolve since before are kept, the missing record may be caused by the missing check.
+	// If archive is true, the names are moved to the archive with the records, else deleted with the records.
+	// fn is called with every matched name. If dryRun is true, nothing is removed.
+	// Returns the number of matched names.
+	PruneNames(before int64, archive bool, dryRun bool, fn func(d *DomainSchema)) (int64, error)
+
+	// TrimRecords removes the records observed before the previous days days and clears the "lastRecord" timestamp of the names without newer record.
+	// If days is 0, the policy set by a previous call is cleared (eg.: the TTL of the records collection).
+	// If dryRun is true, nothing is removed.
+	// Returns the number of the removed records.
+	TrimRecords(days int, dryRun bool) (int64, error)
+
+	// CapRecords keeps the max most recently observed values of every record type of every name and removes the others.
+	// If dryRun is true, nothing is removed.
+	// Returns the number of the removed values.
+	CapRecords(max int, dryRun bool) (int64, error)
+
+	// CountTotal returns the total number of names.
+	CountTotal() (int64, error)
+
+	// CountUpdated returns the number of names that updated at least once.
+	CountUpdated() (int64, error)
+
+	// CountValid returns the number of names that have at least one record.
+	CountValid() (int64, error)
+
+	// StatisticsInsert inserts a new statistic entry.
+	StatisticsInsert(s StatisticSchema) error
+
+	// StatisticsGets returns every statistic entry, the newest first.
+	StatisticsGets() ([]StatisticSchema, error)
+
+	// StatisticsGetNewest returns the newest statistic entry.
+	StatisticsGetNewest() (StatisticSchema, error)
+
+	// StatisticsClean removes the oldest statistic entries beyond max number.
+	StatisticsClean(max int) error
+
+	// CTLogsUpdate updates the stat for the CT log with name name.
+	CTLogsUpdate(name string, index int64, size int64) error
+
+	// CTLogsGet returns the stat for the CT log with name name.
+	CTLogsGet(name string) (*CTLogSchema, error)
+
+	// CTLogsGets returns the stat of every CT log sorted by name.
+	CTLogsGets() ([]CTLogSchema, error)
+
+	// BlockedGets returns every blocked entry sorted by pattern.
+	BlockedGets() ([]BlockedSchema, error)
+
+	// BlockedInsert inserts the blocked entry b if no entry exists with the same pattern.
+	// Returns whether b is inserted.
+	BlockedInsert(b BlockedSchema) (bool, error)
+
+	// BlockedDelete removes the blocked entry with pattern pattern.
+	// Returns whether the entry existed.
+	BlockedDelete(pattern string) (bool, error)
+
+	// BlockedAuditInsert appends a to the audit trail of the blocked entries.
+	BlockedAuditInsert(a BlockedAuditSchema) error
+
+	// BlockedAuditGets returns the newest limit entries of the audit trail, the newest first.
+	BlockedAuditGets(limit int) ([]BlockedAuditSchema, error)
+
+	// SettingGet returns the value of the setting key applied to the stored data (eg.: the split of the names).
+	// If the setting is not stored, returns fault.ErrNotFound.
+	SettingGet(key string) (string, error)
+
+	// SettingSet stores the value of the setting key.
+	SettingSet(key string, value string) error
+
+	// Resplit splits the stored names again with GetParts() and moves the names with changed parts (eg.: after config.PrivateSuffixes is changed).
+	// A name that exists with the new parts is merged into the existing one.
+	// The names that are public suffixes with the current split are kept unchanged.
+	// Returns the number of moved names.
+	Resplit() (int64, error)
+
+	// Close closes the store.
+	Close() error
+}
+
+var store Store
+
+// SetStore sets the storage backend to s.
+func SetStore(s Store) {
+	store = s
+}
+
+// MongoDB returns whether the MongoDB store is used.
+//
+// The top list, the not found domains, the wordlist, the TLD and domain statistics, the lookalike and contains search,
+// the Bloom filter and the migrations are available only with MongoDB.
+func MongoDB() bool {
+
+	_, ok := store.(*MongoStore)
+
+	return ok
+}
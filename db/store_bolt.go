@@ -0,0 +1,1067 @@
+package db
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/elmasy-com/columbus-server/fault"
+	"github.com/elmasy-com/elnet/dns"
+	"github.com/elmasy-com/slices"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	boltDomains    = []byte("domains")
+	boltStatistics = []byte("statistics")
+	boltCTLogs     = []byte("ctlogs")
+	boltArchive    = []byte("archive")
+	boltBlocked    = []byte("blocked")
+	boltAudit      = []byte("blockedAudit")
+	boltSettings   = []byte("settings")
+)
+
+// BoltStore is the embedded Store implementation that stores everything in a single bbolt file.
+//
+// The names are stored as JSON in the "domains" bucket with a "domain\x00tld\x00sub" key,
+// so the names of a domain, the TLDs of a domain and the domains with a prefix are found with a cursor over the sorted keys.
+// The filters are applied after reading the names of the domain (see LookupFilter.match()).
+type BoltStore struct {
+	db *bolt.DB
+}
+
+// NewBoltStore opens the bbolt file in path and creates the buckets.
+// The file is created if not exists.
+func NewBoltStore(path string) (*BoltStore, error) {
+
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+
+		for _, name := range [][]byte{boltDomains, boltStatistics, boltCTLogs, boltArchive, boltBlocked, boltAudit, boltSettings} {
+
+			_, err := tx.CreateBucketIfNotExists(name)
+			if err != nil {
+				return fmt.Errorf("failed to create bucket %s: %w", name, err)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &BoltStore{db: db}, nil
+}
+
+// boltDomainPrefix returns the key prefix of the names of domain d with TLD tld.
+func boltDomainPrefix(d string, tld string) []byte {
+	return []byte(d + "\x00" + tld + "\x00")
+}
+
+// boltDomainKey returns the key of the name with parts p.
+func boltDomainKey(p *dns.Parts) []byte {
+	return append(boltDomainPrefix(p.Domain, p.TLD), p.Sub...)
+}
+
+// boltGet returns the name stored in bucket b with key k.
+// If the name is not found, returns fault.ErrNotFound.
+func boltGet(b *bolt.Bucket, k []byte) (*DomainSchema, error) {
+
+	v := b.Get(k)
+	if v == nil {
+		return nil, fault.ErrNotFound
+	}
+
+	d := new(DomainSchema)
+
+	err := json.Unmarshal(v, d)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal %s: %w", k, err)
+	}
+
+	return d, nil
+}
+
+// boltPut stores the name d in bucket b with key k.
+func boltPut(b *bolt.Bucket, k []byte, d *DomainSchema) error {
+
+	v, err := json.Marshal(d)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s: %w", k, err)
+	}
+
+	return b.Put(k, v)
+}
+
+// count counts the names that match fn.
+func (s *BoltStore) count(fn func(d *DomainSchema) bool) (int64, error) {
+
+	var n int64
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+
+		return tx.Bucket(boltDomains).ForEach(func(k, v []byte) error {
+
+			d := new(DomainSchema)
+
+			err := json.Unmarshal(v, d)
+			if err != nil {
+				return fmt.Errorf("failed to unmarshal %s: %w", k, err)
+			}
+
+			if fn(d) {
+				n++
+			}
+
+			return nil
+		})
+	})
+
+	return n, err
+}
+
+func (s *BoltStore) Insert(p *dns.Parts, source string) (bool, bool, error) {
+
+	var isNew, newSource bool
+
+	err := s.db.Update(func(tx *bolt.Tx) error {
+
+		b := tx.Bucket(boltDomains)
+		k := boltDomainKey(p)
+
+		d, err := boltGet(b, k)
+		if err != nil && !errors.Is(err, fault.ErrNotFound) {
+			return err
+		}
+
+		if d == nil {
+			isNew = true
+			d = &DomainSchema{Domain: p.Domain, TLD: p.TLD, Sub: p.Sub}
+		}
+
+		now := time.Now().Unix()
+		newSource = true
+
+		for i := range d.Sources {
+			if d.Sources[i].Name == source {
+				d.Sources[i].Last = now
+				newSource = false
+				break
+			}
+		}
+
+		if newSource {
+			d.Sources = append(d.Sources, SourceSchema{Name: source, First: now, Last: now})
+		}
+
+		return boltPut(b, k, d)
+	})
+
+	return isNew, newSource, err
+}
+
+func (s *BoltStore) Find(p *dns.Parts, f LookupFilter) ([]DomainSchema, error) {
+
+	if f.Days < -1 {
+		return nil, fault.ErrInvalidDays
+	}
+
+	var ds []DomainSchema
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+
+		c := tx.Bucket(boltDomains).Cursor()
+		prefix := boltDomainPrefix(p.Domain, p.TLD)
+
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+
+			d := new(DomainSchema)
+
+			err := json.Unmarshal(v, d)
+			if err != nil {
+				return fmt.Errorf("failed to unmarshal %s: %w", k, err)
+			}
+
+			if !f.match(p, d) {
+				continue
+			}
+
+			d.Records = nil
+
+			ds = append(ds, *d)
+		}
+
+		return nil
+	})
+
+	return ds, err
+}
+
+func (s *BoltStore) Version(p *dns.Parts, f LookupFilter) (VersionSchema, error) {
+
+	var v VersionSchema
+
+	ds, err := s.Find(p, f)
+	if err != nil {
+		return v, err
+	}
+
+	for i := range ds {
+		v.add(&ds[i])
+	}
+
+	return v, nil
+}
+
+func (s *BoltStore) Get(p *dns.Parts) (*DomainSchema, error) {
+
+	var d *DomainSchema
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+
+		var err error
+
+		d, err = boltGet(tx.Bucket(boltDomains), boltDomainKey(p))
+
+		return err
+	})
+
+	return d, err
+}
+
+func (s *BoltStore) Each(f ExportFilter, fn func(d *DomainSchema) error) error {
+
+	return s.db.View(func(tx *bolt.Tx) error {
+
+		c := tx.Bucket(boltDomains).Cursor()
+
+		var prefix []byte
+
+		if f.Domain != "" {
+			prefix = append([]byte(f.Domain), 0)
+		}
+		if f.Domain != "" && f.TLD != "" {
+			prefix = boltDomainPrefix(f.Domain, f.TLD)
+		}
+
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+
+			d := new(DomainSchema)
+
+			err := json.Unmarshal(v, d)
+			if err != nil {
+				return fmt.Errorf("failed to unmarshal %s: %w", k, err)
+			}
+
+			if !f.match(d) {
+				continue
+			}
+
+			d.Records = nil
+
+			err = fn(d)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func (s *BoltStore) Import(v *DomainSchema) (bool, error) {
+
+	var isNew bool
+
+	err := s.db.Update(func(tx *bolt.Tx) error {
+
+		b := tx.Bucket(boltDomains)
+		k := boltDomainKey(&dns.Parts{Domain: v.Domain, TLD: v.TLD, Sub: v.Sub})
+
+		d, err := boltGet(b, k)
+		if err != nil && !errors.Is(err, fault.ErrNotFound) {
+			return err
+		}
+
+		if d == nil {
+			isNew = true
+			d = &DomainSchema{Domain: v.Domain, TLD: v.TLD, Sub: v.Sub}
+		}
+
+		if v.Updated > d.Updated {
+			d.Updated = v.Updated
+		}
+
+	sources:
+		for _, src := range v.Sources {
+
+			for i := range d.Sources {
+
+				if d.Sources[i].Name != src.Name {
+					continue
+				}
+
+				if src.First < d.Sources[i].First {
+					d.Sources[i].First = src.First
+				}
+				if src.Last > d.Sources[i].Last {
+					d.Sources[i].Last = src.Last
+				}
+
+				continue sources
+			}
+
+			d.Sources = append(d.Sources, src)
+		}
+
+		return boltPut(b, k, d)
+	})
+
+	return isNew, err
+}
+
+func (s *BoltStore) TLDs(d string) ([]string, error) {
+
+	var tlds []string
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+
+		c := tx.Bucket(boltDomains).Cursor()
+		prefix := []byte(d + "\x00")
+
+		for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+
+			// The key is "domain\x00tld\x00sub"
+			parts := bytes.SplitN(k, []byte{0}, 3)
+
+			tlds = slices.AppendUnique(tlds, string(parts[1]))
+		}
+
+		return nil
+	})
+
+	return tlds, err
+}
+
+func (s *BoltStore) Starts(d string) ([]string, error) {
+
+	var domains []string
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+
+		c := tx.Bucket(boltDomains).Cursor()
+		prefix := []byte(d)
+
+		for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+			domains = slices.AppendUnique(domains, string(k[:bytes.IndexByte(k, 0)]))
+		}
+
+		return nil
+	})
+
+	return domains, err
+}
+
+func (s *BoltStore) SetUpdated(p *dns.Parts, t int64) error {
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+
+		b := tx.Bucket(boltDomains)
+		k := boltDomainKey(p)
+
+		d, err := boltGet(b, k)
+		if errors.Is(err, fault.ErrNotFound) {
+			// Same as an update without match in MongoDB
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		d.Updated = t
+
+		return boltPut(b, k, d)
+	})
+}
+
+func (s *BoltStore) InsertRecords(p *dns.Parts, rs []RecordSchema) (bool, error) {
+
+	isNew := false
+
+	err := s.db.Update(func(tx *bolt.Tx) error {
+
+		b := tx.Bucket(boltDomains)
+		k := boltDomainKey(p)
+
+		d, err := boltGet(b, k)
+		if errors.Is(err, fault.ErrNotFound) {
+			// Same as an update without match in MongoDB
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		// The embedded store keeps only the newest observation of every type and value
+		for _, r := range rs {
+
+			if r.Time > d.LastRecord {
+				d.LastRecord = r.Time
+			}
+
+			found := false
+
+			for i := range d.Records {
+				if d.Records[i].Type == r.Type && d.Records[i].Value == r.Value {
+					d.Records[i].Time = r.Time
+					found = true
+					break
+				}
+			}
+
+			if !found {
+				d.Records = append(d.Records, r)
+				isNew = true
+			}
+		}
+
+		return boltPut(b, k, d)
+	})
+
+	return isNew, err
+}
+
+func (s *BoltStore) Records(p *dns.Parts, f LookupFilter, exact bool) (map[string][]RecordSchema, error) {
+
+	var (
+		records = make(map[string][]RecordSchema)
+		after   = time.Now().AddDate(0, 0, -1*f.Days).Unix()
+		prefix  = boltDomainPrefix(p.Domain, p.TLD)
+	)
+
+	if exact {
+		prefix = boltDomainKey(p)
+	}
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+
+		c := tx.Bucket(boltDomains).Cursor()
+
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+
+			// The prefix of the exact key matches the longer subdomains too (eg.: "www" -> "www2")
+			if exact && !bytes.Equal(k, prefix) {
+				break
+			}
+
+			d := new(DomainSchema)
+
+			err := json.Unmarshal(v, d)
+			if err != nil {
+				return fmt.Errorf("failed to unmarshal %s: %w", k, err)
+			}
+
+			for i := range d.Records {
+				if (f.Days <= 0 || d.Records[i].Time > after) && f.recordMatch(d.Records[i]) {
+					records[d.Sub] = append(records[d.Sub], d.Records[i])
+				}
+			}
+		}
+
+		return nil
+	})
+
+	return records, err
+}
+
+// rewrite calls fn with every name and stores the names where fn returns > 0.
+// The changes are written after the iteration, because changing the bucket invalidates the cursor.
+// If dryRun is true, nothing is stored.
+// Returns the sum of the values returned by fn.
+func (s *BoltStore) rewrite(dryRun bool, fn func(d *DomainSchema) int64) (int64, error) {
+
+	var n int64
+
+	err := s.db.Update(func(tx *bolt.Tx) error {
+
+		b := tx.Bucket(boltDomains)
+		c := b.Cursor()
+
+		changed := make(map[string]*DomainSchema)
+
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+
+			d := new(DomainSchema)
+
+			err := json.Unmarshal(v, d)
+			if err != nil {
+				return fmt.Errorf("failed to unmarshal %s: %w", k, err)
+			}
+
+			if r := fn(d); r > 0 {
+				n += r
+				changed[string(k)] = d
+			}
+		}
+
+		if dryRun {
+			return nil
+		}
+
+		for k, d := range changed {
+			if err := boltPut(b, []byte(k), d); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	return n, err
+}
+
+func (s *BoltStore) PruneNames(before int64, archive bool, dryRun bool, fn func(d *DomainSchema)) (int64, error) {
+
+	var n int64
+
+	err := s.db.Update(func(tx *bolt.Tx) error {
+
+		b := tx.Bucket(boltDomains)
+		c := b.Cursor()
+
+		var keys [][]byte
+
+	names:
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+
+			d := new(DomainSchema)
+
+			err := json.Unmarshal(v, d)
+			if err != nil {
+				return fmt.Errorf("failed to unmarshal %s: %w", k, err)
+			}
+
+			if d.Updated < before || (d.LastRecord != 0 && d.LastRecord >= before) {
+				continue
+			}
+
+			for i := range d.Sources {
+				if d.Sources[i].First >= before {
+					continue names
+				}
+			}
+
+			n++
+			fn(d)
+
+			if dryRun {
+				continue
+			}
+
+			// The key is valid only until the bucket is changed
+			k = append([]byte{}, k...)
+
+			if archive {
+
+				out, err := json.Marshal(ArchivedDomainSchema{DomainSchema: *d, Archived: time.Now().Unix()})
+				if err != nil {
+					return fmt.Errorf("failed to marshal %s: %w", k, err)
+				}
+
+				err = tx.Bucket(boltArchive).Put(k, out)
+				if err != nil {
+					return fmt.Errorf("failed to archive %s: %w", k, err)
+				}
+			}
+
+			keys = append(keys, k)
+		}
+
+		for i := range keys {
+			if err := b.Delete(keys[i]); err != nil {
+				return fmt.Errorf("failed to delete %s: %w", keys[i], err)
+			}
+		}
+
+		return nil
+	})
+
+	return n, err
+}
+
+func (s *BoltStore) TrimRecords(days int, dryRun bool) (int64, error) {
+
+	if days == 0 {
+		return 0, nil
+	}
+
+	before := time.Now().AddDate(0, 0, -1*days).Unix()
+
+	return s.rewrite(dryRun, func(d *DomainSchema) int64 {
+
+		var (
+			rs   []RecordSchema
+			last int64
+		)
+
+		for i := range d.Records {
+
+			if d.Records[i].Time < before {
+				continue
+			}
+
+			rs = append(rs, d.Records[i])
+
+			if d.Records[i].Time > last {
+				last = d.Records[i].Time
+			}
+		}
+
+		n := int64(len(d.Records) - len(rs))
+
+		d.Records = rs
+		d.LastRecord = last
+
+		return n
+	})
+}
+
+func (s *BoltStore) CapRecords(max int, dryRun bool) (int64, error) {
+
+	return s.rewrite(dryRun, func(d *DomainSchema) int64 {
+
+		// The newest values first
+		sort.SliceStable(d.Records, func(i, j int) bool { return d.Records[i].Time > d.Records[j].Time })
+
+		var (
+			rs    []RecordSchema
+			count = make(map[uint16]int)
+		)
+
+		for i := range d.Records {
+
+			if count[d.Records[i].Type] >= max {
+				continue
+			}
+
+			count[d.Records[i].Type]++
+			rs = append(rs, d.Records[i])
+		}
+
+		n := int64(len(d.Records) - len(rs))
+
+		d.Records = rs
+
+		return n
+	})
+}
+
+func (s *BoltStore) CountTotal() (int64, error) {
+
+	var n int64
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		n = int64(tx.Bucket(boltDomains).Stats().KeyN)
+		return nil
+	})
+
+	return n, err
+}
+
+func (s *BoltStore) CountUpdated() (int64, error) {
+
+	return s.count(func(d *DomainSchema) bool { return d.Updated != 0 })
+}
+
+func (s *BoltStore) CountValid() (int64, error) {
+
+	return s.count(func(d *DomainSchema) bool { return len(d.Records) > 0 })
+}
+
+func (s *BoltStore) StatisticsInsert(v StatisticSchema) error {
+
+	out, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to marshal: %w", err)
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+
+		b := tx.Bucket(boltStatistics)
+
+		// The sequence keeps the insertion order, the newest is the last
+		seq, err := b.NextSequence()
+		if err != nil {
+			return fmt.Errorf("failed to get next sequence: %w", err)
+		}
+
+		k := make([]byte, 8)
+		binary.BigEndian.PutUint64(k, seq)
+
+		return b.Put(k, out)
+	})
+}
+
+func (s *BoltStore) StatisticsGets() ([]StatisticSchema, error) {
+
+	r := make([]StatisticSchema, 0, MaxStatisticsEntry)
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+
+		c := tx.Bucket(boltStatistics).Cursor()
+
+		for k, v := c.Last(); k != nil; k, v = c.Prev() {
+
+			var v2 StatisticSchema
+
+			err := json.Unmarshal(v, &v2)
+			if err != nil {
+				return fmt.Errorf("failed to unmarshal: %w", err)
+			}
+
+			r = append(r, v2)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (s *BoltStore) StatisticsGetNewest() (StatisticSchema, error) {
+
+	var v StatisticSchema
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+
+		_, out := tx.Bucket(boltStatistics).Cursor().Last()
+		if out == nil {
+			return fault.ErrNotFound
+		}
+
+		return json.Unmarshal(out, &v)
+	})
+
+	return v, err
+}
+
+func (s *BoltStore) StatisticsClean(max int) error {
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+
+		b := tx.Bucket(boltStatistics)
+		n := b.Stats().KeyN
+
+		var keys [][]byte
+
+		// The oldest entries are at the beginning
+		c := b.Cursor()
+
+		for k, _ := c.First(); k != nil && n-len(keys) > max; k, _ = c.Next() {
+			keys = append(keys, k)
+		}
+
+		for i := range keys {
+
+			err := b.Delete(keys[i])
+			if err != nil {
+				return fmt.Errorf("failed to remove entry: %w", err)
+			}
+		}
+
+		return nil
+	})
+}
+
+func (s *BoltStore) CTLogsUpdate(name string, index int64, size int64) error {
+
+	out, err := json.Marshal(CTLogSchema{Name: name, Index: index, Size: size})
+	if err != nil {
+		return fmt.Errorf("failed to marshal: %w", err)
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltCTLogs).Put([]byte(name), out)
+	})
+}
+
+func (s *BoltStore) CTLogsGet(name string) (*CTLogSchema, error) {
+
+	v := new(CTLogSchema)
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+
+		out := tx.Bucket(boltCTLogs).Get([]byte(name))
+		if out == nil {
+			return fault.ErrNotFound
+		}
+
+		return json.Unmarshal(out, v)
+	})
+
+	return v, err
+}
+
+func (s *BoltStore) CTLogsGets() ([]CTLogSchema, error) {
+
+	scs := make([]CTLogSchema, 0)
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+
+		// The keys are the names, ForEach iterates in sorted order
+		return tx.Bucket(boltCTLogs).ForEach(func(k, v []byte) error {
+
+			var sc CTLogSchema
+
+			err := json.Unmarshal(v, &sc)
+			if err != nil {
+				return fmt.Errorf("failed to unmarshal %s: %w", k, err)
+			}
+
+			scs = append(scs, sc)
+
+			return nil
+		})
+	})
+
+	return scs, err
+}
+
+func (s *BoltStore) BlockedGets() ([]BlockedSchema, error) {
+
+	var bs []BlockedSchema
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+
+		// The keys are the patterns, ForEach iterates in sorted order
+		return tx.Bucket(boltBlocked).ForEach(func(k, v []byte) error {
+
+			var b BlockedSchema
+
+			err := json.Unmarshal(v, &b)
+			if err != nil {
+				return fmt.Errorf("failed to unmarshal %s: %w", k, err)
+			}
+
+			bs = append(bs, b)
+
+			return nil
+		})
+	})
+
+	return bs, err
+}
+
+func (s *BoltStore) BlockedInsert(v BlockedSchema) (bool, error) {
+
+	out, err := json.Marshal(v)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal: %w", err)
+	}
+
+	inserted := false
+
+	err = s.db.Update(func(tx *bolt.Tx) error {
+
+		b := tx.Bucket(boltBlocked)
+
+		if b.Get([]byte(v.Pattern)) != nil {
+			return nil
+		}
+
+		inserted = true
+
+		return b.Put([]byte(v.Pattern), out)
+	})
+
+	return inserted, err
+}
+
+func (s *BoltStore) BlockedDelete(pattern string) (bool, error) {
+
+	deleted := false
+
+	err := s.db.Update(func(tx *bolt.Tx) error {
+
+		b := tx.Bucket(boltBlocked)
+
+		if b.Get([]byte(pattern)) == nil {
+			return nil
+		}
+
+		deleted = true
+
+		return b.Delete([]byte(pattern))
+	})
+
+	return deleted, err
+}
+
+func (s *BoltStore) BlockedAuditInsert(v BlockedAuditSchema) error {
+
+	out, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to marshal: %w", err)
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+
+		b := tx.Bucket(boltAudit)
+
+		// The sequence keeps the insertion order, the newest is the last
+		seq, err := b.NextSequence()
+		if err != nil {
+			return fmt.Errorf("failed to get next sequence: %w", err)
+		}
+
+		k := make([]byte, 8)
+		binary.BigEndian.PutUint64(k, seq)
+
+		return b.Put(k, out)
+	})
+}
+
+func (s *BoltStore) BlockedAuditGets(limit int) ([]BlockedAuditSchema, error) {
+
+	as := make([]BlockedAuditSchema, 0, limit)
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+
+		c := tx.Bucket(boltAudit).Cursor()
+
+		for k, v := c.Last(); k != nil && len(as) < limit; k, v = c.Prev() {
+
+			var a BlockedAuditSchema
+
+			err := json.Unmarshal(v, &a)
+			if err != nil {
+				return fmt.Errorf("failed to unmarshal: %w", err)
+			}
+
+			as = append(as, a)
+		}
+
+		return nil
+	})
+
+	return as, err
+}
+
+func (s *BoltStore) SettingGet(key string) (string, error) {
+
+	var v []byte
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		v = tx.Bucket(boltSettings).Get([]byte(key))
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if v == nil {
+		return "", fault.ErrNotFound
+	}
+
+	return string(v), nil
+}
+
+func (s *BoltStore) SettingSet(key string, value string) error {
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltSettings).Put([]byte(key), []byte(value))
+	})
+}
+
+func (s *BoltStore) Resplit() (int64, error) {
+
+	var n int64
+
+	err := s.db.Update(func(tx *bolt.Tx) error {
+
+		b := tx.Bucket(boltDomains)
+
+		var moved []*DomainSchema
+
+		err := b.ForEach(func(k, v []byte) error {
+
+			d := new(DomainSchema)
+
+			err := json.Unmarshal(v, d)
+			if err != nil {
+				return fmt.Errorf("failed to unmarshal %s: %w", k, err)
+			}
+
+			p := GetParts(d.String())
+			if p == nil || (p.Domain == d.Domain && p.TLD == d.TLD && p.Sub == d.Sub) {
+				return nil
+			}
+
+			moved = append(moved, d)
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		// The bucket can not be modified in ForEach
+		for _, d := range moved {
+
+			err = b.Delete(boltDomainKey(&dns.Parts{Domain: d.Domain, TLD: d.TLD, Sub: d.Sub}))
+			if err != nil {
+				return fmt.Errorf("failed to delete %s: %w", d.String(), err)
+			}
+
+			p := GetParts(d.String())
+			k := boltDomainKey(p)
+
+			d.Domain, d.TLD, d.Sub = p.Domain, p.TLD, p.Sub
+
+			cur, err := boltGet(b, k)
+			if err == nil {
+
+				if cur.Updated > d.Updated {
+					d.Updated = cur.Updated
+				}
+				if cur.LastRecord > d.LastRecord {
+					d.LastRecord = cur.LastRecord
+				}
+
+				d.Sources = mergeSources([][]SourceSchema{cur.Sources, d.Sources})
+				d.Records = mergeRecords([][]RecordSchema{cur.Records, d.Records})
+
+			} else if !errors.Is(err, fault.ErrNotFound) {
+				return err
+			}
+
+			err = boltPut(b, k, d)
+			if err != nil {
+				return err
+			}
+
+			n++
+		}
+
+		return nil
+	})
+
+	return n, err
+}
+
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
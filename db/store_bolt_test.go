@@ -0,0 +1,109 @@
+package db
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/elmasy-com/columbus-server/fault"
+	"github.com/elmasy-com/elnet/dns"
+)
+
+func TestBoltStore(t *testing.T) {
+
+	s, err := NewBoltStore(filepath.Join(t.TempDir(), "columbus.db"))
+	if err != nil {
+		t.Fatalf("FAIL: %s\n", err)
+	}
+	defer s.Close()
+
+	www := &dns.Parts{Sub: "www", Domain: "example", TLD: "com"}
+	api := &dns.Parts{Sub: "api.corp", Domain: "example", TLD: "com"}
+
+	for _, p := range []*dns.Parts{www, api, {Domain: "example", TLD: "org"}, {Domain: "examples", TLD: "net"}} {
+
+		isNew, newSource, err := s.Insert(p, SourceCT)
+		if err != nil {
+			t.Fatalf("FAIL: failed to insert %v: %s\n", p, err)
+		}
+		if !isNew || !newSource {
+			t.Fatalf("FAIL: %v is not new\n", p)
+		}
+	}
+
+	isNew, newSource, err := s.Insert(www, SourceCT)
+	if err != nil || isNew || newSource {
+		t.Fatalf("FAIL: second insert of www: new=%v, newSource=%v, err=%v\n", isNew, newSource, err)
+	}
+
+	for i := int64(1); i <= 2; i++ {
+
+		isNew, err := s.InsertRecords(www, []RecordSchema{{Type: dns.TypeA, Value: "192.0.2.1", Time: i}})
+		if err != nil {
+			t.Fatalf("FAIL: failed to insert records: %s\n", err)
+		}
+		if isNew != (i == 1) {
+			t.Fatalf("FAIL: insert %d returned new=%v\n", i, isNew)
+		}
+	}
+
+	rs, err := s.Records(www, LookupFilter{}, true)
+	if err != nil {
+		t.Fatalf("FAIL: failed to get records: %s\n", err)
+	}
+	if len(rs["www"]) != 1 || rs["www"][0].Time != 2 {
+		t.Fatalf("FAIL: invalid records: %v\n", rs)
+	}
+
+	if d, err := s.Get(www); err != nil || d.LastRecord != 2 {
+		t.Fatalf("FAIL: invalid lastRecord: %v, %v\n", d, err)
+	}
+
+	if _, err = s.Get(&dns.Parts{Sub: "mail", Domain: "example", TLD: "com"}); !errors.Is(err, fault.ErrNotFound) {
+		t.Fatalf("FAIL: unknown name returned %v\n", err)
+	}
+
+	ds, err := s.Find(www, LookupFilter{Days: -1})
+	if err != nil || len(ds) != 2 {
+		t.Fatalf("FAIL: find every name returned %v, %v\n", ds, err)
+	}
+
+	ds, err = s.Find(www, LookupFilter{Days: 0})
+	if err != nil || len(ds) != 1 || ds[0].Sub != "www" {
+		t.Fatalf("FAIL: find names with records returned %v, %v\n", ds, err)
+	}
+
+	ds, err = s.Find(&dns.Parts{Sub: "corp", Domain: "example", TLD: "com"}, LookupFilter{Days: -1, Subtree: true})
+	if err != nil || len(ds) != 1 || ds[0].Sub != "api.corp" {
+		t.Fatalf("FAIL: find subtree returned %v, %v\n", ds, err)
+	}
+
+	tlds, err := s.TLDs("example")
+	if err != nil || len(tlds) != 2 {
+		t.Fatalf("FAIL: TLDs returned %v, %v\n", tlds, err)
+	}
+
+	domains, err := s.Starts("examp")
+	if err != nil || len(domains) != 2 {
+		t.Fatalf("FAIL: Starts returned %v, %v\n", domains, err)
+	}
+
+	if n, err := s.CountValid(); err != nil || n != 1 {
+		t.Fatalf("FAIL: CountValid returned %d, %v\n", n, err)
+	}
+
+	for i := int64(1); i <= 3; i++ {
+		if err = s.StatisticsInsert(StatisticSchema{Date: i}); err != nil {
+			t.Fatalf("FAIL: failed to insert statistic: %s\n", err)
+		}
+	}
+
+	if err = s.StatisticsClean(2); err != nil {
+		t.Fatalf("FAIL: failed to clean statistics: %s\n", err)
+	}
+
+	stats, err := s.StatisticsGets()
+	if err != nil || len(stats) != 2 || stats[0].Date != 3 {
+		t.Fatalf("FAIL: StatisticsGets returned %v, %v\n", stats, err)
+	}
+}
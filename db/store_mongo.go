@@ -0,0 +1,1168 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/elmasy-com/columbus-server/fault"
+	"github.com/elmasy-com/elnet/dns"
+	"github.com/elmasy-com/slices"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoStore is the Store implementation that use the MongoDB collections (eg.: Domains).
+// The records are stored in the "records" time-series collection (see DNSRecords).
+type MongoStore struct{}
+
+// The internal fields and the records of the not migrated documents are never returned from "domains".
+var domainProjection = bson.M{"ngrams": 0, "rsub": 0, "records": 0}
+
+// recordsFilter returns the query filter of the "records" collection for the names of the domain in p.
+// The observations must match f.Types and f.Value and must be after the previous f.Days days if f.Days > 0.
+// If exact is true, only the observations of the name p are matched.
+func recordsFilter(p *dns.Parts, f LookupFilter, exact bool) bson.D {
+
+	doc := bson.D{{Key: "meta.domain", Value: p.Domain}, {Key: "meta.tld", Value: p.TLD}}
+
+	if exact {
+		doc = append(doc, bson.E{Key: "meta.sub", Value: p.Sub})
+	}
+
+	if len(f.Types) > 0 {
+		doc = append(doc, bson.E{Key: "meta.type", Value: bson.D{{Key: "$in", Value: f.Types}}})
+	}
+
+	if f.Value != "" {
+		doc = append(doc, bson.E{Key: "meta.value", Value: f.Value})
+	}
+
+	if f.Days > 0 {
+		doc = append(doc, bson.E{Key: "time", Value: bson.D{{Key: "$gt", Value: time.Now().AddDate(0, 0, -1*f.Days)}}})
+	}
+
+	return doc
+}
+
+// MaxRecordsSubs is the maximum number of subdomains with records that match the filter of a lookup, see recordsSubs().
+const MaxRecordsSubs = 100000
+
+// recordsSubs returns the distinct subdomains of the domain in p with records that match f.
+//
+// If more than MaxRecordsSubs subdomains match, returns fault.ErrTooManyNames.
+func recordsSubs(p *dns.Parts, f LookupFilter) ([]string, error) {
+
+	pipeline := bson.A{
+		bson.M{"$match": recordsFilter(p, f, false)},
+		bson.M{"$group": bson.M{"_id": "$meta.sub"}},
+		bson.M{"$limit": MaxRecordsSubs + 1},
+	}
+
+	cursor, err := DNSRecords.Aggregate(context.TODO(), pipeline, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return nil, fmt.Errorf("failed to find subdomains with records: %w", err)
+	}
+
+	var rs []struct {
+		Sub string `bson:"_id"`
+	}
+
+	err = cursor.All(context.TODO(), &rs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode subdomains with records: %w", err)
+	}
+
+	if len(rs) > MaxRecordsSubs {
+		return nil, fault.ErrTooManyNames
+	}
+
+	subs := make([]string, 0, len(rs))
+
+	for i := range rs {
+		subs = append(subs, rs[i].Sub)
+	}
+
+	return subs, nil
+}
+
+// lookupFilter returns the query filter of the "domains" collection for the parts p used in Find().
+// The subdomain of p is used only if f.Subtree is true.
+//
+// If f.Types or f.Value is set, the subdomains with matching records are queried from the "records" collection first.
+//
+// If f.Days if < -1, returns fault.ErrInvalidDays.
+func lookupFilter(p *dns.Parts, f LookupFilter) (bson.D, error) {
+
+	if f.Days < -1 {
+		return nil, fault.ErrInvalidDays
+	}
+
+	doc := bson.D{{Key: "domain", Value: p.Domain}, {Key: "tld", Value: p.TLD}}
+
+	if f.Subtree && p.Sub != "" {
+		// The subdomain itself or the names under it, the anchored prefix match uses the index
+		doc = append(doc, bson.E{Key: "rsub", Value: bson.D{{Key: "$regex", Value: "^" + regexp.QuoteMeta(ReverseLabels(p.Sub)) + `(\.|$)`}}})
+	}
+
+	switch {
+	case len(f.Types) > 0 || f.Value != "":
+
+		// Days, Types and Value must match the same record
+		subs, err := recordsSubs(p, f)
+		if err != nil {
+			return nil, err
+		}
+
+		doc = append(doc, bson.E{Key: "sub", Value: bson.D{{Key: "$in", Value: subs}}})
+
+		// The names without newer record are filtered with the index too
+		if f.Days > 0 {
+			doc = append(doc, bson.E{Key: "lastRecord", Value: bson.D{{Key: "$gt", Value: time.Now().AddDate(0, 0, -1*f.Days).Unix()}}})
+		} else {
+			doc = append(doc, bson.E{Key: "lastRecord", Value: bson.D{{Key: "$exists", Value: true}}})
+		}
+
+	case f.Days > 0:
+		doc = append(doc, bson.E{Key: "lastRecord", Value: bson.D{{Key: "$gt", Value: time.Now().AddDate(0, 0, -1*f.Days).Unix()}}})
+
+	case f.Days == 0:
+		// "lastRecord" field is exists
+		doc = append(doc, bson.E{Key: "lastRecord", Value: bson.D{{Key: "$exists", Value: true}}})
+	}
+
+	if f.Source != "" {
+		doc = append(doc, bson.E{Key: "sources.name", Value: f.Source})
+	}
+
+	return doc, nil
+}
+
+// mongoInsertDoc returns the filter of the name with parts p and the document set on insert.
+func mongoInsertDoc(p *dns.Parts) (bson.D, bson.D) {
+
+	doc := bson.D{{Key: "domain", Value: p.Domain}, {Key: "tld", Value: p.TLD}, {Key: "sub", Value: p.Sub}}
+
+	// The n-grams of the full hostname used by Contains() and the reversed labels of the subdomain used in the subtree lookup
+	onInsert := append(bson.D{}, doc...)
+	onInsert = append(onInsert, bson.E{Key: "ngrams", Value: Ngrams((&FastDomainSchema{Domain: p.Domain, TLD: p.TLD, Sub: p.Sub}).String())}, bson.E{Key: "rsub", Value: ReverseLabels(p.Sub)})
+
+	return doc, onInsert
+}
+
+func (MongoStore) Insert(p *dns.Parts, source string) (bool, bool, error) {
+
+	doc, onInsert := mongoInsertDoc(p)
+
+	// UpdateOne will insert the document with $setOnInsert + upsert or do nothing
+	res, err := Domains.UpdateOne(context.TODO(), doc, bson.M{"$setOnInsert": onInsert}, options.Update().SetUpsert(true))
+	if err != nil {
+		return false, false, fmt.Errorf("failed to update: %w", err)
+	}
+
+	now := time.Now().Unix()
+
+	// "sources" field should contain only one element with "name" source.
+	// Try to update first!
+	// If MatchedCount is 0, the source is new and will be appended to the array.
+	filter := bson.D{{Key: "domain", Value: p.Domain}, {Key: "tld", Value: p.TLD}, {Key: "sub", Value: p.Sub}, {Key: "sources.name", Value: source}}
+
+	up := bson.D{{Key: "$set", Value: bson.D{{Key: "sources.$.last", Value: now}}}}
+
+	result, err := Domains.UpdateOne(context.TODO(), filter, up)
+	if err != nil {
+		return false, false, fmt.Errorf("failed to update source: %w", err)
+	}
+
+	if result.MatchedCount == 0 {
+
+		// "sources.name" is checked again to not append the same source twice
+		filter = bson.D{{Key: "domain", Value: p.Domain}, {Key: "tld", Value: p.TLD}, {Key: "sub", Value: p.Sub}, {Key: "sources.name", Value: bson.M{"$ne": source}}}
+
+		up = bson.D{{Key: "$push", Value: bson.D{{Key: "sources", Value: SourceSchema{Name: source, First: now, Last: now}}}}}
+
+		_, err = Domains.UpdateOne(context.TODO(), filter, up)
+		if err != nil {
+			return false, false, fmt.Errorf("failed to append source: %w", err)
+		}
+	}
+
+	return res.UpsertedCount != 0, result.MatchedCount == 0, nil
+}
+
+func (MongoStore) Find(p *dns.Parts, f LookupFilter) ([]DomainSchema, error) {
+
+	doc, err := lookupFilter(p, f)
+	if err != nil {
+		return nil, err
+	}
+
+	// Use Find() to find every shard of the domain
+	cursor, err := Domains.Find(context.TODO(), doc, options.Find().SetProjection(domainProjection))
+	if err != nil {
+		return nil, fmt.Errorf("failed to find: %w", err)
+	}
+	defer cursor.Close(context.TODO())
+
+	var ds []DomainSchema
+
+	for cursor.Next(context.TODO()) {
+
+		r := new(DomainSchema)
+
+		err = cursor.Decode(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode: %w", err)
+		}
+
+		ds = append(ds, *r)
+	}
+
+	if err := cursor.Err(); err != nil {
+		return nil, fmt.Errorf("cursor failed: %w", err)
+	}
+
+	return ds, nil
+}
+
+func (MongoStore) Version(p *dns.Parts, f LookupFilter) (VersionSchema, error) {
+
+	var v VersionSchema
+
+	doc, err := lookupFilter(p, f)
+	if err != nil {
+		return v, err
+	}
+
+	pipeline := bson.A{
+		bson.M{"$match": doc},
+		bson.M{"$group": bson.M{
+			"_id":        nil,
+			"names":      bson.M{"$sum": 1},
+			"first":      bson.M{"$max": bson.M{"$max": "$sources.first"}},
+			"updated":    bson.M{"$max": "$updated"},
+			"lastRecord": bson.M{"$max": "$lastRecord"},
+		}},
+	}
+
+	cursor, err := Domains.Aggregate(context.TODO(), pipeline)
+	if err != nil {
+		return v, fmt.Errorf("failed to aggregate: %w", err)
+	}
+	defer cursor.Close(context.TODO())
+
+	if cursor.Next(context.TODO()) {
+
+		err = cursor.Decode(&v)
+		if err != nil {
+			return v, fmt.Errorf("failed to decode: %w", err)
+		}
+	}
+
+	if err := cursor.Err(); err != nil {
+		return v, fmt.Errorf("cursor failed: %w", err)
+	}
+
+	return v, nil
+}
+
+func (MongoStore) Get(p *dns.Parts) (*DomainSchema, error) {
+
+	r := new(DomainSchema)
+
+	err := Domains.FindOne(context.TODO(), bson.D{{Key: "domain", Value: p.Domain}, {Key: "tld", Value: p.TLD}, {Key: "sub", Value: p.Sub}}, options.FindOne().SetProjection(domainProjection)).Decode(r)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, fault.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (MongoStore) Each(f ExportFilter, fn func(d *DomainSchema) error) error {
+
+	filter := bson.D{}
+
+	if f.Domain != "" {
+		filter = append(filter, bson.E{Key: "domain", Value: f.Domain})
+	}
+	if f.TLD != "" {
+		filter = append(filter, bson.E{Key: "tld", Value: f.TLD})
+	}
+	if f.Source != "" {
+		filter = append(filter, bson.E{Key: "sources.name", Value: f.Source})
+	}
+	if f.Since > 0 {
+		filter = append(filter, bson.E{Key: "updated", Value: bson.M{"$gte": f.Since}})
+	}
+
+	// The sort use the unique {domain, tld, sub} index
+	sort := bson.D{{Key: "domain", Value: 1}, {Key: "tld", Value: 1}, {Key: "sub", Value: 1}}
+
+	// The same order for a single TLD with the {tld, domain, sub} index
+	if f.Domain == "" && f.TLD != "" {
+		sort = bson.D{{Key: "tld", Value: 1}, {Key: "domain", Value: 1}, {Key: "sub", Value: 1}}
+	}
+
+	opts := options.Find().SetProjection(domainProjection).SetSort(sort)
+
+	cursor, err := Domains.Find(context.TODO(), filter, opts)
+	if err != nil {
+		return fmt.Errorf("failed to find: %w", err)
+	}
+	defer cursor.Close(context.TODO())
+
+	for cursor.Next(context.TODO()) {
+
+		d := new(DomainSchema)
+
+		err = cursor.Decode(d)
+		if err != nil {
+			return fmt.Errorf("failed to decode: %w", err)
+		}
+
+		err = fn(d)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := cursor.Err(); err != nil {
+		return fmt.Errorf("cursor failed: %w", err)
+	}
+
+	return nil
+}
+
+func (MongoStore) Import(d *DomainSchema) (bool, error) {
+
+	doc, onInsert := mongoInsertDoc(&dns.Parts{Domain: d.Domain, TLD: d.TLD, Sub: d.Sub})
+
+	up := bson.M{"$setOnInsert": onInsert}
+
+	// The "updated" field exists only for the resolved names (see CountUpdated())
+	if d.Updated > 0 {
+		up["$max"] = bson.M{"updated": d.Updated}
+	}
+
+	res, err := Domains.UpdateOne(context.TODO(), doc, up, options.Update().SetUpsert(true))
+	if err != nil {
+		return false, fmt.Errorf("failed to update: %w", err)
+	}
+
+	for _, s := range d.Sources {
+
+		// Merge the times of the existing source first, see Insert()
+		filter := append(doc, bson.E{Key: "sources.name", Value: s.Name})
+
+		up := bson.D{{Key: "$min", Value: bson.D{{Key: "sources.$.first", Value: s.First}}}, {Key: "$max", Value: bson.D{{Key: "sources.$.last", Value: s.Last}}}}
+
+		result, err := Domains.UpdateOne(context.TODO(), filter, up)
+		if err != nil {
+			return false, fmt.Errorf("failed to update source %s: %w", s.Name, err)
+		}
+
+		if result.MatchedCount != 0 {
+			continue
+		}
+
+		filter = append(doc, bson.E{Key: "sources.name", Value: bson.M{"$ne": s.Name}})
+
+		_, err = Domains.UpdateOne(context.TODO(), filter, bson.D{{Key: "$push", Value: bson.D{{Key: "sources", Value: s}}}})
+		if err != nil {
+			return false, fmt.Errorf("failed to append source %s: %w", s.Name, err)
+		}
+	}
+
+	return res.UpsertedCount != 0, nil
+}
+
+func (MongoStore) TLDs(d string) ([]string, error) {
+
+	// Use Find() to find every shard of the domain
+	cursor, err := Domains.Find(context.TODO(), bson.M{"domain": d}, options.Find().SetProjection(bson.M{"tld": 1}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to find: %w", err)
+	}
+	defer cursor.Close(context.TODO())
+
+	var tlds []string
+
+	for cursor.Next(context.TODO()) {
+
+		var r FastDomainSchema
+
+		err = cursor.Decode(&r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode: %w", err)
+		}
+
+		tlds = slices.AppendUnique(tlds, r.TLD)
+	}
+
+	if err := cursor.Err(); err != nil {
+		return nil, fmt.Errorf("cursor failed: %w", err)
+	}
+
+	return tlds, nil
+}
+
+func (MongoStore) Starts(d string) ([]string, error) {
+
+	doc := bson.M{"domain": bson.M{"$regex": fmt.Sprintf("^%s", d)}}
+
+	// Use Find() to find every shard of the domain
+	cursor, err := Domains.Find(context.TODO(), doc, options.Find().SetProjection(bson.M{"domain": 1}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to find: %w", err)
+	}
+	defer cursor.Close(context.TODO())
+
+	var domains []string
+
+	for cursor.Next(context.TODO()) {
+
+		var r FastDomainSchema
+
+		err = cursor.Decode(&r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode: %w", err)
+		}
+
+		domains = slices.AppendUnique(domains, r.Domain)
+	}
+
+	if err := cursor.Err(); err != nil {
+		return nil, fmt.Errorf("cursor failed: %w", err)
+	}
+
+	return domains, nil
+}
+
+func (MongoStore) SetUpdated(p *dns.Parts, t int64) error {
+
+	filter := bson.D{{Key: "domain", Value: p.Domain}, {Key: "tld", Value: p.TLD}, {Key: "sub", Value: p.Sub}}
+
+	up := bson.D{{Key: "$set", Value: bson.D{{Key: "updated", Value: t}}}}
+
+	_, err := Domains.UpdateOne(context.TODO(), filter, up)
+
+	return err
+}
+
+func (MongoStore) InsertRecords(p *dns.Parts, rs []RecordSchema) (bool, error) {
+
+	if len(rs) == 0 {
+		return false, nil
+	}
+
+	var (
+		obs   = make([]interface{}, 0, len(rs))
+		pairs = make(bson.A, 0, len(rs))
+		uniq  = make(map[RecordSchema]struct{}, len(rs))
+		last  int64
+	)
+
+	for i := range rs {
+
+		obs = append(obs, RecordObservationSchema{Time: time.Unix(rs[i].Time, 0), Meta: RecordMetaSchema{Domain: p.Domain, TLD: p.TLD, Sub: p.Sub, Type: rs[i].Type, Value: rs[i].Value}})
+
+		if _, ok := uniq[RecordSchema{Type: rs[i].Type, Value: rs[i].Value}]; !ok {
+			uniq[RecordSchema{Type: rs[i].Type, Value: rs[i].Value}] = struct{}{}
+			pairs = append(pairs, bson.D{{Key: "meta.type", Value: rs[i].Type}, {Key: "meta.value", Value: rs[i].Value}})
+		}
+
+		if rs[i].Time > last {
+			last = rs[i].Time
+		}
+	}
+
+	// The number of the already observed types and values, checked before the insert
+	pipeline := bson.A{
+		bson.M{"$match": bson.D{{Key: "meta.domain", Value: p.Domain}, {Key: "meta.tld", Value: p.TLD}, {Key: "meta.sub", Value: p.Sub}, {Key: "$or", Value: pairs}}},
+		bson.M{"$group": bson.M{"_id": bson.M{"type": "$meta.type", "value": "$meta.value"}}},
+		bson.M{"$count": "n"},
+	}
+
+	cursor, err := DNSRecords.Aggregate(context.TODO(), pipeline)
+	if err != nil {
+		return false, fmt.Errorf("failed to count records: %w", err)
+	}
+
+	var known []struct {
+		N int `bson:"n"`
+	}
+
+	err = cursor.All(context.TODO(), &known)
+	if err != nil {
+		return false, fmt.Errorf("failed to decode count: %w", err)
+	}
+
+	isNew := len(known) == 0 || known[0].N < len(uniq)
+
+	// Every observation is a new document in the time-series collection, the documents in "domains" are not grown
+	_, err = DNSRecords.InsertMany(context.TODO(), obs)
+	if err != nil {
+		return false, fmt.Errorf("failed to insert records: %w", err)
+	}
+
+	filter := bson.D{{Key: "domain", Value: p.Domain}, {Key: "tld", Value: p.TLD}, {Key: "sub", Value: p.Sub}}
+
+	_, err = Domains.UpdateOne(context.TODO(), filter, bson.M{"$max": bson.M{"lastRecord": last}})
+	if err != nil {
+		return false, fmt.Errorf("failed to update lastRecord: %w", err)
+	}
+
+	return isNew, nil
+}
+
+func (MongoStore) Records(p *dns.Parts, f LookupFilter, exact bool) (map[string][]RecordSchema, error) {
+
+	pipeline := bson.A{
+		bson.M{"$match": recordsFilter(p, f, exact)},
+		bson.M{"$group": bson.M{"_id": bson.M{"sub": "$meta.sub", "type": "$meta.type", "value": "$meta.value"}, "time": bson.M{"$max": "$time"}}},
+		bson.M{"$sort": bson.D{{Key: "_id.sub", Value: 1}, {Key: "_id.type", Value: 1}, {Key: "_id.value", Value: 1}}},
+	}
+
+	cursor, err := DNSRecords.Aggregate(context.TODO(), pipeline, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate: %w", err)
+	}
+	defer cursor.Close(context.TODO())
+
+	records := make(map[string][]RecordSchema)
+
+	for cursor.Next(context.TODO()) {
+
+		var r struct {
+			ID struct {
+				Sub   string `bson:"sub"`
+				Type  uint16 `bson:"type"`
+				Value string `bson:"value"`
+			} `bson:"_id"`
+			Time time.Time `bson:"time"`
+		}
+
+		err = cursor.Decode(&r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode: %w", err)
+		}
+
+		records[r.ID.Sub] = append(records[r.ID.Sub], RecordSchema{Type: r.ID.Type, Value: r.ID.Value, Time: r.Time.Unix()})
+	}
+
+	if err := cursor.Err(); err != nil {
+		return nil, fmt.Errorf("cursor failed: %w", err)
+	}
+
+	return records, nil
+}
+
+// mongoNameFilter returns the filter of the name with parts of d.
+func mongoNameFilter(d *DomainSchema) bson.D {
+	return bson.D{{Key: "domain", Value: d.Domain}, {Key: "tld", Value: d.TLD}, {Key: "sub", Value: d.Sub}}
+}
+
+// mongoRecordsFilter returns the filter of the observations of the name with parts of d.
+func mongoRecordsFilter(d *DomainSchema) bson.D {
+	return bson.D{{Key: "meta.domain", Value: d.Domain}, {Key: "meta.tld", Value: d.TLD}, {Key: "meta.sub", Value: d.Sub}}
+}
+
+func (s MongoStore) PruneNames(before int64, archive bool, dryRun bool, fn func(d *DomainSchema)) (int64, error) {
+
+	filter := bson.M{
+		// The last resolution is after before, so a name without recent check is kept
+		"updated": bson.M{"$gte": before},
+		"$or":     bson.A{bson.M{"lastRecord": bson.M{"$exists": false}}, bson.M{"lastRecord": bson.M{"$lt": before}}},
+		"sources": bson.M{"$not": bson.M{"$elemMatch": bson.M{"first": bson.M{"$gte": before}}}},
+	}
+
+	cursor, err := Domains.Find(context.TODO(), filter, options.Find().SetProjection(domainProjection))
+	if err != nil {
+		return 0, fmt.Errorf("failed to find: %w", err)
+	}
+	defer cursor.Close(context.TODO())
+
+	var n int64
+
+	for cursor.Next(context.TODO()) {
+
+		d := new(DomainSchema)
+
+		err = cursor.Decode(d)
+		if err != nil {
+			return n, fmt.Errorf("failed to decode: %w", err)
+		}
+
+		n++
+		fn(d)
+
+		if dryRun {
+			continue
+		}
+
+		if archive {
+
+			rs, err := s.Records(&dns.Parts{Domain: d.Domain, TLD: d.TLD, Sub: d.Sub}, LookupFilter{}, true)
+			if err != nil {
+				return n, fmt.Errorf("failed to get records of %s: %w", d.String(), err)
+			}
+
+			d.Records = rs[d.Sub]
+
+			a := ArchivedDomainSchema{DomainSchema: *d, Archived: time.Now().Unix()}
+
+			_, err = Archive.ReplaceOne(context.TODO(), mongoNameFilter(d), a, options.Replace().SetUpsert(true))
+			if err != nil {
+				return n, fmt.Errorf("failed to archive %s: %w", d.String(), err)
+			}
+		}
+
+		_, err = DNSRecords.DeleteMany(context.TODO(), mongoRecordsFilter(d))
+		if err != nil {
+			return n, fmt.Errorf("failed to delete records of %s: %w", d.String(), err)
+		}
+
+		_, err = Domains.DeleteOne(context.TODO(), mongoNameFilter(d))
+		if err != nil {
+			return n, fmt.Errorf("failed to delete %s: %w", d.String(), err)
+		}
+	}
+
+	if err := cursor.Err(); err != nil {
+		return n, fmt.Errorf("cursor failed: %w", err)
+	}
+
+	return n, nil
+}
+
+// TrimRecords sets the TTL of the "records" time-series collection, the expired observations are removed by the server in the background.
+// The server removes a bucket of observations when the newest observation in the bucket is expired, so the observations may removed later.
+// The "lastRecord" field is cleared only when every observation of the name is removed, the rest is cleared by a later run.
+//
+// If days is 0, the TTL set by a previous run is removed. A missing or not time-series collection has no TTL, so nothing to do.
+func (MongoStore) TrimRecords(days int, dryRun bool) (int64, error) {
+
+	info, err := collectionGetInfo(DNSRecords)
+	if err != nil {
+		return 0, err
+	}
+
+	if days == 0 {
+
+		if dryRun || info == nil || info.Type != "timeseries" || info.Options.ExpireAfterSeconds == nil {
+			return 0, nil
+		}
+
+		cmd := bson.D{{Key: "collMod", Value: DNSRecords.Name()}, {Key: "expireAfterSeconds", Value: "off"}}
+
+		err = DNSRecords.Database().RunCommand(context.TODO(), cmd).Err()
+		if err != nil {
+			return 0, fmt.Errorf("failed to clear expireAfterSeconds: %w", err)
+		}
+
+		return 0, nil
+	}
+
+	if info == nil || info.Type != "timeseries" {
+		return 0, fmt.Errorf("%s is not a timeseries collection", DNSRecords.Name())
+	}
+
+	before := time.Now().AddDate(0, 0, -1*days)
+
+	n, err := DNSRecords.CountDocuments(context.TODO(), bson.M{"time": bson.M{"$lt": before}})
+	if err != nil {
+		return 0, fmt.Errorf("failed to count: %w", err)
+	}
+
+	if dryRun {
+		return n, nil
+	}
+
+	cmd := bson.D{{Key: "collMod", Value: DNSRecords.Name()}, {Key: "expireAfterSeconds", Value: int64(days) * 86400}}
+
+	err = DNSRecords.Database().RunCommand(context.TODO(), cmd).Err()
+	if err != nil {
+		return 0, fmt.Errorf("failed to set expireAfterSeconds: %w", err)
+	}
+
+	cursor, err := Domains.Find(context.TODO(), bson.M{"lastRecord": bson.M{"$lt": before.Unix()}}, options.Find().SetProjection(bson.M{"domain": 1, "tld": 1, "sub": 1}))
+	if err != nil {
+		return n, fmt.Errorf("failed to find: %w", err)
+	}
+	defer cursor.Close(context.TODO())
+
+	for cursor.Next(context.TODO()) {
+
+		d := new(DomainSchema)
+
+		err = cursor.Decode(d)
+		if err != nil {
+			return n, fmt.Errorf("failed to decode: %w", err)
+		}
+
+		// The observations are not removed yet
+		err = DNSRecords.FindOne(context.TODO(), mongoRecordsFilter(d)).Err()
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, mongo.ErrNoDocuments) {
+			return n, fmt.Errorf("failed to find records of %s: %w", d.String(), err)
+		}
+
+		_, err = Domains.UpdateOne(context.TODO(), mongoNameFilter(d), bson.M{"$unset": bson.M{"lastRecord": ""}})
+		if err != nil {
+			return n, fmt.Errorf("failed to unset lastRecord of %s: %w", d.String(), err)
+		}
+	}
+
+	if err := cursor.Err(); err != nil {
+		return n, fmt.Errorf("cursor failed: %w", err)
+	}
+
+	return n, nil
+}
+
+// CapRecords sorts the values of every record type of a name by the time of the newest observation with $sortArray,
+// which requires MongoDB 5.2 or newer.
+func (MongoStore) CapRecords(max int, dryRun bool) (int64, error) {
+
+	pipeline := bson.A{
+		bson.M{"$group": bson.M{"_id": bson.M{"domain": "$meta.domain", "tld": "$meta.tld", "sub": "$meta.sub", "type": "$meta.type", "value": "$meta.value"}, "time": bson.M{"$max": "$time"}}},
+		bson.M{"$group": bson.M{"_id": bson.M{"domain": "$_id.domain", "tld": "$_id.tld", "sub": "$_id.sub", "type": "$_id.type"}, "values": bson.M{"$push": bson.M{"value": "$_id.value", "time": "$time"}}}},
+		bson.M{"$match": bson.M{fmt.Sprintf("values.%d", max): bson.M{"$exists": true}}},
+		// The newest value is the first, keep the values after the first max
+		bson.M{"$project": bson.M{"values": bson.M{"$slice": bson.A{bson.M{"$sortArray": bson.M{"input": "$values", "sortBy": bson.M{"time": -1}}}, max, bson.M{"$size": "$values"}}}}},
+		bson.M{"$project": bson.M{"values": "$values.value"}},
+	}
+
+	cursor, err := DNSRecords.Aggregate(context.TODO(), pipeline, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return 0, fmt.Errorf("failed to aggregate: %w", err)
+	}
+	defer cursor.Close(context.TODO())
+
+	var n int64
+
+	for cursor.Next(context.TODO()) {
+
+		var r struct {
+			ID struct {
+				Domain string `bson:"domain"`
+				TLD    string `bson:"tld"`
+				Sub    string `bson:"sub"`
+				Type   uint16 `bson:"type"`
+			} `bson:"_id"`
+			Values []string `bson:"values"`
+		}
+
+		err = cursor.Decode(&r)
+		if err != nil {
+			return n, fmt.Errorf("failed to decode: %w", err)
+		}
+
+		n += int64(len(r.Values))
+
+		if dryRun {
+			continue
+		}
+
+		filter := append(mongoRecordsFilter(&DomainSchema{Domain: r.ID.Domain, TLD: r.ID.TLD, Sub: r.ID.Sub}), bson.E{Key: "meta.type", Value: r.ID.Type}, bson.E{Key: "meta.value", Value: bson.M{"$in": r.Values}})
+
+		_, err = DNSRecords.DeleteMany(context.TODO(), filter)
+		if err != nil {
+			return n, fmt.Errorf("failed to delete records: %w", err)
+		}
+	}
+
+	if err := cursor.Err(); err != nil {
+		return n, fmt.Errorf("cursor failed: %w", err)
+	}
+
+	return n, nil
+}
+
+func (MongoStore) CountTotal() (int64, error) {
+
+	return Domains.CountDocuments(context.TODO(), bson.M{})
+}
+
+func (MongoStore) CountUpdated() (int64, error) {
+
+	return Domains.CountDocuments(context.TODO(), bson.M{"updated": bson.M{"$exists": true}})
+}
+
+func (MongoStore) CountValid() (int64, error) {
+
+	return Domains.CountDocuments(context.TODO(), bson.M{"lastRecord": bson.M{"$exists": true}})
+}
+
+func (MongoStore) StatisticsInsert(s StatisticSchema) error {
+
+	_, err := Statistics.InsertOne(context.TODO(), s)
+
+	return err
+}
+
+func (MongoStore) StatisticsGets() ([]StatisticSchema, error) {
+
+	cursor, err := Statistics.Find(context.TODO(), bson.M{}, options.Find().SetSort(bson.M{"date": -1}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to find: %w", err)
+	}
+	defer cursor.Close(context.TODO())
+
+	r := make([]StatisticSchema, 0, MaxStatisticsEntry)
+
+	for cursor.Next(context.TODO()) {
+
+		s := new(StatisticSchema)
+
+		err = cursor.Decode(s)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode: %w", err)
+		}
+
+		r = append(r, *s)
+	}
+
+	err = cursor.Err()
+	if err != nil {
+		return nil, fmt.Errorf("cursor failed: %w", err)
+	}
+
+	return r, nil
+}
+
+func (MongoStore) StatisticsGetNewest() (StatisticSchema, error) {
+
+	s := new(StatisticSchema)
+
+	err := Statistics.FindOne(context.TODO(), bson.M{}, options.FindOne().SetSort(bson.M{"date": -1})).Decode(s)
+
+	return *s, err
+}
+
+func (MongoStore) StatisticsClean(max int) error {
+
+	n, err := Statistics.CountDocuments(context.TODO(), bson.M{})
+	if err != nil {
+		return fmt.Errorf("failed to count total statistic entries: %w", err)
+	}
+
+	if n <= int64(max) {
+		return nil
+	}
+
+	i := 0
+
+	cursor, err := Statistics.Find(context.TODO(), bson.M{}, options.Find().SetSort(bson.M{"date": -1}))
+	if err != nil {
+		return fmt.Errorf("failed to find statistic entries: %w", err)
+	}
+	defer cursor.Close(context.TODO())
+
+	for cursor.Next(context.TODO()) {
+
+		if i <= max {
+			i++
+			continue
+		}
+
+		s := new(StatisticSchema)
+
+		err = cursor.Decode(s)
+		if err != nil {
+			return fmt.Errorf("failed to decode: %w", err)
+		}
+
+		_, err := Statistics.DeleteOne(context.TODO(), *s)
+		if err != nil {
+			return fmt.Errorf("failed to remove entry (date: %d, total: %d, updated: %d, valid: %d): %w", s.Date, s.Total, s.Updated, s.Valid, err)
+		}
+	}
+
+	return cursor.Err()
+}
+
+func (MongoStore) CTLogsUpdate(name string, index int64, size int64) error {
+
+	_, err := CTLogs.UpdateOne(context.TODO(), bson.D{{Key: "name", Value: name}}, bson.D{{Key: "$set", Value: bson.D{{Key: "index", Value: index}, {Key: "size", Value: size}}}}, options.Update().SetUpsert(true))
+
+	return err
+}
+
+func (MongoStore) CTLogsGet(name string) (*CTLogSchema, error) {
+
+	s := new(CTLogSchema)
+
+	err := CTLogs.FindOne(context.TODO(), bson.D{{Key: "name", Value: name}}).Decode(s)
+
+	return s, err
+}
+
+func (MongoStore) CTLogsGets() ([]CTLogSchema, error) {
+
+	cursor, err := CTLogs.Find(context.TODO(), bson.M{}, options.Find().SetSort(bson.M{"name": 1}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to find: %w", err)
+	}
+	defer cursor.Close(context.TODO())
+
+	scs := make([]CTLogSchema, 0)
+
+	for cursor.Next(context.TODO()) {
+
+		sc := new(CTLogSchema)
+
+		err = cursor.Decode(sc)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode: %w", err)
+		}
+
+		scs = append(scs, *sc)
+	}
+
+	return scs, cursor.Err()
+}
+
+func (MongoStore) BlockedGets() ([]BlockedSchema, error) {
+
+	cursor, err := Blocked.Find(context.TODO(), bson.M{}, options.Find().SetSort(bson.M{"pattern": 1}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to find: %w", err)
+	}
+	defer cursor.Close(context.TODO())
+
+	var bs []BlockedSchema
+
+	err = cursor.All(context.TODO(), &bs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode: %w", err)
+	}
+
+	return bs, nil
+}
+
+func (MongoStore) BlockedInsert(b BlockedSchema) (bool, error) {
+
+	// UpdateOne will insert the document with $setOnInsert + upsert or do nothing
+	res, err := Blocked.UpdateOne(context.TODO(), bson.M{"pattern": b.Pattern}, bson.M{"$setOnInsert": b}, options.Update().SetUpsert(true))
+	if err != nil {
+		return false, err
+	}
+
+	return res.UpsertedCount != 0, nil
+}
+
+func (MongoStore) BlockedDelete(pattern string) (bool, error) {
+
+	res, err := Blocked.DeleteOne(context.TODO(), bson.M{"pattern": pattern})
+	if err != nil {
+		return false, err
+	}
+
+	return res.DeletedCount != 0, nil
+}
+
+func (MongoStore) BlockedAuditInsert(a BlockedAuditSchema) error {
+
+	_, err := BlockedAudit.InsertOne(context.TODO(), a)
+
+	return err
+}
+
+func (MongoStore) BlockedAuditGets(limit int) ([]BlockedAuditSchema, error) {
+
+	cursor, err := BlockedAudit.Find(context.TODO(), bson.M{}, options.Find().SetSort(bson.D{{Key: "time", Value: -1}, {Key: "_id", Value: -1}}).SetLimit(int64(limit)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to find: %w", err)
+	}
+	defer cursor.Close(context.TODO())
+
+	as := make([]BlockedAuditSchema, 0, limit)
+
+	err = cursor.All(context.TODO(), &as)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode: %w", err)
+	}
+
+	return as, nil
+}
+
+func (MongoStore) SettingGet(key string) (string, error) {
+
+	v := new(SettingSchema)
+
+	err := Migrations.FindOne(context.TODO(), bson.M{"_id": key}).Decode(v)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return "", fault.ErrNotFound
+	}
+
+	return v.Value, err
+}
+
+func (MongoStore) SettingSet(key string, value string) error {
+
+	_, err := Migrations.ReplaceOne(context.TODO(), bson.M{"_id": key}, SettingSchema{Key: key, Value: value}, options.Replace().SetUpsert(true))
+
+	return err
+}
+
+// Resplit moves the observations of a name before the name, so an interrupted run can be continued:
+// the observations of an already moved name are found with the new parts.
+// The domains in the "topList" and "topListBuckets" collections are moved too, the counts are summed.
+func (MongoStore) Resplit() (int64, error) {
+
+	cursor, err := Domains.Find(context.TODO(), bson.M{}, options.Find().SetProjection(bson.M{"domain": 1, "tld": 1, "sub": 1}))
+	if err != nil {
+		return 0, fmt.Errorf("failed to find: %w", err)
+	}
+	defer cursor.Close(context.TODO())
+
+	var n int64
+
+	for cursor.Next(context.TODO()) {
+
+		d := new(DomainSchema)
+
+		err = cursor.Decode(d)
+		if err != nil {
+			return n, fmt.Errorf("failed to decode: %w", err)
+		}
+
+		p := GetParts(d.String())
+		if p == nil || (p.Domain == d.Domain && p.TLD == d.TLD && p.Sub == d.Sub) {
+			continue
+		}
+
+		_, err = DNSRecords.UpdateMany(context.TODO(), mongoRecordsFilter(d), bson.M{"$set": bson.M{"meta.domain": p.Domain, "meta.tld": p.TLD, "meta.sub": p.Sub}})
+		if err != nil {
+			return n, fmt.Errorf("failed to move records of %s: %w", d.String(), err)
+		}
+
+		_, err = Domains.UpdateOne(context.TODO(), mongoNameFilter(d), bson.M{"$set": bson.M{"domain": p.Domain, "tld": p.TLD, "sub": p.Sub, "rsub": ReverseLabels(p.Sub)}})
+		if mongo.IsDuplicateKeyError(err) {
+			err = mongoResplitMerge(d, p)
+		}
+		if err != nil {
+			return n, fmt.Errorf("failed to move %s: %w", d.String(), err)
+		}
+
+		n++
+	}
+
+	if err := cursor.Err(); err != nil {
+		return n, fmt.Errorf("cursor failed: %w", err)
+	}
+
+	if err = mongoResplitTopList(TopList, nil); err != nil {
+		return n, fmt.Errorf("failed to move topList: %w", err)
+	}
+
+	if err = mongoResplitTopList(TopListBuckets, []string{"date"}); err != nil {
+		return n, fmt.Errorf("failed to move topListBuckets: %w", err)
+	}
+
+	return n, nil
+}
+
+// mongoResplitMerge merges the name d into the existing name with parts p and removes d.
+func mongoResplitMerge(d *DomainSchema, p *dns.Parts) error {
+
+	old := new(DomainSchema)
+
+	err := Domains.FindOne(context.TODO(), mongoNameFilter(d)).Decode(old)
+	if err != nil {
+		return fmt.Errorf("failed to get: %w", err)
+	}
+
+	cur := new(DomainSchema)
+
+	err = Domains.FindOne(context.TODO(), bson.D{{Key: "domain", Value: p.Domain}, {Key: "tld", Value: p.TLD}, {Key: "sub", Value: p.Sub}}).Decode(cur)
+	if err != nil {
+		return fmt.Errorf("failed to get existing: %w", err)
+	}
+
+	set := bson.M{"updated": old.Updated}
+	if cur.Updated > old.Updated {
+		set["updated"] = cur.Updated
+	}
+
+	if last := old.LastRecord; last > 0 || cur.LastRecord > 0 {
+		if cur.LastRecord > last {
+			last = cur.LastRecord
+		}
+		set["lastRecord"] = last
+	}
+
+	if sources := mergeSources([][]SourceSchema{cur.Sources, old.Sources}); len(sources) > 0 {
+		set["sources"] = sources
+	}
+
+	_, err = Domains.UpdateOne(context.TODO(), mongoNameFilter(cur), bson.M{"$set": set})
+	if err != nil {
+		return fmt.Errorf("failed to update existing: %w", err)
+	}
+
+	_, err = Domains.DeleteOne(context.TODO(), mongoNameFilter(d))
+	if err != nil {
+		return fmt.Errorf("failed to delete: %w", err)
+	}
+
+	return nil
+}
+
+// mongoResplitTopList moves the documents of collection c (topList or topListBuckets) to the domain returned by GetDomain() and sums the counts.
+// keys are the fields of the unique index beside "domain".
+func mongoResplitTopList(c *mongo.Collection, keys []string) error {
+
+	cursor, err := c.Find(context.TODO(), bson.M{})
+	if err != nil {
+		return fmt.Errorf("failed to find: %w", err)
+	}
+	defer cursor.Close(context.TODO())
+
+	for cursor.Next(context.TODO()) {
+
+		var v bson.M
+
+		err = cursor.Decode(&v)
+		if err != nil {
+			return fmt.Errorf("failed to decode: %w", err)
+		}
+
+		domain, _ := v["domain"].(string)
+
+		d := GetDomain(domain)
+		if d == "" || d == domain {
+			continue
+		}
+
+		doc := bson.D{{Key: "domain", Value: d}}
+		for i := range keys {
+			doc = append(doc, bson.E{Key: keys[i], Value: v[keys[i]]})
+		}
+
+		_, err = c.UpdateOne(context.TODO(), doc, bson.M{"$setOnInsert": doc, "$inc": bson.M{"count": v["count"]}}, options.Update().SetUpsert(true))
+		if err != nil {
+			return fmt.Errorf("failed to update %s: %w", d, err)
+		}
+
+		_, err = c.DeleteOne(context.TODO(), bson.M{"_id": v["_id"]})
+		if err != nil {
+			return fmt.Errorf("failed to delete %s: %w", domain, err)
+		}
+	}
+
+	return cursor.Err()
+}
+
+func (MongoStore) Close() error {
+	return Client.Disconnect(context.Background())
+}
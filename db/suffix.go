@@ -0,0 +1,151 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/elmasy-com/columbus-server/config"
+	"github.com/elmasy-com/columbus-server/fault"
+	"github.com/elmasy-com/elnet/dns"
+	"golang.org/x/net/publicsuffix"
+)
+
+// PublicSuffix returns the public suffix of d (eg.: co.uk for www.example.co.uk) based on the Public Suffix List, used to split the names.
+// Only the ICANN suffixes are used, the private suffixes (eg.: github.io) are used only if config.PrivateSuffixes is set.
+// The last label of a name without a listed suffix is the public suffix.
+//
+// NOTE: This function not validate and Clean() d!
+func PublicSuffix(d string) string {
+
+	s, icann := publicsuffix.PublicSuffix(d)
+
+	// Strip the private suffix until an ICANN (or unlisted) suffix is found
+	for !icann && !config.PrivateSuffixes {
+
+		i := strings.IndexByte(s, '.')
+		if i == -1 {
+			break
+		}
+
+		s, icann = publicsuffix.PublicSuffix(s[i+1:])
+	}
+
+	return s
+}
+
+// IsPublicSuffix returns whether d is a public suffix in the ICANN or the private section of the Public Suffix List (eg.: co.uk or github.io).
+// The private suffixes are detected regardless of config.PrivateSuffixes, so github.io can not be queried or inserted
+// even if the tenants (eg.: user.github.io) are split as the subdomains of it.
+// A TLD is a public suffix too.
+//
+// NOTE: This function not validate and Clean() d!
+func IsPublicSuffix(d string) bool {
+
+	if d == "" {
+		return false
+	}
+
+	s, _ := publicsuffix.PublicSuffix(d)
+
+	return s == d
+}
+
+// GetParts splits d into sub|domain|tld parts like dns.GetParts(), but the TLD is the public suffix returned by PublicSuffix().
+// Returns nil if d is a public suffix or failed to get the parts.
+//
+// NOTE: This function not validate and Clean() d!
+func GetParts(d string) *dns.Parts {
+
+	tld := PublicSuffix(d)
+	if tld == "" || !strings.HasSuffix(d, "."+tld) {
+		return nil
+	}
+
+	v := strings.TrimSuffix(d, "."+tld)
+
+	p := &dns.Parts{TLD: tld}
+
+	if i := strings.LastIndexByte(v, '.'); i != -1 {
+		p.Sub = v[:i]
+		p.Domain = v[i+1:]
+	} else {
+		p.Domain = v
+	}
+
+	if p.Domain == "" {
+		return nil
+	}
+
+	return p
+}
+
+// GetDomain returns the domain part of d (eg.: example.co.uk for www.example.co.uk), see GetParts().
+// Returns an empty string if d is a public suffix or failed to get the parts.
+//
+// NOTE: This function not validate and Clean() d!
+func GetDomain(d string) string {
+
+	p := GetParts(d)
+	if p == nil {
+		return ""
+	}
+
+	return p.Domain + "." + p.TLD
+}
+
+// The setting that stores config.PrivateSuffixes used to split the stored names.
+const settingPrivateSuffixes = "privateSuffixes"
+
+// Resplit splits the stored names again if config.PrivateSuffixes is changed since the names are split (see Store.Resplit()).
+// The names stored before the setting are split with the ICANN suffixes only (config.PrivateSuffixes is false).
+// The setting is stored after every name is moved, so an interrupted run is continued on the next start.
+// With MongoDB, the instances are serialized with the lock of the migrations.
+// If any name is moved, the Bloom filter snapshot is removed (see BloomInvalidate()).
+func Resplit() error {
+
+	if MongoDB() {
+
+		release, err := migrationsLock()
+		if err != nil {
+			return err
+		}
+		defer release()
+	}
+
+	v, err := store.SettingGet(settingPrivateSuffixes)
+	if errors.Is(err, fault.ErrNotFound) {
+		v = strconv.FormatBool(false)
+	} else if err != nil {
+		return fmt.Errorf("failed to get setting: %w", err)
+	}
+
+	if v == strconv.FormatBool(config.PrivateSuffixes) {
+		return nil
+	}
+
+	fmt.Printf("Resplit(): PrivateSuffixes is changed to %v, splitting the names again...\n", config.PrivateSuffixes)
+
+	start := time.Now()
+
+	n, err := store.Resplit()
+	if err != nil {
+		return fmt.Errorf("failed to split names: %w", err)
+	}
+
+	err = store.SettingSet(settingPrivateSuffixes, strconv.FormatBool(config.PrivateSuffixes))
+	if err != nil {
+		return fmt.Errorf("failed to set setting: %w", err)
+	}
+
+	fmt.Printf("Resplit(): Moved %d names in %s\n", n, time.Since(start))
+
+	// The keys of the moved names are changed
+	if n > 0 {
+		return BloomInvalidate()
+	}
+
+	return nil
+}
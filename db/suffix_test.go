@@ -0,0 +1,106 @@
+package db
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/elmasy-com/columbus-server/config"
+	"github.com/elmasy-com/columbus-server/fault"
+	"github.com/elmasy-com/elnet/dns"
+)
+
+func TestGetParts(t *testing.T) {
+
+	defer func() { config.PrivateSuffixes = false }()
+
+	cases := []struct {
+		Private bool
+		Name    string
+		Sub     string
+		Domain  string
+		TLD     string
+	}{
+		{false, "www.example.co.uk", "www", "example", "co.uk"},
+		{false, "a.b.user.github.io", "a.b.user", "github", "io"},
+		{false, "github.io", "", "github", "io"},
+		{false, "co.uk", "", "", ""},
+		{false, "com", "", "", ""},
+		{true, "a.b.user.github.io", "a.b", "user", "github.io"},
+		{true, "github.io", "", "", ""},
+		{true, "co.uk", "", "", ""},
+	}
+
+	for _, c := range cases {
+
+		config.PrivateSuffixes = c.Private
+
+		if _, err := Lookup("github.io", LookupFilter{Days: -1}); !errors.Is(err, fault.ErrPublicSuffix) {
+			t.Fatalf("FAIL: lookup of github.io (private: %v) returned %v\n", c.Private, err)
+		}
+
+		p := GetParts(c.Name)
+
+		if c.Domain == "" {
+			if p != nil {
+				t.Fatalf("FAIL: %s (private: %v) returned %v\n", c.Name, c.Private, p)
+			}
+			if !IsPublicSuffix(c.Name) {
+				t.Fatalf("FAIL: %s (private: %v) is not a public suffix\n", c.Name, c.Private)
+			}
+			continue
+		}
+
+		if p == nil || p.Sub != c.Sub || p.Domain != c.Domain || p.TLD != c.TLD {
+			t.Fatalf("FAIL: %s (private: %v) returned %v\n", c.Name, c.Private, p)
+		}
+
+		// The private suffixes are detected with both split
+		if IsPublicSuffix(c.Name) != (c.Name == "github.io") {
+			t.Fatalf("FAIL: IsPublicSuffix(%s) (private: %v) != %v\n", c.Name, c.Private, c.Name == "github.io")
+		}
+	}
+}
+
+func TestResplit(t *testing.T) {
+
+	s, err := NewBoltStore(filepath.Join(t.TempDir(), "columbus.db"))
+	if err != nil {
+		t.Fatalf("FAIL: %s\n", err)
+	}
+	defer s.Close()
+
+	SetStore(s)
+
+	defer func() { config.PrivateSuffixes = false }()
+
+	// Stored with the ICANN split before the setting
+	for _, p := range []*dns.Parts{{Sub: "www.user", Domain: "github", TLD: "io"}, {Domain: "github", TLD: "io"}, {Sub: "www", Domain: "example", TLD: "com"}} {
+		if _, _, err = s.Insert(p, SourceCT); err != nil {
+			t.Fatalf("FAIL: %s\n", err)
+		}
+	}
+
+	config.PrivateSuffixes = true
+
+	if err = Resplit(); err != nil {
+		t.Fatalf("FAIL: %s\n", err)
+	}
+
+	if _, err = s.Get(&dns.Parts{Sub: "www", Domain: "user", TLD: "github.io"}); err != nil {
+		t.Fatalf("FAIL: www.user.github.io is not moved: %s\n", err)
+	}
+
+	if _, err = s.Get(&dns.Parts{Sub: "www.user", Domain: "github", TLD: "io"}); err == nil {
+		t.Fatalf("FAIL: www.user.github.io is stored with the old parts\n")
+	}
+
+	// A public suffix with the new split is kept
+	if _, err = s.Get(&dns.Parts{Domain: "github", TLD: "io"}); err != nil {
+		t.Fatalf("FAIL: github.io is removed: %s\n", err)
+	}
+
+	if v, err := s.SettingGet(settingPrivateSuffixes); err != nil || v != "true" {
+		t.Fatalf("FAIL: setting is %s, %v\n", v, err)
+	}
+}
@@ -0,0 +1,163 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/elmasy-com/columbus-server/config"
+	"github.com/elmasy-com/columbus-server/fault"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	MaxTopListBucketDays = 60 // Buckets older than this are removed by TopListBucketsCleanWorker()
+	MaxTrendingDays      = MaxTopListBucketDays / 2
+)
+
+// topListDay returns the beginning of the day of t in UTC as a Unix timestamp.
+func topListDay(t time.Time) int64 {
+
+	return t.UTC().Truncate(24 * time.Hour).Unix()
+}
+
+// TopListGets returns the limit most looked up domains in the last days days from the *topListBuckets* collection.
+// The current day is included.
+// Domains in config.TopListOptOut and the blocked domains are never returned.
+// The blocked domains are skipped while reading the sorted result, so the $limit stage is not used.
+//
+// If days is < 1 or > MaxTopListBucketDays, returns fault.ErrInvalidDays.
+func TopListGets(days int, limit int) ([]TopListSchema, error) {
+
+	if days < 1 || days > MaxTopListBucketDays {
+		return nil, fault.ErrInvalidDays
+	}
+
+	after := topListDay(time.Now().AddDate(0, 0, -1*(days-1)))
+
+	pipeline := bson.A{
+		bson.M{"$match": bson.D{{Key: "date", Value: bson.M{"$gte": after}}, {Key: "domain", Value: bson.M{"$nin": config.TopListOptOut}}}},
+		bson.M{"$group": bson.M{"_id": "$domain", "count": bson.M{"$sum": "$count"}}},
+		bson.M{"$sort": bson.D{{Key: "count", Value: -1}, {Key: "_id", Value: 1}}},
+		bson.M{"$project": bson.M{"_id": 0, "domain": "$_id", "count": 1}},
+	}
+
+	cursor, err := TopListBuckets.Aggregate(context.TODO(), pipeline, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate: %w", err)
+	}
+	defer cursor.Close(context.TODO())
+
+	r := make([]TopListSchema, 0, limit)
+
+	for cursor.Next(context.TODO()) {
+
+		t := new(TopListSchema)
+
+		err = cursor.Decode(t)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode: %w", err)
+		}
+
+		if IsBlocked(t.Domain) {
+			continue
+		}
+
+		r = append(r, *t)
+
+		if len(r) >= limit {
+			break
+		}
+	}
+
+	err = cursor.Err()
+	if err != nil {
+		return nil, fmt.Errorf("cursor failed: %w", err)
+	}
+
+	return r, nil
+}
+
+// TopListTrending returns the limit domains with the biggest growth in lookups.
+// The growth is the number of lookups in the last days days minus the number of lookups in the days days before.
+// Domains in config.TopListOptOut and the blocked domains are never returned (see TopListGets()).
+//
+// If days is < 1 or > MaxTrendingDays, returns fault.ErrInvalidDays.
+func TopListTrending(days int, limit int) ([]TrendingSchema, error) {
+
+	if days < 1 || days > MaxTrendingDays {
+		return nil, fault.ErrInvalidDays
+	}
+
+	now := time.Now()
+
+	// The current day is included in the current window
+	current := topListDay(now.AddDate(0, 0, -1*(days-1)))
+	previous := topListDay(now.AddDate(0, 0, -1*(2*days-1)))
+
+	pipeline := bson.A{
+		bson.M{"$match": bson.D{{Key: "date", Value: bson.M{"$gte": previous}}, {Key: "domain", Value: bson.M{"$nin": config.TopListOptOut}}}},
+		bson.M{"$group": bson.M{
+			"_id":      "$domain",
+			"count":    bson.M{"$sum": bson.M{"$cond": bson.A{bson.M{"$gte": bson.A{"$date", current}}, "$count", 0}}},
+			"previous": bson.M{"$sum": bson.M{"$cond": bson.A{bson.M{"$lt": bson.A{"$date", current}}, "$count", 0}}},
+		}},
+		bson.M{"$addFields": bson.M{"growth": bson.M{"$subtract": bson.A{"$count", "$previous"}}}},
+		bson.M{"$match": bson.M{"growth": bson.M{"$gt": 0}}},
+		bson.M{"$sort": bson.D{{Key: "growth", Value: -1}, {Key: "_id", Value: 1}}},
+	}
+
+	cursor, err := TopListBuckets.Aggregate(context.TODO(), pipeline, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate: %w", err)
+	}
+	defer cursor.Close(context.TODO())
+
+	r := make([]TrendingSchema, 0, limit)
+
+	for cursor.Next(context.TODO()) {
+
+		t := new(TrendingSchema)
+
+		err = cursor.Decode(t)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode: %w", err)
+		}
+
+		if IsBlocked(t.Domain) {
+			continue
+		}
+
+		r = append(r, *t)
+
+		if len(r) >= limit {
+			break
+		}
+	}
+
+	err = cursor.Err()
+	if err != nil {
+		return nil, fmt.Errorf("cursor failed: %w", err)
+	}
+
+	return r, nil
+}
+
+// TopListBucketsCleanWorker removes the buckets older than MaxTopListBucketDays days.
+//
+// This function is designed to run as a goroutine in the background.
+// The errors are printed to STDERR.
+func TopListBucketsCleanWorker() {
+
+	t := time.Tick(3600 * time.Second)
+
+	for range t {
+
+		_, err := TopListBuckets.DeleteMany(context.TODO(), bson.M{"date": bson.M{"$lt": topListDay(time.Now().AddDate(0, 0, -1*MaxTopListBucketDays))}})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "TopListBucketsCleanWorker(): Failed to remove old buckets: %s\n", err)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"os"
+	"time"
+
+	"github.com/elmasy-com/columbus-server/config"
+	"github.com/elmasy-com/elnet/dns"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// WordlistInsert tokenizes every subdomain in the "domains" collection into labels
+// and replace the "wordlist" collection with the number of occurrences per TLD and across every TLD.
+//
+// This function is **very** slow!
+func WordlistInsert() error {
+
+	pipeline := bson.A{
+		bson.M{"$match": bson.M{"sub": bson.M{"$ne": ""}}},
+		bson.M{"$project": bson.M{"_id": 0, "tld": 1, "labels": bson.M{"$split": bson.A{"$sub", "."}}}},
+		bson.M{"$unwind": "$labels"},
+		// Count every label twice: once for the TLD and once for every TLD (empty TLD)
+		bson.M{"$project": bson.M{"keys": bson.A{bson.M{"tld": "$tld", "label": "$labels"}, bson.M{"tld": "", "label": "$labels"}}}},
+		bson.M{"$unwind": "$keys"},
+		bson.M{"$group": bson.M{"_id": "$keys", "count": bson.M{"$sum": 1}}},
+		bson.M{"$project": bson.M{"_id": 0, "tld": "$_id.tld", "label": "$_id.label", "count": 1}},
+		bson.M{"$out": "wordlist"},
+	}
+
+	cursor, err := Domains.Aggregate(context.TODO(), pipeline, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return fmt.Errorf("failed to aggregate: %w", err)
+	}
+
+	return cursor.Close(context.TODO())
+}
+
+// wordlistUpdate updates the wordlist and refreshes the brute-force labels if the generated wordlist is used.
+// The errors are printed to STDERR.
+func wordlistUpdate() {
+
+	err := WordlistInsert()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to update wordlist: %s\n", err)
+		return
+	}
+
+	if config.BruteForce && config.BruteForceWordlist == "" {
+
+		_, err = bruteForceRefreshLabels()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to refresh brute-force labels: %s\n", err)
+		}
+	}
+}
+
+// WordlistWorker updates the wordlist at the beginning and at a random time in an infinite loop.
+// The labels of the brute-force are refreshed after every update, unless config.BruteForceWordlist is set.
+//
+// This function is designed to run as a goroutine in the background.
+// The errors are printed to STDERR.
+func WordlistWorker() {
+
+	wordlistUpdate()
+
+	for {
+
+		time.Sleep(time.Duration(rand.Int63n(86400)) * time.Second)
+
+		wordlistUpdate()
+	}
+}
+
+// WordlistGets returns the top most frequent labels in tld.
+// If tld is empty, returns the most frequent labels across every TLD.
+func WordlistGets(tld string, top int) ([]WordlistSchema, error) {
+
+	cursor, err := Wordlist.Find(context.TODO(), bson.M{"tld": tld}, options.Find().SetSort(bson.D{{Key: "count", Value: -1}, {Key: "label", Value: 1}}).SetLimit(int64(top)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to find: %w", err)
+	}
+	defer cursor.Close(context.TODO())
+
+	r := make([]WordlistSchema, 0, top)
+
+	for cursor.Next(context.TODO()) {
+
+		w := new(WordlistSchema)
+
+		err = cursor.Decode(w)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode: %w", err)
+		}
+
+		r = append(r, *w)
+	}
+
+	err = cursor.Err()
+	if err != nil {
+		return nil, fmt.Errorf("cursor failed: %w", err)
+	}
+
+	return r, nil
+}
+
+// WordlistLabels returns the top most frequent valid labels across every TLD.
+// Labels that are not valid hostname labels (eg.: "*") are skipped.
+func WordlistLabels(top int) ([]string, error) {
+
+	ws, err := WordlistGets("", top)
+	if err != nil {
+		return nil, err
+	}
+
+	labels := make([]string, 0, len(ws))
+
+	for i := range ws {
+		if dns.IsValidSLD(ws[i].Label) && ws[i].Label != "*" {
+			labels = append(labels, ws[i].Label)
+		}
+	}
+
+	return labels, nil
+}
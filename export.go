@@ -0,0 +1,262 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/elmasy-com/columbus-server/config"
+	"github.com/elmasy-com/columbus-server/db"
+	"github.com/elmasy-com/elnet/dns"
+)
+
+// Number of entries between two progress lines.
+const progressInterval = 10000
+
+// parseConfig parses the config file in path and opens the store.
+// Exits on error.
+func parseConfig(fs *flag.FlagSet, path string) {
+
+	if path == "" {
+		fmt.Fprintf(os.Stderr, "Path to the config file is missing!\n")
+		fmt.Printf("Usage of %s %s:\n", os.Args[0], fs.Name())
+		fs.PrintDefaults()
+		os.Exit(1)
+	}
+
+	fmt.Printf("Parsing config file...\n")
+	if err := config.Parse(path); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to parse config file: %s\n", err)
+		os.Exit(1)
+	}
+
+	openStore()
+}
+
+// parseSince parses s as a Unix timestamp or as a date in YYYY-MM-DD format (UTC).
+// If s is empty, returns 0.
+func parseSince(s string) (int64, error) {
+
+	if s == "" {
+		return 0, nil
+	}
+
+	if v, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return v, nil
+	}
+
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid since: %s", s)
+	}
+
+	return t.Unix(), nil
+}
+
+// runExport runs the "export" subcommand with args.
+func runExport(args []string) {
+
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	path := fs.String("config", "", "Path to the config file.")
+	out := fs.String("out", "", "Path to the output file.")
+	format := fs.String("format", db.FormatNDJSON, "Format of the output (ndjson or binary), compressed with gzip.")
+	collections := fs.String("collections", "", "Comma separated list of the exported collections (domains, ctlogs, topList, statistics). Default is every collection.")
+	tld := fs.String("tld", "", "Export the names with TLD only.")
+	domain := fs.String("domain", "", "Export the names of the domain only (eg.: example.com).")
+	source := fs.String("source", "", "Export the names with the source only (eg.: ct).")
+	since := fs.String("since", "", "Export the names updated since the given Unix timestamp or date (YYYY-MM-DD) only.")
+	records := fs.Bool("records", false, "Export the DNS records of the names.")
+	fs.Parse(args)
+
+	if *out == "" {
+		fmt.Fprintf(os.Stderr, "Path to the output file is missing!\n")
+		os.Exit(1)
+	}
+
+	cs, err := db.ParseExportCollections(*collections)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid collections: %s\n", err)
+		os.Exit(1)
+	}
+
+	f := db.ExportFilter{TLD: dns.Clean(*tld), Source: *source, Records: *records}
+
+	f.Since, err = parseSince(*since)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+
+	// The domain is split after the config is parsed (see config.PrivateSuffixes)
+	parseConfig(fs, *path)
+	defer db.Disconnect()
+
+	if *domain != "" {
+
+		p := db.GetParts(dns.Clean(*domain))
+		if p == nil || p.Domain == "" || p.TLD == "" || p.Sub != "" {
+			fmt.Fprintf(os.Stderr, "Invalid domain: %s\n", *domain)
+			os.Exit(1)
+		}
+		if f.TLD != "" && f.TLD != p.TLD {
+			fmt.Fprintf(os.Stderr, "The TLD of domain is not %s\n", f.TLD)
+			os.Exit(1)
+		}
+
+		f.Domain = p.Domain
+		f.TLD = p.TLD
+	}
+
+	file, err := os.Create(*out)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create %s: %s\n", *out, err)
+		os.Exit(1)
+	}
+	defer file.Close()
+
+	enc, err := db.NewEncoder(file, *format)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create encoder: %s\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Exporting %s to %s...\n", strings.Join(cs, ", "), *out)
+
+	start := time.Now()
+
+	n, err := db.Export(enc, cs, f, func(n int64) {
+		if n%progressInterval == 0 {
+			fmt.Printf("Exported %d entries (%s)...\n", n, time.Since(start).Round(time.Second))
+		}
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to export after %d entries: %s\n", n, err)
+		os.Exit(1)
+	}
+
+	if err = enc.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to close encoder: %s\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Exported %d entries in %s\n", n, time.Since(start).Round(time.Second))
+}
+
+// runImport runs the "import" subcommand with args.
+//
+// The number of processed entries is saved periodically to "<in>.progress", and with -resume the saved number of entries is skipped.
+// The file is removed after a successful import.
+func runImport(args []string) {
+
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	path := fs.String("config", "", "Path to the config file.")
+	in := fs.String("in", "", "Path to the input file created by export.")
+	resume := fs.Bool("resume", false, "Resume the interrupted import of the input file.")
+	fs.Parse(args)
+
+	if *in == "" {
+		fmt.Fprintf(os.Stderr, "Path to the input file is missing!\n")
+		os.Exit(1)
+	}
+
+	state := *in + ".progress"
+
+	var skip int64
+
+	if *resume {
+
+		out, err := os.ReadFile(state)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			fmt.Fprintf(os.Stderr, "Failed to read %s: %s\n", state, err)
+			os.Exit(1)
+		}
+
+		if err == nil {
+			skip, err = strconv.ParseInt(strings.TrimSpace(string(out)), 10, 64)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Invalid progress in %s: %s\n", state, err)
+				os.Exit(1)
+			}
+		}
+	}
+
+	parseConfig(fs, *path)
+	defer db.Disconnect()
+
+	// The records collection is created by the migrations
+	if db.MongoDB() && config.MigrateOnStartup {
+
+		fmt.Printf("Applying database migrations...\n")
+		if err := db.Migrate(); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to migrate: %s\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// The imported names are split with the current config
+	if err := db.Resplit(); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to split the names again: %s\n", err)
+		os.Exit(1)
+	}
+
+	file, err := os.Open(*in)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to open %s: %s\n", *in, err)
+		os.Exit(1)
+	}
+	defer file.Close()
+
+	dec, err := db.NewDecoder(file)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create decoder: %s\n", err)
+		os.Exit(1)
+	}
+	defer dec.Close()
+
+	if skip > 0 {
+		fmt.Printf("Resuming import of %s after %d entries...\n", *in, skip)
+	} else {
+		fmt.Printf("Importing %s (%s)...\n", *in, dec.Format())
+	}
+
+	start := time.Now()
+
+	progress := func(r db.ImportResult) {
+
+		if r.Total%progressInterval != 0 || r.Total <= skip {
+			return
+		}
+
+		fmt.Printf("Processed %d entries, imported %d, rejected %d (%s)...\n", r.Total, r.Imported, r.Rejected, time.Since(start).Round(time.Second))
+
+		if err := os.WriteFile(state, []byte(strconv.FormatInt(r.Total, 10)), 0644); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to save progress to %s: %s\n", state, err)
+		}
+	}
+
+	reject := func(e *db.ExportEntrySchema, err error) {
+
+		if e.Domain != nil {
+			fmt.Fprintf(os.Stderr, "Rejected %s %s: %s\n", e.Collection, e.Domain.String(), err)
+		} else {
+			fmt.Fprintf(os.Stderr, "Rejected %s entry: %s\n", e.Collection, err)
+		}
+	}
+
+	r, err := db.Import(dec, skip, progress, reject)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to import: %s\n", err)
+		fmt.Fprintf(os.Stderr, "Use -resume to continue the import\n")
+		os.Exit(1)
+	}
+
+	if err = os.Remove(state); err != nil && !errors.Is(err, os.ErrNotExist) {
+		fmt.Fprintf(os.Stderr, "Failed to remove %s: %s\n", state, err)
+	}
+
+	fmt.Printf("Processed %d entries in %s: imported %d, rejected %d, skipped %d\n", r.Total, time.Since(start).Round(time.Second), r.Imported, r.Rejected, r.Skipped)
+}
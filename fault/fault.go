@@ -38,4 +38,15 @@ var (
 	ErrDataBase       = ColumbusError{"Database error"}
 	ErrGetPartsFailed = ColumbusError{"GetParts() failed"}
 	ErrInvalidDays    = ColumbusError{"invalid days"}
+	ErrInvalidLimit   = ColumbusError{"invalid limit"}
+	ErrInvalidSkip    = ColumbusError{"invalid skip"}
+	ErrQueueFull      = ColumbusError{"queue is full"}
+	ErrInvalidSource  = ColumbusError{"invalid source"}
+	ErrInvalidPattern = ColumbusError{"invalid pattern"}
+	ErrInvalidBody    = ColumbusError{"invalid body"}
+	ErrTooManyDomains = ColumbusError{"too many domains"}
+	ErrInvalidType    = ColumbusError{"invalid type"}
+	ErrReasonMissing  = ColumbusError{"reason is missing"}
+	ErrTooBroad       = ColumbusError{"pattern is too broad"}
+	ErrTooManyNames   = ColumbusError{"too many names"}
 )
@@ -6,7 +6,9 @@ require (
 	github.com/elmasy-com/elnet v0.0.0-20230802113148-1a44aa92b75c
 	github.com/elmasy-com/slices v0.0.0-20230712174526-6eb4e5e38b73
 	github.com/gin-gonic/gin v1.9.1
+	go.etcd.io/bbolt v1.3.7
 	go.mongodb.org/mongo-driver v1.12.0
+	golang.org/x/net v0.13.0
 	golang.org/x/text v0.11.0
 	gopkg.in/yaml.v3 v3.0.1
 )
@@ -44,7 +46,6 @@ require (
 	golang.org/x/arch v0.4.0 // indirect
 	golang.org/x/crypto v0.11.0 // indirect
 	golang.org/x/mod v0.12.0 // indirect
-	golang.org/x/net v0.13.0 // indirect
 	golang.org/x/sync v0.3.0 // indirect
 	golang.org/x/sys v0.10.0 // indirect
 	golang.org/x/tools v0.11.1 // indirect
@@ -0,0 +1,166 @@
+// Package idn converts internationalized domain names between the A-label (punycode) and the U-label (Unicode) form.
+package idn
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/net/idna"
+)
+
+// Name is a domain name in both form.
+type Name struct {
+	ASCII   string `json:"ascii"`
+	Unicode string `json:"unicode"`
+}
+
+// Scripts that are used together in a single label legitimately (eg.: Japanese).
+var scriptGroups = map[string]string{
+	"Hiragana": "Han",
+	"Katakana": "Han",
+	"Hangul":   "Han",
+	"Bopomofo": "Han",
+}
+
+// isASCII returns whether s contains only ASCII characters.
+func isASCII(s string) bool {
+
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ToASCII converts the U-labels of d to A-labels (eg.: "bücher.de" -> "xn--bcher-kva.de").
+// ASCII labels are returned unchanged, so labels like "_dmarc" are kept.
+//
+// Returns error if any non-ASCII label is invalid.
+func ToASCII(d string) (string, error) {
+
+	if isASCII(d) {
+		return d, nil
+	}
+
+	labels := strings.Split(d, ".")
+
+	for i := range labels {
+
+		if isASCII(labels[i]) {
+			continue
+		}
+
+		l, err := idna.Lookup.ToASCII(labels[i])
+		if err != nil {
+			return d, err
+		}
+
+		labels[i] = l
+	}
+
+	return strings.Join(labels, "."), nil
+}
+
+// ToUnicode converts the A-labels of d to U-labels (eg.: "xn--bcher-kva.de" -> "bücher.de").
+// Invalid A-labels are returned unchanged.
+func ToUnicode(d string) string {
+
+	if !strings.Contains(d, "xn--") {
+		return d
+	}
+
+	labels := strings.Split(d, ".")
+
+	for i := range labels {
+
+		if !strings.HasPrefix(labels[i], "xn--") {
+			continue
+		}
+
+		l, err := idna.Lookup.ToUnicode(labels[i])
+		if err != nil {
+			continue
+		}
+
+		labels[i] = l
+	}
+
+	return strings.Join(labels, ".")
+}
+
+// NewName returns d in both form.
+func NewName(d string) Name {
+	return Name{ASCII: d, Unicode: ToUnicode(d)}
+}
+
+// NewNames returns every element of ds in both form.
+func NewNames(ds []string) []Name {
+
+	ns := make([]Name, 0, len(ds))
+
+	for i := range ds {
+		ns = append(ns, NewName(ds[i]))
+	}
+
+	return ns
+}
+
+// script returns the name of the script of r.
+// Returns an empty string for the common characters (eg.: digits, "-").
+func script(r rune) string {
+
+	if r < utf8.RuneSelf {
+		if unicode.IsLetter(r) {
+			return "Latin"
+		}
+		return ""
+	}
+
+	for name, table := range unicode.Scripts {
+
+		if name == "Common" || name == "Inherited" || !unicode.Is(table, r) {
+			continue
+		}
+
+		if g, ok := scriptGroups[name]; ok {
+			return g
+		}
+
+		return name
+	}
+
+	return ""
+}
+
+// IsMixedScript returns whether any label of d (in either form) mixes characters from different scripts (eg.: Latin and Cyrillic in "раypal").
+// Mixed script labels are commonly used to spoof other domains.
+func IsMixedScript(d string) bool {
+
+	for _, l := range strings.Split(ToUnicode(d), ".") {
+
+		if isASCII(l) {
+			continue
+		}
+
+		first := ""
+
+		for _, r := range l {
+
+			s := script(r)
+			if s == "" {
+				continue
+			}
+
+			if first == "" {
+				first = s
+			} else if s != first {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,42 @@
+package idn
+
+import "testing"
+
+func TestConvert(t *testing.T) {
+
+	a, err := ToASCII("www.Bücher.de")
+	if err != nil {
+		t.Fatalf("FAIL: %s\n", err)
+	}
+
+	if a != "www.xn--bcher-kva.de" {
+		t.Fatalf("FAIL: Invalid A-label: %s\n", a)
+	}
+
+	if u := ToUnicode(a); u != "www.bücher.de" {
+		t.Fatalf("FAIL: Invalid U-label: %s\n", u)
+	}
+
+	// ASCII names must not be changed
+	if a, _ := ToASCII("_dmarc.example.com"); a != "_dmarc.example.com" {
+		t.Fatalf("FAIL: ASCII name changed: %s\n", a)
+	}
+}
+
+func TestIsMixedScript(t *testing.T) {
+
+	cases := map[string]bool{
+		"example.com":        false,
+		"bücher.de":          false,
+		"пример.рф":          false,
+		"раypal.com":         true, // Cyrillic "р" and "а"
+		"xn--ypal-43d9g.com": true,
+		"ひらがな漢字.jp":          false,
+	}
+
+	for d, want := range cases {
+		if got := IsMixedScript(d); got != want {
+			t.Fatalf("FAIL: %s: want %v, got %v\n", d, want, got)
+		}
+	}
+}
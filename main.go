@@ -60,11 +60,55 @@ func checkUpdate() {
 	os.Exit(0)
 }
 
+// openStore opens the store selected in the config and sets it as the store of db.
+// Exits on error.
+func openStore() {
+
+	switch config.Store {
+	case "embedded":
+
+		fmt.Printf("Opening embedded store...\n")
+		s, err := db.NewBoltStore(config.StorePath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to open embedded store: %s\n", err)
+			os.Exit(1)
+		}
+
+		db.SetStore(s)
+
+	default:
+
+		fmt.Printf("Connecting to MongoDB...\n")
+		if err := db.Connect(config.MongoURI); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to connect to MongoDB: %s\n", err)
+			os.Exit(1)
+		}
+	}
+
+	fmt.Printf("Loading blocklist...\n")
+	if err := db.BlockedLoad(); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load blocklist: %s\n", err)
+		os.Exit(1)
+	}
+}
+
 func main() {
 
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "export":
+			runExport(os.Args[2:])
+			return
+		case "import":
+			runImport(os.Args[2:])
+			return
+		}
+	}
+
 	path := flag.String("config", "", "Path to the config file.")
 	version := flag.Bool("version", false, "Print version informations.")
 	check := flag.Bool("check", false, "Check for updates.")
+	migrate := flag.Bool("migrate", false, "Apply the database migrations and exit.")
 	flag.Parse()
 
 	if *version {
@@ -94,12 +138,35 @@ func main() {
 	// Use common DNS servers
 	dns.UpdateConfCommon()
 
-	fmt.Printf("Connecting to MongoDB...\n")
-	if err := db.Connect(config.MongoURI); err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to connect to MongoDB: %s\n", err)
+	openStore()
+	defer db.Disconnect()
+
+	if *migrate && !db.MongoDB() {
+		fmt.Fprintf(os.Stderr, "Migrations are available only with MongoDB\n")
 		os.Exit(1)
 	}
-	defer db.Disconnect()
+
+	if db.MongoDB() && (*migrate || config.MigrateOnStartup) {
+
+		fmt.Printf("Applying database migrations...\n")
+		if err := db.Migrate(); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to migrate: %s\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if err := db.Resplit(); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to split the names again: %s\n", err)
+		os.Exit(1)
+	}
+
+	if *migrate {
+		return
+	}
+
+	if config.CacheSize > 0 {
+		db.CacheSetBackend(db.NewMemoryCache(config.CacheSize, config.CacheTTL))
+	}
 
 	fmt.Printf("Starting db.StatisticsInsertWorker...\n")
 	go db.StatisticsInsertWorker()
@@ -107,9 +174,85 @@ func main() {
 	fmt.Printf("Starting db.StatisticsCleanWorker...\n")
 	go db.StatisticsCleanWorker()
 
+	fmt.Printf("Starting db.BlockedWorker...\n")
+	go db.BlockedWorker()
+
+	if err := db.RetentionClear(); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to clear the retention of the records: %s\n", err)
+		os.Exit(1)
+	}
+
+	if config.RetentionNames > 0 || config.RetentionRecords > 0 || config.RetentionRecordCap > 0 {
+		fmt.Printf("Starting db.RetentionWorker...\n")
+		go db.RetentionWorker()
+	}
+
+	if config.DumpDir != "" {
+		fmt.Printf("Starting db.DumpWorker...\n")
+		go db.DumpWorker()
+	}
+
 	fmt.Printf("Starting RecordUpdater...\n")
 	go db.RecordsUpdater()
 
+	if db.MongoDB() {
+
+		fmt.Printf("Starting db.StatisticsTLDWorker...\n")
+		go db.StatisticsTLDWorker()
+
+		fmt.Printf("Starting db.TopListBucketsCleanWorker...\n")
+		go db.TopListBucketsCleanWorker()
+
+		fmt.Printf("Starting db.WordlistWorker...\n")
+		go db.WordlistWorker()
+
+		fmt.Printf("Starting db.BackfillWorker...\n")
+		go db.BackfillWorker()
+	}
+
+	if config.BruteForce {
+
+		var labels []string
+
+		if config.BruteForceWordlist != "" {
+
+			fmt.Printf("Loading brute-force wordlist...\n")
+
+			var err error
+
+			labels, err = db.BruteForceLoadWordlist(config.BruteForceWordlist)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to load brute-force wordlist: %s\n", err)
+				os.Exit(1)
+			}
+			if len(labels) == 0 {
+				fmt.Fprintf(os.Stderr, "Failed to load brute-force wordlist: wordlist is empty\n")
+				os.Exit(1)
+			}
+
+			fmt.Printf("Starting BruteForceUpdater with %d labels...\n", len(labels))
+		} else {
+			// The generated wordlist can be empty on a new database, the labels are loaded at the first brute-force
+			fmt.Printf("Starting BruteForceUpdater with the generated wordlist...\n")
+		}
+
+		db.BruteForceUpdater(labels)
+	}
+
+	if db.MongoDB() {
+
+		fmt.Printf("Starting db.NotFoundInserter...\n")
+		go db.NotFoundInserter()
+
+		fmt.Printf("Starting db.NotFoundWorker...\n")
+		go db.NotFoundWorker()
+	}
+
+	if config.Bloom {
+		fmt.Printf("Starting db.BloomWorker...\n")
+		go db.BloomWorker()
+	}
+
 	fmt.Printf("Starting HTTP server...\n")
 	if err := server.Run(); err != nil {
 		fmt.Fprintf(os.Stderr, "Server failed: %s\n", err)
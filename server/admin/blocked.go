@@ -0,0 +1,130 @@
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/elmasy-com/columbus-server/db"
+	"github.com/elmasy-com/columbus-server/fault"
+	"github.com/gin-gonic/gin"
+)
+
+type BlockedRequest struct {
+	Pattern string `json:"pattern"`
+	Reason  string `json:"reason"`
+}
+
+// blockedError sends the error of db.Block() or db.Unblock().
+func blockedError(c *gin.Context, err error) {
+
+	c.Error(err)
+
+	respCode := 0
+
+	switch {
+	case errors.Is(err, fault.ErrInvalidPattern), errors.Is(err, fault.ErrInvalidDomain), errors.Is(err, fault.ErrReasonMissing):
+		respCode = http.StatusBadRequest
+	case errors.Is(err, fault.ErrNothingToDo):
+		respCode = http.StatusConflict
+	case errors.Is(err, fault.ErrNotFound):
+		respCode = http.StatusNotFound
+	default:
+		respCode = http.StatusInternalServerError
+		err = fmt.Errorf("internal server error")
+	}
+
+	c.JSON(respCode, gin.H{"error": err.Error()})
+}
+
+// GET /api/admin/blocked
+// Returns the blocked entries sorted by pattern.
+func GetApiBlocked(c *gin.Context) {
+
+	bs, err := db.BlockedGets()
+	if err != nil {
+
+		c.Error(err)
+
+		if c.GetHeader("Accept") == "text/plain" {
+			c.String(http.StatusInternalServerError, "internal server error")
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		}
+		return
+	}
+
+	if c.GetHeader("Accept") == "text/plain" {
+
+		patterns := make([]string, 0, len(bs))
+
+		for i := range bs {
+			patterns = append(patterns, bs[i].Pattern)
+		}
+
+		c.String(http.StatusOK, strings.Join(patterns, "\n"))
+	} else {
+		c.JSON(http.StatusOK, bs)
+	}
+}
+
+// POST /api/admin/blocked
+// Blocks the domain or the glob-style pattern in the body ({"pattern": "...", "reason": "..."}).
+func PostApiBlocked(c *gin.Context) {
+
+	var r BlockedRequest
+
+	err := c.ShouldBindJSON(&r)
+	if err != nil {
+		c.Error(fmt.Errorf("%w: %v", fault.ErrInvalidBody, err))
+		c.JSON(http.StatusBadRequest, fault.ErrInvalidBody)
+		return
+	}
+
+	b, err := db.Block(r.Pattern, r.Reason, c.GetString("user"))
+	if err != nil {
+		blockedError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, b)
+}
+
+// DELETE /api/admin/blocked?pattern=&reason=
+// Removes the blocked entry pattern.
+func DeleteApiBlocked(c *gin.Context) {
+
+	err := db.Unblock(c.Query("pattern"), c.Query("reason"), c.GetString("user"))
+	if err != nil {
+		blockedError(c, err)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+// GET /api/admin/blocked/audit?limit=
+// Returns the audit trail of the blocklist, the newest first.
+func GetApiBlockedAudit(c *gin.Context) {
+
+	limit, err := getQueryInt(c, "limit", DefaultLimit)
+	if err != nil || limit < 1 || limit > db.MaxBlockedAudit {
+		c.Error(fault.ErrInvalidLimit)
+		c.JSON(http.StatusBadRequest, fault.ErrInvalidLimit)
+		return
+	}
+
+	as, err := db.BlockedAuditGets(limit)
+	if err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	if as == nil {
+		as = make([]db.BlockedAuditSchema, 0)
+	}
+
+	c.JSON(http.StatusOK, as)
+}
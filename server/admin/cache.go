@@ -0,0 +1,22 @@
+package admin
+
+import (
+	"net/http"
+
+	"github.com/elmasy-com/columbus-server/db"
+	"github.com/gin-gonic/gin"
+)
+
+// GET /api/admin/cache
+// Returns the metrics of the lookup cache.
+func GetApiCache(c *gin.Context) {
+
+	c.JSON(http.StatusOK, db.CacheStatistics())
+}
+
+// GET /api/admin/bloom
+// Returns the metrics of the Bloom filter of the known domains.
+func GetApiBloom(c *gin.Context) {
+
+	c.JSON(http.StatusOK, db.BloomStatistics())
+}
@@ -0,0 +1,86 @@
+package admin
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/elmasy-com/columbus-server/db"
+	"github.com/elmasy-com/columbus-server/fault"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	DefaultLimit = 100
+	MaxLimit     = 10000
+)
+
+// Return the integer value of the query parameter name.
+// If not set, returns def.
+func getQueryInt(c *gin.Context, name string, def int) (int, error) {
+
+	vStr, vSet := c.GetQuery(name)
+	if !vSet {
+		return def, nil
+	}
+
+	if vStr == "" {
+		return 0, fmt.Errorf("empty")
+	}
+
+	return strconv.Atoi(vStr)
+}
+
+// GET /api/admin/notfound?limit=&skip=
+// Returns the domains that requested but not found, sorted by the number of requests.
+func GetApiNotFound(c *gin.Context) {
+
+	limit, err := getQueryInt(c, "limit", DefaultLimit)
+	if err != nil || limit < 1 || limit > MaxLimit {
+		c.Error(fault.ErrInvalidLimit)
+		if c.GetHeader("Accept") == "text/plain" {
+			c.String(http.StatusBadRequest, fault.ErrInvalidLimit.Err)
+		} else {
+			c.JSON(http.StatusBadRequest, fault.ErrInvalidLimit)
+		}
+		return
+	}
+
+	skip, err := getQueryInt(c, "skip", 0)
+	if err != nil || skip < 0 {
+		c.Error(fault.ErrInvalidSkip)
+		if c.GetHeader("Accept") == "text/plain" {
+			c.String(http.StatusBadRequest, fault.ErrInvalidSkip.Err)
+		} else {
+			c.JSON(http.StatusBadRequest, fault.ErrInvalidSkip)
+		}
+		return
+	}
+
+	nfs, err := db.NotFoundGets(limit, skip)
+	if err != nil {
+
+		c.Error(err)
+
+		if c.GetHeader("Accept") == "text/plain" {
+			c.String(http.StatusInternalServerError, "internal server error")
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		}
+		return
+	}
+
+	if c.GetHeader("Accept") == "text/plain" {
+
+		doms := make([]string, 0, len(nfs))
+
+		for i := range nfs {
+			doms = append(doms, nfs[i].Domain)
+		}
+
+		c.String(http.StatusOK, strings.Join(doms, "\n"))
+	} else {
+		c.JSON(http.StatusOK, nfs)
+	}
+}
@@ -0,0 +1,23 @@
+package admin
+
+import (
+	"net/http"
+
+	"github.com/elmasy-com/columbus-server/db"
+	"github.com/elmasy-com/columbus-server/fault"
+	"github.com/gin-gonic/gin"
+)
+
+// GET /api/admin/retention
+// Returns the report of the last run of the retention policy.
+func GetApiRetention(c *gin.Context) {
+
+	r := db.RetentionReport()
+	if r == nil {
+		c.Error(fault.ErrNotFound)
+		c.JSON(http.StatusNotFound, fault.ErrNotFound)
+		return
+	}
+
+	c.JSON(http.StatusOK, r)
+}
@@ -0,0 +1,61 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/elmasy-com/columbus-server/config"
+	"github.com/elmasy-com/columbus-server/fault"
+	"github.com/gin-gonic/gin"
+)
+
+// RequireAdmin aborts the request if the API key in the X-Api-Key header is missing or not in config.AdminKeys.
+// The name of the admin is stored in the context with the "user" key.
+func RequireAdmin(c *gin.Context) {
+
+	key := c.GetHeader("X-Api-Key")
+	if key == "" {
+		c.Error(fault.ErrMissingAPIKey)
+		c.AbortWithStatusJSON(http.StatusUnauthorized, fault.ErrMissingAPIKey)
+		return
+	}
+
+	for name, k := range config.AdminKeys {
+		if subtle.ConstantTimeCompare([]byte(key), []byte(k)) == 1 {
+			c.Set("user", name)
+			return
+		}
+	}
+
+	c.Error(fault.ErrNotAdmin)
+	c.AbortWithStatusJSON(http.StatusForbidden, fault.ErrNotAdmin)
+}
+
+// RequireAPIKey aborts the request if the API key in the X-Api-Key header is missing or not in config.APIKeys or config.AdminKeys.
+// The name of the user is stored in the context with the "user" key.
+func RequireAPIKey(c *gin.Context) {
+
+	key := c.GetHeader("X-Api-Key")
+	if key == "" {
+		c.Error(fault.ErrMissingAPIKey)
+		c.AbortWithStatusJSON(http.StatusUnauthorized, fault.ErrMissingAPIKey)
+		return
+	}
+
+	for name, k := range config.APIKeys {
+		if subtle.ConstantTimeCompare([]byte(key), []byte(k)) == 1 {
+			c.Set("user", name)
+			return
+		}
+	}
+
+	for name, k := range config.AdminKeys {
+		if subtle.ConstantTimeCompare([]byte(key), []byte(k)) == 1 {
+			c.Set("user", name)
+			return
+		}
+	}
+
+	c.Error(fault.ErrInvalidAPIKey)
+	c.AbortWithStatusJSON(http.StatusUnauthorized, fault.ErrInvalidAPIKey)
+}
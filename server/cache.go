@@ -0,0 +1,14 @@
+package server
+
+import (
+	"github.com/elmasy-com/columbus-server/config"
+	"github.com/gin-gonic/gin"
+)
+
+// cacheControl sets the Cache-Control header configured for the route in config.CacheControl.
+func cacheControl(c *gin.Context) {
+
+	if v, ok := config.CacheControl[c.FullPath()]; ok {
+		c.Header("Cache-Control", v)
+	}
+}
@@ -0,0 +1,53 @@
+package discovery
+
+import (
+	"net/http"
+
+	"github.com/elmasy-com/columbus-server/db"
+	"github.com/elmasy-com/columbus-server/fault"
+	"github.com/elmasy-com/elnet/dns"
+	"github.com/gin-gonic/gin"
+)
+
+// POST /api/bruteforce/{domain}
+// Queue domain to brute-force the configured wordlist under it.
+func PostApiBruteForce(c *gin.Context) {
+
+	d := c.Param("domain")
+
+	if !dns.IsValid(d) {
+		c.Error(fault.ErrInvalidDomain)
+		c.JSON(http.StatusBadRequest, fault.ErrInvalidDomain)
+		return
+	}
+
+	d = db.BruteForceZone(d)
+
+	if db.IsPublicSuffix(d) {
+		c.Error(fault.ErrPublicSuffix)
+		c.JSON(http.StatusBadRequest, fault.ErrPublicSuffix)
+		return
+	}
+
+	if db.GetDomain(d) == "" {
+		c.Error(fault.ErrInvalidDomain)
+		c.JSON(http.StatusBadRequest, fault.ErrInvalidDomain)
+		return
+	}
+
+	if db.IsBlocked(d) {
+		c.Error(fault.ErrBlocked)
+		c.JSON(http.StatusUnavailableForLegalReasons, fault.ErrBlocked)
+		return
+	}
+
+	if len(db.BruteForceChan) >= cap(db.BruteForceChan) {
+		c.Error(fault.ErrQueueFull)
+		c.JSON(http.StatusServiceUnavailable, fault.ErrQueueFull)
+		return
+	}
+
+	db.BruteForceChan <- d
+
+	c.JSON(http.StatusAccepted, gin.H{"result": d})
+}
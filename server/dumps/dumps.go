@@ -0,0 +1,61 @@
+package dumps
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/elmasy-com/columbus-server/db"
+	"github.com/elmasy-com/columbus-server/fault"
+	"github.com/elmasy-com/columbus-server/server/etag"
+	"github.com/gin-gonic/gin"
+)
+
+// GetApiDumps returns the manifest of the dump files.
+// With "Accept: text/plain", returns the file names one per line.
+func GetApiDumps(c *gin.Context) {
+
+	m := db.DumpManifest()
+	if m == nil {
+		c.Error(fault.ErrNotFound)
+		c.JSON(http.StatusNotFound, fault.ErrNotFound)
+		return
+	}
+
+	if etag.Check(c, m.Generated, m) {
+		return
+	}
+
+	if c.GetHeader("Accept") == "text/plain" {
+
+		names := make([]string, 0, len(m.Files))
+
+		for i := range m.Files {
+			names = append(names, m.Files[i].Name)
+		}
+
+		c.String(http.StatusOK, strings.Join(names, "\n"))
+		return
+	}
+
+	c.JSON(http.StatusOK, m)
+}
+
+// GetApiDumpsFile serves the dump file with name :file.
+func GetApiDumpsFile(c *gin.Context) {
+
+	path, err := db.DumpPath(c.Param("file"))
+	if err != nil {
+
+		c.Error(err)
+
+		if errors.Is(err, fault.ErrNotFound) {
+			c.JSON(http.StatusNotFound, fault.ErrNotFound)
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		}
+		return
+	}
+
+	c.FileAttachment(path, c.Param("file"))
+}
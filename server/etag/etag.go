@@ -0,0 +1,93 @@
+// Package etag implements the conditional requests with the ETag and Last-Modified headers.
+package etag
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/elmasy-com/columbus-server/fault"
+	"github.com/gin-gonic/gin"
+)
+
+// New returns the ETag of the response value v (see Check()) in the representation accept (the Accept header).
+func New(accept string, v interface{}) (string, error) {
+
+	out, err := json.Marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal: %w", err)
+	}
+
+	h := sha256.New()
+	h.Write([]byte(accept))
+	h.Write([]byte{0})
+	h.Write(out)
+
+	return `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`, nil
+}
+
+// match returns whether the If-None-Match header value inm contains tag.
+// Uses the weak comparison.
+func match(inm string, tag string) bool {
+
+	for _, t := range strings.Split(inm, ",") {
+
+		t = strings.TrimPrefix(strings.TrimSpace(t), "W/")
+
+		if t == "*" || t == tag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Check sets the ETag header computed from v and the Last-Modified header from modified (Unix timestamp, ignored if 0).
+// v is the response value or any value that changes with the response (eg.: the newest timestamp and the number of the names),
+// the latter can be checked before the response is queried.
+// If the client has a fresh copy (If-None-Match matches or, if not set, If-Modified-Since is not before modified),
+// the status is set to 304 and returns true, the caller must not write the body.
+//
+// If failed to compute the ETag, the error is set in c and returns false.
+func Check(c *gin.Context, modified int64, v interface{}) bool {
+
+	tag, err := New(c.GetHeader("Accept"), v)
+	if err != nil {
+		c.Error(fmt.Errorf("failed to compute ETag: %w", err))
+		return false
+	}
+
+	c.Header("ETag", tag)
+	c.Header("Vary", "Accept")
+
+	if modified > 0 {
+		c.Header("Last-Modified", time.Unix(modified, 0).UTC().Format(http.TimeFormat))
+	}
+
+	if inm := c.GetHeader("If-None-Match"); inm != "" {
+
+		// If-Modified-Since is ignored if If-None-Match is set
+		if !match(inm, tag) {
+			return false
+		}
+
+	} else if ims := c.GetHeader("If-Modified-Since"); ims != "" && modified > 0 {
+
+		t, err := http.ParseTime(ims)
+		if err != nil || time.Unix(modified, 0).After(t) {
+			return false
+		}
+
+	} else {
+		return false
+	}
+
+	c.Error(fault.ErrNotModified)
+	c.Status(http.StatusNotModified)
+
+	return true
+}
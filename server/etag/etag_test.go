@@ -0,0 +1,61 @@
+package etag
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCheck(t *testing.T) {
+
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.GET("/", func(c *gin.Context) {
+		if Check(c, 1700000000, []string{"www", "mail"}) {
+			return
+		}
+		c.JSON(http.StatusOK, []string{"www", "mail"})
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+	tag := w.Header().Get("ETag")
+
+	if w.Code != http.StatusOK || tag == "" || w.Header().Get("Last-Modified") == "" {
+		t.Fatalf("FAIL: Invalid first response: %d, %v\n", w.Code, w.Header())
+	}
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("If-None-Match", "W/"+tag)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+
+	if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Fatalf("FAIL: If-None-Match: want 304 without body, got %d, %q\n", w.Code, w.Body.String())
+	}
+
+	r = httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("If-Modified-Since", time.Unix(1700000000, 0).UTC().Format(http.TimeFormat))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+
+	if w.Code != http.StatusNotModified {
+		t.Fatalf("FAIL: If-Modified-Since: want 304, got %d\n", w.Code)
+	}
+
+	// Different representation must have different ETag
+	r = httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("If-None-Match", tag)
+	r.Header.Set("Accept", "text/plain")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("FAIL: Other representation: want 200, got %d\n", w.Code)
+	}
+}
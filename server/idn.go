@@ -0,0 +1,24 @@
+package server
+
+import (
+	"github.com/elmasy-com/columbus-server/idn"
+	"github.com/gin-gonic/gin"
+)
+
+// normalizeParams converts the internationalized names in the "domain" and "fqdn" path parameters to A-label form,
+// so every endpoint accepts U-label input (eg.: /api/lookup/bücher.de).
+// Invalid names are left unchanged, the handlers returns the proper error.
+func normalizeParams(c *gin.Context) {
+
+	for i := range c.Params {
+
+		if c.Params[i].Key != "domain" && c.Params[i].Key != "fqdn" {
+			continue
+		}
+
+		a, err := idn.ToASCII(c.Params[i].Value)
+		if err == nil {
+			c.Params[i].Value = a
+		}
+	}
+}
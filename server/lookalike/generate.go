@@ -0,0 +1,190 @@
+package lookalike
+
+import (
+	"strings"
+
+	"github.com/elmasy-com/elnet/dns"
+	"golang.org/x/net/idna"
+)
+
+const (
+	KindOmission      = "omission"
+	KindTransposition = "transposition"
+	KindHomoglyph     = "homoglyph"
+	KindIDN           = "idn"
+	KindBitFlip       = "bitflip"
+	KindTLDSwap       = "tldswap"
+)
+
+var (
+	// ASCII characters (or sequences) that looks like each other.
+	homoglyphs = [][2]string{
+		{"o", "0"}, {"0", "o"},
+		{"l", "1"}, {"1", "l"},
+		{"i", "1"}, {"1", "i"},
+		{"i", "l"}, {"l", "i"},
+		{"m", "rn"}, {"rn", "m"},
+		{"m", "nn"},
+		{"w", "vv"}, {"vv", "w"},
+		{"d", "cl"}, {"cl", "d"},
+		{"g", "q"}, {"q", "g"},
+		{"u", "v"}, {"v", "u"},
+	}
+
+	// Unicode confusables of ASCII characters used in IDN labels.
+	confusables = map[rune][]rune{
+		'a': {'а'},      // CYRILLIC SMALL LETTER A
+		'c': {'с'},      // CYRILLIC SMALL LETTER ES
+		'e': {'е'},      // CYRILLIC SMALL LETTER IE
+		'i': {'і'},      // CYRILLIC SMALL LETTER BYELORUSSIAN-UKRAINIAN I
+		'j': {'ј'},      // CYRILLIC SMALL LETTER JE
+		'o': {'о', 'ο'}, // CYRILLIC SMALL LETTER O, GREEK SMALL LETTER OMICRON
+		'p': {'р'},      // CYRILLIC SMALL LETTER ER
+		's': {'ѕ'},      // CYRILLIC SMALL LETTER DZE
+		'x': {'х'},      // CYRILLIC SMALL LETTER HA
+		'y': {'у'},      // CYRILLIC SMALL LETTER U
+	}
+)
+
+// Candidate is a generated lookalike domain and the kind of technique used to generate it.
+type Candidate struct {
+	Domain string `json:"domain"`
+	Kind   string `json:"kind"`
+}
+
+type generator struct {
+	domain string
+	tld    string
+	known  map[string]struct{}
+	result []Candidate
+}
+
+// add appends label.tld to the result if it is valid and not known yet.
+func (g *generator) add(label string, tld string, kind string) {
+
+	if label == "" || strings.HasPrefix(label, "-") || strings.HasSuffix(label, "-") {
+		return
+	}
+
+	d := label + "." + tld
+
+	if _, ok := g.known[d]; ok {
+		return
+	}
+
+	if !dns.IsValid(d) {
+		return
+	}
+
+	g.known[d] = struct{}{}
+	g.result = append(g.result, Candidate{Domain: d, Kind: kind})
+}
+
+// omission removes every character once (eg.: example -> exmple).
+func (g *generator) omission() {
+
+	for i := range g.domain {
+		g.add(g.domain[:i]+g.domain[i+1:], g.tld, KindOmission)
+	}
+}
+
+// transposition swaps every adjacent characters (eg.: example -> exmaple).
+func (g *generator) transposition() {
+
+	for i := 0; i < len(g.domain)-1; i++ {
+
+		b := []byte(g.domain)
+		b[i], b[i+1] = b[i+1], b[i]
+
+		g.add(string(b), g.tld, KindTransposition)
+	}
+}
+
+// homoglyph replaces every ASCII homoglyph once (eg.: example -> examp1e).
+func (g *generator) homoglyph() {
+
+	for _, h := range homoglyphs {
+
+		orig, rep := h[0], h[1]
+
+		for i := 0; i+len(orig) <= len(g.domain); i++ {
+			if g.domain[i:i+len(orig)] == orig {
+				g.add(g.domain[:i]+rep+g.domain[i+len(orig):], g.tld, KindHomoglyph)
+			}
+		}
+	}
+}
+
+// idn replaces every character that has a Unicode confusable once and converts the label to punycode (eg.: example -> xn--xample-2of).
+func (g *generator) idn() {
+
+	// The domain is an IDN already
+	if strings.HasPrefix(g.domain, "xn--") {
+		return
+	}
+
+	rs := []rune(g.domain)
+
+	for i := range rs {
+
+		for _, c := range confusables[rs[i]] {
+
+			orig := rs[i]
+			rs[i] = c
+
+			label, err := idna.ToASCII(string(rs))
+			if err == nil {
+				g.add(label, g.tld, KindIDN)
+			}
+
+			rs[i] = orig
+		}
+	}
+}
+
+// bitFlip flips every bit of every character once and keeps the valid ones (eg.: example -> axample).
+func (g *generator) bitFlip() {
+
+	for i := 0; i < len(g.domain); i++ {
+
+		for bit := 0; bit < 8; bit++ {
+
+			c := g.domain[i] ^ (1 << bit)
+
+			if (c < 'a' || c > 'z') && (c < '0' || c > '9') && c != '-' {
+				continue
+			}
+
+			g.add(g.domain[:i]+string(c)+g.domain[i+1:], g.tld, KindBitFlip)
+		}
+	}
+}
+
+// tldSwap keeps the domain and swaps the TLD with every TLD in tlds.
+func (g *generator) tldSwap(tlds []string) {
+
+	for i := range tlds {
+		if tlds[i] != g.tld {
+			g.add(g.domain, tlds[i], KindTLDSwap)
+		}
+	}
+}
+
+// Generate returns the lookalike candidates of domain.tld (eg.: "example" and "com").
+// domain must be a Second Level Domain without the TLD.
+// tlds is the list of TLDs used to swap the TLD (eg.: the known TLDs of the domain).
+//
+// The original domain is never returned.
+func Generate(domain string, tld string, tlds []string) []Candidate {
+
+	g := generator{domain: domain, tld: tld, known: map[string]struct{}{domain + "." + tld: {}}}
+
+	g.omission()
+	g.transposition()
+	g.homoglyph()
+	g.idn()
+	g.bitFlip()
+	g.tldSwap(tlds)
+
+	return g.result
+}
@@ -0,0 +1,45 @@
+package lookalike
+
+import "testing"
+
+func TestGenerate(t *testing.T) {
+
+	cs := Generate("example", "com", []string{"com", "org", "net"})
+
+	kinds := make(map[string]string)
+
+	for i := range cs {
+		kinds[cs[i].Domain] = cs[i].Kind
+	}
+
+	if _, ok := kinds["example.com"]; ok {
+		t.Fatalf("FAIL: The original domain is generated\n")
+	}
+
+	for d, k := range map[string]string{
+		"exmple.com":  KindOmission,
+		"exmaple.com": KindTransposition,
+		"examp1e.com": KindHomoglyph,
+		"axample.com": KindBitFlip,
+		"example.org": KindTLDSwap,
+	} {
+		if kinds[d] != k {
+			t.Fatalf("FAIL: %s is not generated as %s: %s\n", d, k, kinds[d])
+		}
+	}
+
+	idn := 0
+
+	for d, k := range kinds {
+		if k == KindIDN {
+			idn++
+			if d[:4] != "xn--" {
+				t.Fatalf("FAIL: Invalid IDN candidate: %s\n", d)
+			}
+		}
+	}
+
+	if idn == 0 {
+		t.Fatalf("FAIL: No IDN candidate generated\n")
+	}
+}
@@ -0,0 +1,130 @@
+package lookalike
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/elmasy-com/columbus-server/db"
+	"github.com/elmasy-com/columbus-server/fault"
+	"github.com/elmasy-com/elnet/dns"
+	"github.com/gin-gonic/gin"
+)
+
+// Result is an existing lookalike domain.
+type Result struct {
+	Kind string `json:"kind"`
+	db.LookalikeSchema
+}
+
+// GET /api/lookalike/{domain}
+// Generates typosquat candidates of domain and returns the ones that exist in the DB with their records and first-seen time.
+func GetApiLookalike(c *gin.Context) {
+
+	d := c.Param("domain")
+
+	if !dns.IsValid(d) {
+
+		c.Error(fault.ErrInvalidDomain)
+
+		if c.GetHeader("Accept") == "text/plain" {
+			c.String(http.StatusBadRequest, fault.ErrInvalidDomain.Err)
+		} else {
+			c.JSON(http.StatusBadRequest, fault.ErrInvalidDomain)
+		}
+		return
+	}
+
+	if db.IsPublicSuffix(dns.Clean(d)) {
+
+		c.Error(fault.ErrPublicSuffix)
+
+		if c.GetHeader("Accept") == "text/plain" {
+			c.String(http.StatusBadRequest, fault.ErrPublicSuffix.Err)
+		} else {
+			c.JSON(http.StatusBadRequest, fault.ErrPublicSuffix)
+		}
+		return
+	}
+
+	p := db.GetParts(dns.Clean(d))
+	if p == nil || p.Domain == "" || p.TLD == "" {
+
+		c.Error(fault.ErrGetPartsFailed)
+
+		if c.GetHeader("Accept") == "text/plain" {
+			c.String(http.StatusBadRequest, fault.ErrInvalidDomain.Err)
+		} else {
+			c.JSON(http.StatusBadRequest, fault.ErrInvalidDomain)
+		}
+		return
+	}
+
+	tlds, err := db.TLD(p.Domain)
+	if err != nil {
+
+		c.Error(fmt.Errorf("failed to get TLDs: %w", err))
+
+		if c.GetHeader("Accept") == "text/plain" {
+			c.String(http.StatusInternalServerError, "internal server error")
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		}
+		return
+	}
+
+	candidates := Generate(p.Domain, p.TLD, tlds)
+
+	kinds := make(map[string]string, len(candidates))
+	doms := make([]string, 0, len(candidates))
+
+	for i := range candidates {
+		kinds[candidates[i].Domain] = candidates[i].Kind
+		doms = append(doms, candidates[i].Domain)
+	}
+
+	ls, err := db.Lookalikes(doms)
+	if err != nil {
+
+		c.Error(err)
+
+		if c.GetHeader("Accept") == "text/plain" {
+			c.String(http.StatusInternalServerError, "internal server error")
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		}
+		return
+	}
+
+	if len(ls) == 0 {
+
+		c.Error(fault.ErrNotFound)
+
+		if c.GetHeader("Accept") == "text/plain" {
+			c.String(http.StatusNotFound, fault.ErrNotFound.Err)
+		} else {
+			c.JSON(http.StatusNotFound, fault.ErrNotFound)
+		}
+		return
+	}
+
+	if c.GetHeader("Accept") == "text/plain" {
+
+		names := make([]string, 0, len(ls))
+
+		for i := range ls {
+			names = append(names, ls[i].Domain)
+		}
+
+		c.String(http.StatusOK, strings.Join(names, "\n"))
+		return
+	}
+
+	results := make([]Result, 0, len(ls))
+
+	for i := range ls {
+		results = append(results, Result{Kind: kinds[ls[i].Domain], LookalikeSchema: ls[i]})
+	}
+
+	c.JSON(http.StatusOK, results)
+}
@@ -0,0 +1,90 @@
+package lookup
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/elmasy-com/columbus-server/config"
+	"github.com/elmasy-com/columbus-server/db"
+	"github.com/elmasy-com/columbus-server/fault"
+	"github.com/elmasy-com/columbus-server/idn"
+	"github.com/gin-gonic/gin"
+)
+
+// BatchResult is the result of a single domain in the batch lookup.
+// Either Subdomains (Names with unicode) or Error is set.
+type BatchResult struct {
+	Subdomains []string   `json:"subdomains,omitempty"`
+	Names      []idn.Name `json:"names,omitempty"`
+	Error      string     `json:"error,omitempty"`
+}
+
+// POST /api/lookup?days=&source=&type=&value=&unicode=
+// The body is a JSON list of domains (at most config.BatchLookupMax).
+// Returns a map of domain -> subdomains (or the error of the domain).
+// If unicode is "true", the A-label and the U-label form of the subdomains are returned in "names".
+// The query parameters are the same as in GET /api/lookup/{domain}.
+//
+// Batch lookups are not counted in the toplist and the not found domains are not recorded.
+func PostApiLookup(c *gin.Context) {
+
+	f, err := getLookupFilter(c)
+	if err != nil {
+		c.Error(err)
+		c.JSON(http.StatusBadRequest, err)
+		return
+	}
+
+	var ds []string
+
+	err = c.ShouldBindJSON(&ds)
+	if err != nil || len(ds) == 0 {
+		c.Error(fmt.Errorf("%w: %v", fault.ErrInvalidBody, err))
+		c.JSON(http.StatusBadRequest, fault.ErrInvalidBody)
+		return
+	}
+
+	if len(ds) > config.BatchLookupMax {
+		c.Error(fault.ErrTooManyDomains)
+		c.JSON(http.StatusBadRequest, fault.ErrTooManyDomains)
+		return
+	}
+
+	// Accept U-label input, like the path parameters
+	names := make([]string, len(ds))
+
+	for i := range ds {
+
+		names[i] = ds[i]
+
+		if a, err := idn.ToASCII(ds[i]); err == nil {
+			names[i] = a
+		}
+	}
+
+	unicode := c.Query("unicode") == "true"
+
+	results := make(map[string]BatchResult, len(ds))
+
+	for i, r := range db.LookupMany(names, f, config.BatchLookupWorker) {
+
+		switch {
+		case r.Err == nil && len(r.Subs) == 0:
+			results[ds[i]] = BatchResult{Error: fault.ErrNotFound.Err}
+		case r.Err == nil && unicode:
+			results[ds[i]] = BatchResult{Names: idn.NewNames(r.Subs)}
+		case r.Err == nil:
+			results[ds[i]] = BatchResult{Subdomains: r.Subs}
+		case errors.Is(r.Err, fault.ErrInvalidDomain), errors.Is(r.Err, fault.ErrInvalidDays), errors.Is(r.Err, fault.ErrPublicSuffix), errors.Is(r.Err, fault.ErrBlocked), errors.Is(r.Err, fault.ErrTooManyNames):
+			results[ds[i]] = BatchResult{Error: r.Err.Error()}
+		case errors.Is(r.Err, fault.ErrGetPartsFailed):
+			results[ds[i]] = BatchResult{Error: fault.ErrInvalidDomain.Err}
+		default:
+			c.Error(fmt.Errorf("failed to lookup %s: %w", r.Domain, r.Err))
+			results[ds[i]] = BatchResult{Error: "internal server error"}
+		}
+	}
+
+	c.JSON(http.StatusOK, results)
+}
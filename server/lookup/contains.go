@@ -0,0 +1,103 @@
+package lookup
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/elmasy-com/columbus-server/db"
+	"github.com/elmasy-com/columbus-server/fault"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	DefaultContainsLimit = 100
+	MaxContainsLimit     = 1000
+)
+
+// Return the integer value of the query parameter name.
+// If not set, returns def.
+func getQueryInt(c *gin.Context, name string, def int) (int, error) {
+
+	vStr, vSet := c.GetQuery(name)
+	if !vSet {
+		return def, nil
+	}
+
+	if vStr == "" {
+		return 0, fmt.Errorf("empty")
+	}
+
+	return strconv.Atoi(vStr)
+}
+
+// GET /api/contains/:pattern?source=&full=&limit=&skip=
+// Returns the domains (or the full hostnames if full is "true") that matches pattern.
+// pattern is a keyword (eg.: "paypal") or a glob-style pattern (eg.: "*-login.*").
+// Too broad patterns (see db.MaxContainsNames) are rejected with 400.
+func GetApiContains(c *gin.Context) {
+
+	limit, err := getQueryInt(c, "limit", DefaultContainsLimit)
+	if err != nil || limit < 1 || limit > MaxContainsLimit {
+		c.Error(fault.ErrInvalidLimit)
+		if c.GetHeader("Accept") == "text/plain" {
+			c.String(http.StatusBadRequest, fault.ErrInvalidLimit.Err)
+		} else {
+			c.JSON(http.StatusBadRequest, fault.ErrInvalidLimit)
+		}
+		return
+	}
+
+	skip, err := getQueryInt(c, "skip", 0)
+	if err != nil || skip < 0 {
+		c.Error(fault.ErrInvalidSkip)
+		if c.GetHeader("Accept") == "text/plain" {
+			c.String(http.StatusBadRequest, fault.ErrInvalidSkip.Err)
+		} else {
+			c.JSON(http.StatusBadRequest, fault.ErrInvalidSkip)
+		}
+		return
+	}
+
+	names, err := db.Contains(c.Param("pattern"), c.Query("source"), c.Query("full") == "true", limit, skip)
+	if err != nil {
+
+		c.Error(err)
+
+		respCode := 0
+
+		if errors.Is(err, fault.ErrInvalidPattern) || errors.Is(err, fault.ErrTooBroad) {
+			respCode = http.StatusBadRequest
+		} else {
+			respCode = http.StatusInternalServerError
+			err = fmt.Errorf("internal server error")
+		}
+
+		if c.GetHeader("Accept") == "text/plain" {
+			c.String(respCode, err.Error())
+		} else {
+			c.JSON(respCode, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	if len(names) == 0 {
+
+		c.Error(fault.ErrNotFound)
+
+		if c.GetHeader("Accept") == "text/plain" {
+			c.String(http.StatusNotFound, fault.ErrNotFound.Err)
+		} else {
+			c.JSON(http.StatusNotFound, fault.ErrNotFound)
+		}
+		return
+	}
+
+	if c.GetHeader("Accept") == "text/plain" {
+		c.String(http.StatusOK, strings.Join(names, "\n"))
+	} else {
+		c.JSON(http.StatusOK, names)
+	}
+}
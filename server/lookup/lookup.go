@@ -6,9 +6,12 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/elmasy-com/columbus-server/db"
 	"github.com/elmasy-com/columbus-server/fault"
+	"github.com/elmasy-com/columbus-server/idn"
+	"github.com/elmasy-com/columbus-server/server/etag"
 	"github.com/elmasy-com/elnet/dns"
 	"github.com/gin-gonic/gin"
 )
@@ -30,6 +33,85 @@ func getQueryDays(c *gin.Context) (int, error) {
 	return strconv.Atoi(daysStr)
 }
 
+// Return the filter from the "days", "source", "type", "value" and "subtree" query parameters.
+// See db.LookupFilter.
+// The returned error is a fault.ColumbusError that can be sent to the client.
+func getLookupFilter(c *gin.Context) (db.LookupFilter, error) {
+
+	var (
+		f   = db.LookupFilter{Source: c.Query("source"), Value: c.Query("value"), Subtree: c.Query("subtree") == "true"}
+		err error
+	)
+
+	f.Days, err = getQueryDays(c)
+	if err != nil {
+		return f, fault.ErrInvalidDays
+	}
+
+	f.Types, err = db.ParseRecordTypes(c.Query("type"))
+	if err != nil {
+		return f, fault.ErrInvalidType
+	}
+
+	return f, nil
+}
+
+// validators returns the Last-Modified time and the value of the ETag (see etag.Check()) of the response from the metadata of the names in v.
+// If details is true, the response contains the records and the update time, which are changed by every resolution.
+// Without details, the list of the names is changed only by a new name or a new source, so the number of names and the newest first seen time are used.
+// With f.Days > 0 the names leave (and enter with a new record) the time window, so the time of the newest record and the hour is part of the ETag
+// and Last-Modified is not used.
+func validators(v db.VersionSchema, f db.LookupFilter, details bool, params ...interface{}) (int64, []interface{}) {
+
+	if !details && f.Days <= 0 {
+		v.LastRecord = 0
+	}
+
+	modified := v.First
+
+	if v.LastRecord > modified {
+		modified = v.LastRecord
+	}
+
+	if details && v.Updated > modified {
+		modified = v.Updated
+	}
+
+	if !details {
+		v.Updated = 0
+	}
+
+	var hour int64
+
+	if f.Days > 0 {
+		modified = 0
+		hour = time.Now().Unix() / 3600
+	}
+
+	return modified, append([]interface{}{f, v, db.BlockedVersion(), hour}, params...)
+}
+
+// found updates the records and the toplist of the found domain d.
+func found(c *gin.Context, d string) {
+
+	// Send to db.RecordsUpdaterDomainChan if the channle if not full to update the DNS records.
+	// Send only if any subdomain found.
+	// In db.RecordsUpdaterDomainChan, every record for domain d is updated if not updated in the last hour.
+	if len(db.RecordsUpdaterDomainChan) < cap(db.RecordsUpdaterDomainChan) {
+		db.RecordsUpdaterDomainChan <- d
+	}
+
+	_, err := db.InsertTopList(d)
+	if err != nil {
+		c.Error(fmt.Errorf("failed to insert topList: %w", err))
+	}
+}
+
+// GET /api/lookup/{domain}?days=&source=&type=&value=&subtree=&full=&details=&unicode=
+// Returns the subdomains of domain.
+// If subtree is "true", returns only the names under the subdomain of domain (eg.: corp.example.com).
+// If full is "true", returns the full hostnames.
+// If details is "true", returns objects with the subdomain, the full hostname and the metadata of the records.
 func GetApiLookup(c *gin.Context) {
 
 	var err error
@@ -37,19 +119,52 @@ func GetApiLookup(c *gin.Context) {
 	// Parse domain param
 	d := c.Param("domain")
 
-	// Parse days query param
-	days, err := getQueryDays(c)
+	// Parse days, source, type, value and subtree query params
+	f, err := getLookupFilter(c)
 	if err != nil {
 		c.Error(err)
 		if c.GetHeader("Accept") == "text/plain" {
-			c.String(http.StatusBadRequest, fault.ErrInvalidDays.Err)
+			c.String(http.StatusBadRequest, err.Error())
 		} else {
-			c.JSON(http.StatusBadRequest, fault.ErrInvalidDays)
+			c.JSON(http.StatusBadRequest, err)
 		}
 		return
 	}
 
-	subs, err := db.Lookup(d, days)
+	var (
+		subs    []string
+		details []db.LookupDetailSchema
+		detail  = c.Query("details") == "true"
+		full    = c.Query("full") == "true"
+		unicode = c.Query("unicode") == "true"
+		known   = db.BloomMayContain(d)
+	)
+
+	// Answer the conditional request from the metadata of the names before the full query.
+	// The errors are returned by the full query.
+	if known {
+		if v, err := db.LookupVersion(d, f); err == nil && v.Names > 0 {
+
+			modified, tag := validators(v, f, detail, full, unicode)
+
+			if etag.Check(c, modified, tag) {
+				found(c, d)
+				return
+			}
+		}
+	}
+
+	switch {
+	case !known:
+		// Unknown domain, answered from the Bloom filter without querying the DB
+	case detail:
+		details, err = db.LookupDetails(d, f)
+	case full:
+		subs, err = db.LookupFull(d, f)
+	default:
+		subs, err = db.Lookup(d, f)
+	}
+
 	if err != nil {
 
 		c.Error(err)
@@ -61,6 +176,12 @@ func GetApiLookup(c *gin.Context) {
 			respCode = http.StatusBadRequest
 		case errors.Is(err, fault.ErrInvalidDays):
 			respCode = http.StatusBadRequest
+		case errors.Is(err, fault.ErrTooManyNames):
+			respCode = http.StatusBadRequest
+		case errors.Is(err, fault.ErrPublicSuffix):
+			respCode = http.StatusBadRequest
+		case errors.Is(err, fault.ErrBlocked):
+			respCode = http.StatusUnavailableForLegalReasons
 		default:
 			respCode = http.StatusInternalServerError
 			err = fmt.Errorf("internal server error")
@@ -74,13 +195,18 @@ func GetApiLookup(c *gin.Context) {
 		return
 	}
 
-	if len(subs) == 0 {
+	if len(subs) == 0 && len(details) == 0 {
 
 		c.Error(fault.ErrNotFound)
 
-		_, err = db.InsertNotFound(d)
-		if err != nil {
-			c.Error(fmt.Errorf("failed to insert notFound: %w", err))
+		// Without filter, an empty result means that the domain is unknown
+		if known && f.Days == -1 && f.Source == "" && len(f.Types) == 0 && f.Value == "" && !f.Subtree {
+			db.BloomFalsePositive()
+		}
+
+		// The domains answered from the Bloom filter are recorded too, only the query is skipped
+		if !db.InsertNotFoundAsync(d) {
+			c.Error(fmt.Errorf("failed to insert notFound: buffer is full"))
 		}
 
 		if c.GetHeader("Accept") == "text/plain" {
@@ -91,20 +217,31 @@ func GetApiLookup(c *gin.Context) {
 		return
 	}
 
-	// Send to db.RecordsUpdaterDomainChan if the channle if not full to update the DNS records.
-	// Send only if any subdomain found.
-	// In db.RecordsUpdaterDomainChan, every record for domain d is updated if not updated in the last hour.
-	if len(db.RecordsUpdaterDomainChan) < cap(db.RecordsUpdaterDomainChan) {
-		db.RecordsUpdaterDomainChan <- d
-	}
+	found(c, d)
 
-	_, err = db.InsertTopList(d)
-	if err != nil {
-		c.Error(fmt.Errorf("failed to insert topList: %w", err))
+	if detail {
+
+		if c.GetHeader("Accept") == "text/plain" {
+
+			fqdns := make([]string, 0, len(details))
+
+			for i := range details {
+				fqdns = append(fqdns, details[i].FQDN)
+			}
+
+			c.String(http.StatusOK, strings.Join(fqdns, "\n"))
+		} else {
+			c.JSON(http.StatusOK, details)
+		}
+
+		return
 	}
 
 	if c.GetHeader("Accept") == "text/plain" {
 		c.String(http.StatusOK, strings.Join(subs, "\n"))
+	} else if unicode {
+		// Return the A-label and the U-label form of the names
+		c.JSON(http.StatusOK, idn.NewNames(subs))
 	} else {
 		c.JSON(http.StatusOK, subs)
 	}
@@ -219,19 +356,32 @@ func GetApiHistory(c *gin.Context) {
 	// Parse domain param
 	d := c.Param("domain")
 
-	// Parse days query param
-	days, err := getQueryDays(c)
+	// Parse days, type and value query params
+	f, err := getLookupFilter(c)
 	if err != nil {
-		c.Error(fault.ErrInvalidDays)
+		c.Error(err)
 		if c.GetHeader("Accept") == "text/plain" {
-			c.String(http.StatusBadRequest, fault.ErrInvalidDays.Err)
+			c.String(http.StatusBadRequest, err.Error())
 		} else {
-			c.JSON(http.StatusBadRequest, fault.ErrInvalidDays)
+			c.JSON(http.StatusBadRequest, err)
 		}
 		return
 	}
 
-	records, err := db.Records(d, days)
+	// Answer the conditional request from the metadata of the name before the full query.
+	// The errors are returned by the full query.
+	if v, err := db.RecordsVersion(d); err == nil && v.Names > 0 {
+
+		// The response contains the records
+		modified, tag := validators(v, f, true)
+
+		if etag.Check(c, modified, tag) {
+			found(c, d)
+			return
+		}
+	}
+
+	records, err := db.Records(d, f)
 	if err != nil {
 
 		c.Error(err)
@@ -243,6 +393,10 @@ func GetApiHistory(c *gin.Context) {
 			respCode = http.StatusBadRequest
 		case errors.Is(err, fault.ErrInvalidDays):
 			respCode = http.StatusBadRequest
+		case errors.Is(err, fault.ErrPublicSuffix):
+			respCode = http.StatusBadRequest
+		case errors.Is(err, fault.ErrBlocked):
+			respCode = http.StatusUnavailableForLegalReasons
 		default:
 			respCode = http.StatusInternalServerError
 			err = fmt.Errorf("internal server error")
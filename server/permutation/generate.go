@@ -0,0 +1,252 @@
+package permutation
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/elmasy-com/elnet/dns"
+)
+
+var (
+	// Environment tokens swapped with each other.
+	environments = []string{"dev", "development", "test", "testing", "qa", "uat", "stage", "staging", "stg", "preprod", "prod", "production", "prd", "sandbox"}
+
+	// Environment tokens prepended and appended to labels without environment token.
+	commonEnvironments = []string{"dev", "test", "stage", "prod"}
+
+	// Region tokens swapped with each other.
+	regions = []string{"us", "eu", "uk", "de", "fr", "nl", "jp", "ap", "asia", "east", "west", "north", "south", "central", "useast", "uswest", "euwest", "eucentral"}
+
+	numberRegexp = regexp.MustCompile(`[0-9]+`)
+)
+
+type generator struct {
+	domain string
+	limit  int
+	known  map[string]struct{}
+	result []string
+}
+
+// full returns whether the limit is reached.
+func (g *generator) full() bool {
+	return len(g.result) >= g.limit
+}
+
+// add appends sub to the result if it is valid and not known yet.
+func (g *generator) add(sub string) {
+
+	if g.full() || sub == "" {
+		return
+	}
+
+	sub = strings.ToLower(sub)
+
+	if _, ok := g.known[sub]; ok {
+		return
+	}
+
+	for _, l := range strings.Split(sub, ".") {
+		if !dns.IsValidSLD(l) || strings.HasPrefix(l, "-") || strings.HasSuffix(l, "-") {
+			return
+		}
+	}
+
+	fqdn := sub + "." + g.domain
+
+	if !dns.IsValid(fqdn) {
+		return
+	}
+
+	g.known[sub] = struct{}{}
+	g.result = append(g.result, fqdn)
+}
+
+// replaceLabel returns sub with the i-th label replaced with l.
+func replaceLabel(sub string, i int, l string) string {
+
+	ls := strings.Split(sub, ".")
+	ls[i] = l
+
+	return strings.Join(ls, ".")
+}
+
+// numbers increments and decrements the numbers in the labels of sub (eg.: web01 -> web00, web02, web03).
+// Labels without number get a number appended to the first label (eg.: api -> api1, api2).
+func (g *generator) numbers(sub string) {
+
+	ls := strings.Split(sub, ".")
+
+	hasNumber := false
+
+	for i := range ls {
+
+		for _, loc := range numberRegexp.FindAllStringIndex(ls[i], -1) {
+
+			hasNumber = true
+
+			num := ls[i][loc[0]:loc[1]]
+
+			n, err := strconv.Atoi(num)
+			if err != nil {
+				continue
+			}
+
+			for _, delta := range []int{-1, 1, 2} {
+
+				if n+delta < 0 {
+					continue
+				}
+
+				v := strconv.Itoa(n + delta)
+
+				// Keep the zero padding (eg.: 01 -> 02)
+				if strings.HasPrefix(num, "0") {
+					v = fmt.Sprintf("%0*d", len(num), n+delta)
+				}
+
+				g.add(replaceLabel(sub, i, ls[i][:loc[0]]+v+ls[i][loc[1]:]))
+			}
+		}
+	}
+
+	if !hasNumber {
+		g.add(replaceLabel(sub, 0, ls[0]+"1"))
+		g.add(replaceLabel(sub, 0, ls[0]+"2"))
+	}
+}
+
+// swap replaces the tokens of the labels (separated by "-") that are in set with every other token in set.
+// Returns whether any token found in set.
+func (g *generator) swap(sub string, set []string) bool {
+
+	ls := strings.Split(sub, ".")
+
+	found := false
+
+	for i := range ls {
+
+		tokens := strings.Split(ls[i], "-")
+
+		for ii := range tokens {
+
+			if !contains(set, tokens[ii]) {
+				continue
+			}
+
+			found = true
+
+			orig := tokens[ii]
+
+			for _, t := range set {
+				if t == orig {
+					continue
+				}
+
+				tokens[ii] = t
+				g.add(replaceLabel(sub, i, strings.Join(tokens, "-")))
+			}
+
+			tokens[ii] = orig
+		}
+	}
+
+	return found
+}
+
+// environments swaps the environment tokens in sub (eg.: api-dev -> api-prod).
+// If sub has no environment token, common environments are prepended and appended to the first label (eg.: api -> dev-api, api-dev).
+func (g *generator) environments(sub string) {
+
+	if g.swap(sub, environments) {
+		return
+	}
+
+	first := strings.Split(sub, ".")[0]
+
+	for _, e := range commonEnvironments {
+		g.add(replaceLabel(sub, 0, e+"-"+first))
+		g.add(replaceLabel(sub, 0, first+"-"+e))
+	}
+}
+
+// recombine combines the first labels of the known subdomains with the parents of the known subdomains
+// (eg.: api.dev and www.prod -> api.prod, www.dev).
+func (g *generator) recombine(subs []string) {
+
+	var firsts, parents []string
+
+	for i := range subs {
+
+		first, parent, hasParent := strings.Cut(subs[i], ".")
+
+		if !contains(firsts, first) {
+			firsts = append(firsts, first)
+		}
+
+		if hasParent && !contains(parents, parent) {
+			parents = append(parents, parent)
+		}
+	}
+
+	for i := range parents {
+		for ii := range firsts {
+			g.add(firsts[ii] + "." + parents[i])
+		}
+	}
+}
+
+func contains(s []string, v string) bool {
+
+	for i := range s {
+		if s[i] == v {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Generate returns at most limit likely unseen hostnames under domain based on the known subdomains subs (eg.: ["www", "api.dev"]).
+// The empty subdomain (the domain itself) is ignored in subs.
+//
+// For every known subdomain, permutations are generated in this order:
+//   - numeric increments (eg.: web01 -> web02)
+//   - environment swaps (eg.: api-dev -> api-prod)
+//   - region swaps (eg.: cdn-eu -> cdn-us)
+//
+// After that, the label recombinations are generated (eg.: api.dev and www.prod -> api.prod).
+//
+// The returned hostnames are full domain names (eg.: web02.example.com).
+func Generate(domain string, subs []string, limit int) []string {
+
+	g := &generator{domain: dns.Clean(domain), limit: limit, known: make(map[string]struct{}, len(subs))}
+
+	known := make([]string, 0, len(subs))
+
+	for i := range subs {
+
+		if subs[i] == "" {
+			continue
+		}
+
+		s := strings.ToLower(subs[i])
+
+		g.known[s] = struct{}{}
+		known = append(known, s)
+	}
+
+	sort.Strings(known)
+
+	for i := range known {
+		g.numbers(known[i])
+		g.environments(known[i])
+		g.swap(known[i], regions)
+	}
+
+	g.recombine(known)
+
+	return g.result
+}
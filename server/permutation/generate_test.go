@@ -0,0 +1,27 @@
+package permutation
+
+import "testing"
+
+func TestGenerate(t *testing.T) {
+
+	r := Generate("example.com", []string{"", "web01", "api-dev", "www.prod", "api.dev"}, 1000)
+
+	want := []string{"web00.example.com", "web02.example.com", "api-prod.example.com", "api.prod.example.com", "www.dev.example.com"}
+
+	for i := range want {
+		if !contains(r, want[i]) {
+			t.Fatalf("FAIL: %s is missing from %v\n", want[i], r)
+		}
+	}
+
+	for _, known := range []string{"example.com", "web01.example.com", "api-dev.example.com", "www.prod.example.com", "api.dev.example.com"} {
+		if contains(r, known) {
+			t.Fatalf("FAIL: known name %s is returned\n", known)
+		}
+	}
+
+	r = Generate("example.com", []string{"web01", "api-dev"}, 3)
+	if len(r) != 3 {
+		t.Fatalf("FAIL: limit is not respected: %v\n", r)
+	}
+}
@@ -0,0 +1,144 @@
+package permutation
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/elmasy-com/columbus-server/db"
+	"github.com/elmasy-com/columbus-server/fault"
+	"github.com/elmasy-com/columbus-server/server/auth"
+	"github.com/elmasy-com/elnet/dns"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	DefaultLimit = 100
+	MaxLimit     = 1000
+)
+
+// Return the "limit" query parameter.
+// If not set, returns DefaultLimit.
+func getQueryLimit(c *gin.Context) (int, error) {
+
+	limitStr, limitSet := c.GetQuery("limit")
+	if !limitSet {
+		return DefaultLimit, nil
+	}
+
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil {
+		return 0, err
+	}
+
+	if limit < 1 || limit > MaxLimit {
+		return 0, fault.ErrInvalidLimit
+	}
+
+	return limit, nil
+}
+
+// GET /api/permutations/{domain}?limit=&resolve=
+// Returns likely unseen hostnames generated from the known subdomains of domain.
+//
+// If resolve is "true", the candidates are queued in the records updater and the ones that resolves are inserted.
+// The resolve mode requires an API key.
+func GetApiPermutations(c *gin.Context) {
+
+	d := c.Param("domain")
+
+	limit, err := getQueryLimit(c)
+	if err != nil {
+		c.Error(err)
+		if c.GetHeader("Accept") == "text/plain" {
+			c.String(http.StatusBadRequest, fault.ErrInvalidLimit.Err)
+		} else {
+			c.JSON(http.StatusBadRequest, fault.ErrInvalidLimit)
+		}
+		return
+	}
+
+	resolve := c.Query("resolve") == "true"
+
+	if resolve {
+		auth.RequireAPIKey(c)
+		if c.IsAborted() {
+			return
+		}
+	}
+
+	subs, err := db.Lookup(d, db.LookupFilter{Days: -1})
+	if err != nil {
+
+		c.Error(err)
+
+		respCode := 0
+
+		switch {
+		case errors.Is(err, fault.ErrInvalidDomain):
+			respCode = http.StatusBadRequest
+		case errors.Is(err, fault.ErrGetPartsFailed):
+			respCode = http.StatusBadRequest
+			err = fault.ErrInvalidDomain
+		case errors.Is(err, fault.ErrPublicSuffix):
+			respCode = http.StatusBadRequest
+		case errors.Is(err, fault.ErrBlocked):
+			respCode = http.StatusUnavailableForLegalReasons
+		default:
+			respCode = http.StatusInternalServerError
+			err = fmt.Errorf("internal server error")
+		}
+
+		if c.GetHeader("Accept") == "text/plain" {
+			c.String(respCode, err.Error())
+		} else {
+			c.JSON(respCode, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	if len(subs) == 0 {
+
+		c.Error(fault.ErrNotFound)
+
+		if c.GetHeader("Accept") == "text/plain" {
+			c.String(http.StatusNotFound, fault.ErrNotFound.Err)
+		} else {
+			c.JSON(http.StatusNotFound, fault.ErrNotFound)
+		}
+		return
+	}
+
+	candidates := Generate(db.GetDomain(dns.Clean(d)), subs, limit)
+
+	code := http.StatusOK
+
+	if resolve {
+
+		code = http.StatusAccepted
+
+		queued := make([]string, 0, len(candidates))
+
+		// Send to db.RecordsResolveChan until the channel is full.
+	send:
+		for i := range candidates {
+
+			select {
+			case db.RecordsResolveChan <- candidates[i]:
+				queued = append(queued, candidates[i])
+			default:
+				break send
+			}
+		}
+
+		candidates = queued
+	}
+
+	if c.GetHeader("Accept") == "text/plain" {
+		c.String(code, strings.Join(candidates, "\n"))
+	} else {
+		c.JSON(code, candidates)
+	}
+}
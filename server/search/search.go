@@ -11,6 +11,7 @@ import (
 
 	"github.com/elmasy-com/columbus-server/db"
 	"github.com/elmasy-com/columbus-server/fault"
+	"github.com/elmasy-com/columbus-server/idn"
 	"github.com/elmasy-com/elnet/dns"
 	"github.com/gin-gonic/gin"
 )
@@ -21,15 +22,25 @@ type RecordsData struct {
 	Time  string
 }
 
+type SourcesData struct {
+	Name  string
+	First string
+	Last  string
+}
+
 type DomainsData struct {
-	Domain  string
-	Records []RecordsData
+	Domain      string
+	Unicode     string
+	MixedScript bool
+	Records     []RecordsData
+	Sources     []SourcesData
 }
 
 type SearchData struct {
 	Question string
+	Unicode  string
 	Domains  []DomainsData
-	Unknowns []string
+	Unknowns []idn.Name
 	Error    error
 }
 
@@ -48,6 +59,9 @@ var searchInternalServerErrorHtml string
 //go:embed searchNotFound.html
 var searchNotFoundHtml string
 
+//go:embed searchBlocked.html
+var searchBlockedHtml string
+
 func GetSearch(c *gin.Context) {
 
 	c.Data(http.StatusOK, "text/html", []byte(searchHtml))
@@ -61,7 +75,7 @@ func GetSearchResult(c *gin.Context) {
 	// Parse domain param
 	d := c.Param("domain")
 
-	doms, err = db.LookupFull(d, -1)
+	doms, err = db.LookupFull(d, db.LookupFilter{Days: -1, Source: c.Query("source")})
 	if err != nil {
 
 		c.Error(fmt.Errorf("fail to lookup full: %w", err))
@@ -71,6 +85,10 @@ func GetSearchResult(c *gin.Context) {
 			c.Data(http.StatusBadRequest, "text/html", []byte(searchBadrequestHtml))
 		case errors.Is(err, fault.ErrInvalidDays):
 			c.Data(http.StatusBadRequest, "text/html", []byte(searchBadrequestHtml))
+		case errors.Is(err, fault.ErrPublicSuffix):
+			c.Data(http.StatusBadRequest, "text/html", []byte(searchBadrequestHtml))
+		case errors.Is(err, fault.ErrBlocked):
+			c.Data(http.StatusUnavailableForLegalReasons, "text/html", []byte(searchBlockedHtml))
 		default:
 			c.Data(http.StatusInternalServerError, "text/html", []byte(searchInternalServerErrorHtml))
 		}
@@ -80,9 +98,8 @@ func GetSearchResult(c *gin.Context) {
 
 	if len(doms) == 0 {
 
-		_, err = db.InsertNotFound(d)
-		if err != nil {
-			c.Error(fmt.Errorf("failed to insert notFound: %w", err))
+		if !db.InsertNotFoundAsync(d) {
+			c.Error(fmt.Errorf("failed to insert notFound: buffer is full"))
 		}
 
 		c.Data(http.StatusNotFound, "text/html", []byte(searchNotFoundHtml))
@@ -103,33 +120,40 @@ func GetSearchResult(c *gin.Context) {
 		db.RecordsUpdaterDomainChan <- d
 	}
 
-	searchData := SearchData{Question: d}
+	// The records of every name are queried at once
+	records, err := db.RecordsDomain(d, db.LookupFilter{})
+	if err != nil {
 
-	for i := range doms {
+		c.Error(fmt.Errorf("fail to get records for %s: %w", d, err))
 
-		rs, err := db.Records(doms[i], 0)
-		if err != nil {
+		switch {
+		case errors.Is(err, fault.ErrInvalidDomain):
+			c.Data(http.StatusBadRequest, "text/html", []byte(searchBadrequestHtml))
+		case errors.Is(err, fault.ErrInvalidDays):
+			c.Data(http.StatusBadRequest, "text/html", []byte(searchBadrequestHtml))
+		case errors.Is(err, fault.ErrPublicSuffix):
+			c.Data(http.StatusBadRequest, "text/html", []byte(searchBadrequestHtml))
+		case errors.Is(err, fault.ErrBlocked):
+			c.Data(http.StatusUnavailableForLegalReasons, "text/html", []byte(searchBlockedHtml))
+		default:
+			c.Data(http.StatusInternalServerError, "text/html", []byte(searchInternalServerErrorHtml))
+		}
 
-			c.Error(fmt.Errorf("fail to get record for %s: %w", doms[i], err))
+		return
+	}
 
-			switch {
-			case errors.Is(err, fault.ErrInvalidDomain):
-				c.Data(http.StatusBadRequest, "text/html", []byte(searchBadrequestHtml))
-			case errors.Is(err, fault.ErrInvalidDays):
-				c.Data(http.StatusBadRequest, "text/html", []byte(searchBadrequestHtml))
-			default:
-				c.Data(http.StatusInternalServerError, "text/html", []byte(searchInternalServerErrorHtml))
-			}
+	searchData := SearchData{Question: d, Unicode: idn.ToUnicode(d)}
 
-			return
-		}
+	for i := range doms {
+
+		rs := records[doms[i]]
 
 		if len(rs) == 0 {
-			searchData.Unknowns = append(searchData.Unknowns, doms[i])
+			searchData.Unknowns = append(searchData.Unknowns, idn.NewName(doms[i]))
 			continue
 		}
 
-		v := DomainsData{Domain: doms[i]}
+		v := DomainsData{Domain: doms[i], Unicode: idn.ToUnicode(doms[i]), MixedScript: idn.IsMixedScript(doms[i])}
 
 		for ii := range rs {
 			v.Records = append(v.Records, RecordsData{Type: dns.TypeToString(rs[ii].Type), Value: rs[ii].Value, Time: time.Unix(rs[ii].Time, 0).String()})
@@ -137,6 +161,16 @@ func GetSearchResult(c *gin.Context) {
 
 		sort.Slice(v.Records, func(i, j int) bool { return v.Records[i].Time > v.Records[j].Time })
 
+		ss, err := db.Sources(doms[i])
+		if err != nil {
+			// Sources are not crucial, log the error and continue
+			c.Error(fmt.Errorf("fail to get sources for %s: %w", doms[i], err))
+		}
+
+		for ii := range ss {
+			v.Sources = append(v.Sources, SourcesData{Name: ss[ii].Name, First: time.Unix(ss[ii].First, 0).String(), Last: time.Unix(ss[ii].Last, 0).String()})
+		}
+
 		searchData.Domains = append(searchData.Domains, v)
 	}
 
@@ -11,9 +11,17 @@ import (
 	"time"
 
 	"github.com/elmasy-com/columbus-server/config"
+	"github.com/elmasy-com/columbus-server/db"
+	"github.com/elmasy-com/columbus-server/server/admin"
+	"github.com/elmasy-com/columbus-server/server/auth"
+	"github.com/elmasy-com/columbus-server/server/discovery"
+	"github.com/elmasy-com/columbus-server/server/dumps"
+	"github.com/elmasy-com/columbus-server/server/lookalike"
 	"github.com/elmasy-com/columbus-server/server/lookup"
+	"github.com/elmasy-com/columbus-server/server/permutation"
 	"github.com/elmasy-com/columbus-server/server/search"
 	"github.com/elmasy-com/columbus-server/server/stat"
+	"github.com/elmasy-com/columbus-server/server/toplist"
 
 	"github.com/gin-gonic/gin"
 )
@@ -52,16 +60,53 @@ func Run() error {
 
 	router.Use(gin.LoggerWithFormatter(GinLog))
 	router.Use(gin.Recovery())
+	router.Use(normalizeParams)
+	router.Use(cacheControl)
 
 	router.SetTrustedProxies(config.TrustedProxies)
 
 	router.GET("/api/lookup/:domain", lookup.GetApiLookup)
+	router.POST("/api/lookup", lookup.PostApiLookup)
 	router.GET("/api/starts/:domain", lookup.GetApiStarts)
 	router.GET("/api/tld/:domain", lookup.GetApiTLD)
 	router.GET("/api/history/:domain", lookup.GetApiHistory)
+	router.GET("/api/permutations/:domain", permutation.GetApiPermutations)
 
 	// router.PUT("/insert/:domain", InsertPut)
 
+	router.GET("/api/admin/cache", auth.RequireAdmin, admin.GetApiCache)
+	router.GET("/api/admin/retention", auth.RequireAdmin, admin.GetApiRetention)
+	router.GET("/api/admin/blocked", auth.RequireAdmin, admin.GetApiBlocked)
+	router.POST("/api/admin/blocked", auth.RequireAdmin, admin.PostApiBlocked)
+	router.DELETE("/api/admin/blocked", auth.RequireAdmin, admin.DeleteApiBlocked)
+	router.GET("/api/admin/blocked/audit", auth.RequireAdmin, admin.GetApiBlockedAudit)
+
+	// These features use MongoDB-only collections and aggregations
+	if db.MongoDB() {
+
+		router.GET("/api/contains/:pattern", auth.RequireAPIKey, lookup.GetApiContains)
+		router.GET("/api/lookalike/:domain", lookalike.GetApiLookalike)
+
+		router.GET("/api/toplist", toplist.GetApiTopList)
+		router.GET("/api/trending", toplist.GetApiTrending)
+
+		router.GET("/api/admin/notfound", auth.RequireAdmin, admin.GetApiNotFound)
+		router.GET("/api/admin/bloom", auth.RequireAdmin, admin.GetApiBloom)
+
+		router.GET("/api/stat/tld", stat.GetApiStatTLD)
+		router.GET("/api/stat/domain/:domain", stat.GetApiStatDomain)
+		router.GET("/api/wordlist", stat.GetApiWordlist)
+	}
+
+	if config.BruteForce {
+		router.POST("/api/bruteforce/:domain", auth.RequireAPIKey, discovery.PostApiBruteForce)
+	}
+
+	if config.DumpDir != "" {
+		router.GET("/api/dumps/", dumps.GetApiDumps)
+		router.GET("/api/dumps/:file", dumps.GetApiDumpsFile)
+	}
+
 	router.GET("/api/stat", stat.GetApiStat)
 	router.GET("/stat", stat.GetStat)
 
@@ -72,6 +117,7 @@ func Run() error {
 	router.GET("/api/tools/domain/:fqdn", ToolsDomainGet)
 	router.GET("/api/tools/subdomain/:fqdn", ToolsSubdomainGet)
 	router.GET("/api/tools/isvalid/:fqdn", ToolsIsValidGet)
+	router.GET("/api/tools/punycode/:fqdn", ToolsPunycodeGet)
 
 	// Redirect to /search/:domain
 	router.GET("/lookup/:domain", RedirectLookup)
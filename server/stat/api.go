@@ -1,10 +1,14 @@
 package stat
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/elmasy-com/columbus-server/db"
+	"github.com/elmasy-com/columbus-server/fault"
+	"github.com/elmasy-com/columbus-server/server/etag"
 	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 func GetApiStat(c *gin.Context) {
@@ -17,5 +21,62 @@ func GetApiStat(c *gin.Context) {
 		return
 	}
 
+	if etag.Check(c, s.Date, s) {
+		return
+	}
+
+	c.JSON(http.StatusOK, s)
+}
+
+func GetApiStatTLD(c *gin.Context) {
+
+	s, err := db.StatisticsTLDGetNewest()
+	if err != nil {
+
+		c.Error(err)
+
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			c.JSON(http.StatusNotFound, fault.ErrNotFound)
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		}
+		return
+	}
+
+	if etag.Check(c, s.Date, s) {
+		return
+	}
+
+	c.JSON(http.StatusOK, s)
+}
+
+func GetApiStatDomain(c *gin.Context) {
+
+	s, err := db.StatisticsDomain(c.Param("domain"))
+	if err != nil {
+
+		c.Error(err)
+
+		switch {
+		case errors.Is(err, fault.ErrInvalidDomain):
+			c.JSON(http.StatusBadRequest, fault.ErrInvalidDomain)
+		case errors.Is(err, fault.ErrGetPartsFailed):
+			c.JSON(http.StatusBadRequest, fault.ErrInvalidDomain)
+		case errors.Is(err, fault.ErrPublicSuffix):
+			c.JSON(http.StatusBadRequest, fault.ErrPublicSuffix)
+		case errors.Is(err, fault.ErrNotFound):
+			c.JSON(http.StatusNotFound, fault.ErrNotFound)
+		case errors.Is(err, fault.ErrBlocked):
+			c.JSON(http.StatusUnavailableForLegalReasons, fault.ErrBlocked)
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		}
+		return
+	}
+
+	if etag.Check(c, s.Updated, s) {
+		return
+	}
+
 	c.JSON(http.StatusOK, s)
 }
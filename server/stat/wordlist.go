@@ -0,0 +1,96 @@
+package stat
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/elmasy-com/columbus-server/db"
+	"github.com/elmasy-com/columbus-server/fault"
+	"github.com/elmasy-com/elnet/dns"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	DefaultWordlistTop = 1000
+	MaxWordlistTop     = 100000
+)
+
+// GET /api/wordlist?top=&tld=
+// Returns the top most frequent subdomain labels in tld.
+// If tld is not set, returns the most frequent labels across every TLD.
+func GetApiWordlist(c *gin.Context) {
+
+	top := DefaultWordlistTop
+
+	if topStr, topSet := c.GetQuery("top"); topSet {
+
+		var err error
+
+		top, err = strconv.Atoi(topStr)
+		if err != nil || top < 1 || top > MaxWordlistTop {
+			c.Error(fault.ErrInvalidLimit)
+			if c.GetHeader("Accept") == "text/plain" {
+				c.String(http.StatusBadRequest, fault.ErrInvalidLimit.Err)
+			} else {
+				c.JSON(http.StatusBadRequest, fault.ErrInvalidLimit)
+			}
+			return
+		}
+	}
+
+	tld := c.Query("tld")
+
+	if tld != "" {
+
+		if !dns.IsValid(tld) {
+			c.Error(fault.ErrInvalidDomain)
+			if c.GetHeader("Accept") == "text/plain" {
+				c.String(http.StatusBadRequest, fault.ErrInvalidDomain.Err)
+			} else {
+				c.JSON(http.StatusBadRequest, fault.ErrInvalidDomain)
+			}
+			return
+		}
+
+		tld = dns.Clean(tld)
+	}
+
+	ws, err := db.WordlistGets(tld, top)
+	if err != nil {
+
+		c.Error(err)
+
+		if c.GetHeader("Accept") == "text/plain" {
+			c.String(http.StatusInternalServerError, "internal server error")
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		}
+		return
+	}
+
+	if len(ws) == 0 {
+
+		c.Error(fault.ErrNotFound)
+
+		if c.GetHeader("Accept") == "text/plain" {
+			c.String(http.StatusNotFound, fault.ErrNotFound.Err)
+		} else {
+			c.JSON(http.StatusNotFound, fault.ErrNotFound)
+		}
+		return
+	}
+
+	if c.GetHeader("Accept") == "text/plain" {
+
+		labels := make([]string, 0, len(ws))
+
+		for i := range ws {
+			labels = append(labels, ws[i].Label)
+		}
+
+		c.String(http.StatusOK, strings.Join(labels, "\n"))
+	} else {
+		c.JSON(http.StatusOK, ws)
+	}
+}
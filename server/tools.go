@@ -4,13 +4,15 @@ import (
 	"fmt"
 	"net/http"
 
+	"github.com/elmasy-com/columbus-server/db"
 	"github.com/elmasy-com/columbus-server/fault"
+	"github.com/elmasy-com/columbus-server/idn"
 	"github.com/elmasy-com/elnet/dns"
 	"github.com/gin-gonic/gin"
 )
 
 // GET /tools/tld/{fqdn}
-// Returns the TLD part of a FQDN.
+// Returns the TLD part (the public suffix, see db.PublicSuffix()) of a FQDN.
 func ToolsTLDGet(c *gin.Context) {
 
 	fqdn := c.Param("fqdn")
@@ -27,7 +29,7 @@ func ToolsTLDGet(c *gin.Context) {
 
 	fqdn = dns.Clean(fqdn)
 
-	d := dns.GetTLD(fqdn)
+	d := db.PublicSuffix(fqdn)
 	if d == "" {
 		c.Error(fault.ErrNotFound)
 		if c.GetHeader("Accept") == "text/plain" {
@@ -47,6 +49,7 @@ func ToolsTLDGet(c *gin.Context) {
 
 // GET /tools/domain/{fqdn}
 // Returns the domain part of a FQDN.
+// If fqdn is a public suffix, returns fault.ErrPublicSuffix.
 func ToolsDomainGet(c *gin.Context) {
 
 	fqdn := c.Param("fqdn")
@@ -63,7 +66,17 @@ func ToolsDomainGet(c *gin.Context) {
 
 	fqdn = dns.Clean(fqdn)
 
-	d := dns.GetDomain(fqdn)
+	if db.IsPublicSuffix(fqdn) {
+		c.Error(fault.ErrPublicSuffix)
+		if c.GetHeader("Accept") == "text/plain" {
+			c.String(http.StatusBadRequest, fault.ErrPublicSuffix.Err)
+		} else {
+			c.JSON(http.StatusBadRequest, fault.ErrPublicSuffix)
+		}
+		return
+	}
+
+	d := db.GetDomain(fqdn)
 	if d == "" {
 		c.Error(fault.ErrNotFound)
 		if c.GetHeader("Accept") == "text/plain" {
@@ -83,6 +96,7 @@ func ToolsDomainGet(c *gin.Context) {
 
 // GET /tools/subdomain/{fqdn}
 // Returns the subdomain part of a FQDN.
+// If fqdn is a public suffix, returns fault.ErrPublicSuffix.
 func ToolsSubdomainGet(c *gin.Context) {
 
 	fqdn := c.Param("fqdn")
@@ -99,8 +113,18 @@ func ToolsSubdomainGet(c *gin.Context) {
 
 	fqdn = dns.Clean(fqdn)
 
-	d := dns.GetSub(fqdn)
-	if d == "" {
+	if db.IsPublicSuffix(fqdn) {
+		c.Error(fault.ErrPublicSuffix)
+		if c.GetHeader("Accept") == "text/plain" {
+			c.String(http.StatusBadRequest, fault.ErrPublicSuffix.Err)
+		} else {
+			c.JSON(http.StatusBadRequest, fault.ErrPublicSuffix)
+		}
+		return
+	}
+
+	p := db.GetParts(fqdn)
+	if p == nil || p.Sub == "" {
 		c.Error(fault.ErrNotFound)
 		if c.GetHeader("Accept") == "text/plain" {
 			c.String(http.StatusNotFound, fault.ErrInvalidDomain.Err)
@@ -111,9 +135,9 @@ func ToolsSubdomainGet(c *gin.Context) {
 	}
 
 	if c.GetHeader("Accept") == "text/plain" {
-		c.String(http.StatusOK, d)
+		c.String(http.StatusOK, p.Sub)
 	} else {
-		c.JSON(http.StatusOK, gin.H{"result": d})
+		c.JSON(http.StatusOK, gin.H{"result": p.Sub})
 	}
 }
 
@@ -130,3 +154,30 @@ func ToolsIsValidGet(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"result": dns.IsValid(fqdn)})
 	}
 }
+
+// GET /api/tools/punycode/{fqdn}
+// Returns the A-label (punycode) and the U-label (Unicode) form of fqdn and whether it mixes scripts.
+// fqdn can be given in either form.
+func ToolsPunycodeGet(c *gin.Context) {
+
+	// The parameter is converted to A-label form by normalizeParams()
+	fqdn := c.Param("fqdn")
+
+	if !dns.IsValid(fqdn) || fqdn == "." {
+		c.Error(fault.ErrInvalidDomain)
+		if c.GetHeader("Accept") == "text/plain" {
+			c.String(http.StatusBadRequest, fault.ErrInvalidDomain.Err)
+		} else {
+			c.JSON(http.StatusBadRequest, fault.ErrInvalidDomain)
+		}
+		return
+	}
+
+	n := idn.NewName(dns.Clean(fqdn))
+
+	if c.GetHeader("Accept") == "text/plain" {
+		c.String(http.StatusOK, fmt.Sprintf("%s\n%s", n.ASCII, n.Unicode))
+	} else {
+		c.JSON(http.StatusOK, gin.H{"ascii": n.ASCII, "unicode": n.Unicode, "mixedScript": idn.IsMixedScript(n.ASCII)})
+	}
+}
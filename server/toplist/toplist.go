@@ -0,0 +1,178 @@
+package toplist
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/elmasy-com/columbus-server/db"
+	"github.com/elmasy-com/columbus-server/fault"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	DefaultLimit = 100
+	MaxLimit     = 1000
+	DefaultDays  = 7
+	DefaultTop   = 30 // Default window of the toplist in days
+)
+
+// Return the integer value of the query parameter name.
+// If not set, returns def.
+func getQueryInt(c *gin.Context, name string, def int) (int, error) {
+
+	vStr, vSet := c.GetQuery(name)
+	if !vSet {
+		return def, nil
+	}
+
+	if vStr == "" {
+		return 0, fmt.Errorf("empty")
+	}
+
+	return strconv.Atoi(vStr)
+}
+
+// Return the "limit" query parameter.
+// If not set, returns DefaultLimit.
+func getQueryLimit(c *gin.Context) (int, error) {
+
+	limit, err := getQueryInt(c, "limit", DefaultLimit)
+	if err != nil {
+		return 0, err
+	}
+
+	if limit < 1 || limit > MaxLimit {
+		return 0, fault.ErrInvalidLimit
+	}
+
+	return limit, nil
+}
+
+// GET /api/toplist?days=&limit=
+// Returns the most looked up domains in the last days days.
+func GetApiTopList(c *gin.Context) {
+
+	limit, err := getQueryLimit(c)
+	if err != nil {
+		c.Error(err)
+		if c.GetHeader("Accept") == "text/plain" {
+			c.String(http.StatusBadRequest, fault.ErrInvalidLimit.Err)
+		} else {
+			c.JSON(http.StatusBadRequest, fault.ErrInvalidLimit)
+		}
+		return
+	}
+
+	days, err := getQueryInt(c, "days", DefaultTop)
+	if err != nil {
+		c.Error(err)
+		if c.GetHeader("Accept") == "text/plain" {
+			c.String(http.StatusBadRequest, fault.ErrInvalidDays.Err)
+		} else {
+			c.JSON(http.StatusBadRequest, fault.ErrInvalidDays)
+		}
+		return
+	}
+
+	top, err := db.TopListGets(days, limit)
+	if err != nil {
+
+		c.Error(err)
+
+		respCode := 0
+
+		switch {
+		case errors.Is(err, fault.ErrInvalidDays):
+			respCode = http.StatusBadRequest
+		default:
+			respCode = http.StatusInternalServerError
+			err = fmt.Errorf("internal server error")
+		}
+
+		if c.GetHeader("Accept") == "text/plain" {
+			c.String(respCode, err.Error())
+		} else {
+			c.JSON(respCode, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	if c.GetHeader("Accept") == "text/plain" {
+
+		doms := make([]string, 0, len(top))
+
+		for i := range top {
+			doms = append(doms, top[i].Domain)
+		}
+
+		c.String(http.StatusOK, strings.Join(doms, "\n"))
+	} else {
+		c.JSON(http.StatusOK, top)
+	}
+}
+
+// GET /api/trending?days=&limit=
+// Returns the domains with the biggest growth in lookups in the last days days compared to the days days before.
+func GetApiTrending(c *gin.Context) {
+
+	limit, err := getQueryLimit(c)
+	if err != nil {
+		c.Error(err)
+		if c.GetHeader("Accept") == "text/plain" {
+			c.String(http.StatusBadRequest, fault.ErrInvalidLimit.Err)
+		} else {
+			c.JSON(http.StatusBadRequest, fault.ErrInvalidLimit)
+		}
+		return
+	}
+
+	days, err := getQueryInt(c, "days", DefaultDays)
+	if err != nil {
+		c.Error(err)
+		if c.GetHeader("Accept") == "text/plain" {
+			c.String(http.StatusBadRequest, fault.ErrInvalidDays.Err)
+		} else {
+			c.JSON(http.StatusBadRequest, fault.ErrInvalidDays)
+		}
+		return
+	}
+
+	trending, err := db.TopListTrending(days, limit)
+	if err != nil {
+
+		c.Error(err)
+
+		respCode := 0
+
+		switch {
+		case errors.Is(err, fault.ErrInvalidDays):
+			respCode = http.StatusBadRequest
+		default:
+			respCode = http.StatusInternalServerError
+			err = fmt.Errorf("internal server error")
+		}
+
+		if c.GetHeader("Accept") == "text/plain" {
+			c.String(respCode, err.Error())
+		} else {
+			c.JSON(respCode, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	if c.GetHeader("Accept") == "text/plain" {
+
+		doms := make([]string, 0, len(trending))
+
+		for i := range trending {
+			doms = append(doms, trending[i].Domain)
+		}
+
+		c.String(http.StatusOK, strings.Join(doms, "\n"))
+	} else {
+		c.JSON(http.StatusOK, trending)
+	}
+}